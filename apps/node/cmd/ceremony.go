@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lairik-pulse/node/internal/p2p"
+	"github.com/lairik-pulse/node/internal/zkp"
+	"github.com/sirupsen/logrus"
+)
+
+const ceremonyUsage = `Usage: lairik-node ceremony <command> [flags]
+
+Commands:
+  init        start a new trusted setup ceremony transcript
+  contribute  add your randomness, locally or to a coordinator over the mesh
+  serve       coordinate the ceremony for participants on the mesh
+  verify      re-verify every contribution in a transcript
+  finalize    close the current phase; closing phase 2 installs the keys
+`
+
+// runCeremony implements the `ceremony` subcommands and returns the exit code.
+func runCeremony(args []string, log *logrus.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, ceremonyUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("ceremony "+args[0], flag.ExitOnError)
+	dir := fs.String("dir", "./ceremony", "Ceremony transcript directory")
+
+	var err error
+	switch args[0] {
+	case "init":
+		circuit := fs.String("circuit", "degree", "Proof type whose circuit the ceremony is for (degree, identity, age_over, residency)")
+		fs.Parse(args[1:])
+		var c *zkp.Ceremony
+		if c, err = zkp.InitCeremony(*dir, *circuit); err == nil {
+			m := c.Manifest()
+			log.Infof("Ceremony for circuit %s (%s) initialised in %s, power 2^%d", m.Circuit, m.Fingerprint[:16], *dir, m.Power)
+		}
+
+	case "contribute":
+		name := fs.String("name", "", "Name recorded with your contribution")
+		coordinator := fs.String("peer", "", "Coordinator multiaddr; contribute over the mesh instead of to -dir")
+		fs.Parse(args[1:])
+		if *name == "" {
+			err = fmt.Errorf("-name is required")
+			break
+		}
+		var entry *zkp.CeremonyContribution
+		if *coordinator != "" {
+			entry, err = p2p.ContributeRemote(context.Background(), *coordinator, *name)
+		} else {
+			var c *zkp.Ceremony
+			if c, err = zkp.OpenCeremony(*dir); err == nil {
+				entry, err = c.Contribute(*name)
+			}
+		}
+		if err == nil {
+			log.Infof("Contribution %d to phase %d accepted, hash %s", entry.Index, entry.Phase, entry.Hash)
+		}
+
+	case "serve":
+		p2pPort := fs.Int("p2p-port", 0, "P2P port (0 for random)")
+		fs.Parse(args[1:])
+		err = serveCeremony(*dir, *p2pPort, log)
+
+	case "verify":
+		dataDir := fs.String("data", "", "Also check the keys installed in this data directory")
+		fs.Parse(args[1:])
+		var c *zkp.Ceremony
+		if c, err = zkp.OpenCeremony(*dir); err == nil {
+			if err = c.Verify(*dataDir); err == nil {
+				m := c.Manifest()
+				log.Infof("Transcript OK: %d contribution(s), phase %d, finalized=%v", len(m.Contributions), m.Phase, m.Finalized)
+			}
+		}
+
+	case "finalize":
+		dataDir := fs.String("data", "./data", "Data directory to install the final keys into")
+		fs.Parse(args[1:])
+		var c *zkp.Ceremony
+		if c, err = zkp.OpenCeremony(*dir); err == nil {
+			if err = c.Finalize(*dataDir); err == nil {
+				if m := c.Manifest(); m.Finalized {
+					log.Infof("Ceremony finalized; keys for circuit %s installed in %s", m.Fingerprint[:16], *dataDir)
+				} else {
+					log.Infof("Phase 1 closed; phase 2 is open for contributions")
+				}
+			}
+		}
+
+	default:
+		fmt.Fprint(os.Stderr, ceremonyUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Errorf("ceremony %s: %v", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func serveCeremony(dir string, port int, log *logrus.Logger) error {
+	c, err := zkp.OpenCeremony(dir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	node, err := p2p.NewNode(ctx, p2p.Config{Port: port, Logger: log})
+	if err != nil {
+		return err
+	}
+	defer node.Stop()
+	node.ServeCeremony(c)
+
+	log.Info("Coordinating ceremony; participants run:")
+	for _, addr := range node.Addrs() {
+		log.Infof("  lairik-node ceremony contribute -name <you> -peer %s", addr)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	return nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/zkp"
+	"github.com/sirupsen/logrus"
+)
+
+const issuerUsage = `Usage: lairik-node issuer <command> [flags]
+
+Commands:
+  keygen  create an institution signing key
+  sign    sign a credential so its holder can prove it
+  revoke  sign a revocation of a proof or of every proof over a document
+  add     trust an institution's public key on this node
+  remove  stop trusting an institution's public key
+  list    list the institutions this node trusts
+`
+
+// runIssuer implements the `issuer` subcommands and returns the exit code.
+func runIssuer(args []string, log *logrus.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, issuerUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("issuer "+args[0], flag.ExitOnError)
+	keyFile := fs.String("key", "./issuer.key", "Issuer signing key file")
+	dataDir := fs.String("data", "./data", "Node data directory (add, remove, list)")
+
+	var err error
+	switch args[0] {
+	case "keygen":
+		fs.Parse(args[1:])
+		err = issuerKeygen(*keyFile, log)
+
+	case "sign":
+		proofType := fs.String("type", "degree", "Proof type the credential is for (degree, identity, residency, age_over, disclosure)")
+		document := fs.String("document", "", "Credential document to sign")
+		claims := fs.String("claims", "", `Credential claims as JSON, e.g. {"student_id":"42","issue_date":"2019-06-30","valid_until":"2029-06-30","institution_id":"manipur-university"}`)
+		fs.Parse(args[1:])
+		err = issuerSign(*keyFile, *proofType, *document, *claims)
+
+	case "revoke":
+		proofHash := fs.String("proof", "", "Hash of the proof to revoke")
+		commitment := fs.String("commitment", "", "Document commitment whose proofs to revoke")
+		reason := fs.String("reason", "", "Why the credential is withdrawn")
+		fs.Parse(args[1:])
+		err = issuerRevoke(*keyFile, &zkp.Revocation{
+			ProofHash:          *proofHash,
+			DocumentCommitment: *commitment,
+			Reason:             *reason,
+		})
+
+	case "add":
+		name := fs.String("name", "", "Institution name, e.g. Manipur University")
+		publicKey := fs.String("public-key", "", "Institution public key, as printed by keygen")
+		fs.Parse(args[1:])
+		err = issuerAdd(*dataDir, *name, *publicKey, log)
+
+	case "remove":
+		publicKey := fs.String("public-key", "", "Institution public key")
+		fs.Parse(args[1:])
+		err = issuerRemove(*dataDir, *publicKey, log)
+
+	case "list":
+		fs.Parse(args[1:])
+		err = issuerList(*dataDir, log)
+
+	default:
+		fmt.Fprint(os.Stderr, issuerUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Errorf("issuer %s: %v", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func issuerKeygen(keyFile string, log *logrus.Logger) error {
+	if _, err := os.Stat(keyFile); err == nil {
+		return fmt.Errorf("%s already exists", keyFile)
+	}
+
+	key, err := zkp.GenerateIssuerKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key.Bytes())+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+
+	log.Infof("Issuer key written to %s", keyFile)
+	fmt.Println(hex.EncodeToString(key.Public().Bytes()))
+	return nil
+}
+
+func issuerSign(keyFile, proofType, document, rawClaims string) error {
+	if document == "" || rawClaims == "" {
+		return fmt.Errorf("-document and -claims are required")
+	}
+
+	rawKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := zkp.ParseIssuerPrivateKey(string(rawKey))
+	if err != nil {
+		return err
+	}
+
+	digest, err := hashFile(document)
+	if err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+	var claims zkp.Claims
+	if err := json.Unmarshal([]byte(rawClaims), &claims); err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+
+	pt, err := zkp.LookupProofType(proofType)
+	if err != nil {
+		return err
+	}
+	sig, err := zkp.SignCredential(key, proofType, digest, claims)
+	if err != nil {
+		return err
+	}
+
+	claims[zkp.ClaimIssuerPublicKey] = hex.EncodeToString(key.Public().Bytes())
+	claims[pt.SignatureClaimName()] = sig
+	out, err := json.MarshalIndent(claims, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// hashFile returns the SHA-256 of the file at path, read as a stream.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// issuerRevoke prints a signed revocation, ready to POST to
+// /zkp/revocations on any node.
+func issuerRevoke(keyFile string, r *zkp.Revocation) error {
+	rawKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := zkp.ParseIssuerPrivateKey(string(rawKey))
+	if err != nil {
+		return err
+	}
+
+	if err := zkp.SignRevocation(key, r); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// issuerAdd makes the node report proofs signed with publicKey as issued by
+// a registered institution. Trust is only granted here, by whoever runs the
+// node: check the key with the institution first.
+func issuerAdd(dataDir, name, publicKey string, log *logrus.Logger) error {
+	if name == "" || publicKey == "" {
+		return fmt.Errorf("-name and -public-key are required")
+	}
+	if _, err := zkp.ParseIssuerPublicKey(publicKey); err != nil {
+		return err
+	}
+	db, err := database.Open(dataDir, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	issuer := database.IssuerRecord{
+		PublicKey: strings.ToLower(strings.TrimSpace(publicKey)),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	if err := db.SaveIssuer(issuer); err != nil {
+		return err
+	}
+	log.Infof("Trusting %s as %s", issuer.PublicKey, issuer.Name)
+	return nil
+}
+
+func issuerRemove(dataDir, publicKey string, log *logrus.Logger) error {
+	if publicKey == "" {
+		return fmt.Errorf("-public-key is required")
+	}
+	db, err := database.Open(dataDir, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteIssuer(strings.ToLower(strings.TrimSpace(publicKey)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s is not a trusted issuer", publicKey)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("No longer trusting %s", publicKey)
+	return nil
+}
+
+func issuerList(dataDir string, log *logrus.Logger) error {
+	db, err := database.Open(dataDir, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	issuers, err := db.ListIssuers()
+	if err != nil {
+		return err
+	}
+	for _, iss := range issuers {
+		fmt.Printf("%s  %s  %s\n", iss.PublicKey, iss.CreatedAt.Format("2006-01-02"), iss.Name)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lairik-pulse/node/internal/p2p"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/sirupsen/logrus"
+)
+
+const keysUsage = `Usage: lairik-node keys <command> [flags]
+
+Commands:
+  list    list the keys in the key ring
+  export  write a key, still sealed under its passphrase, to a file
+  import  add an exported key to the key ring and install it on this node
+  delete  remove a key from the key ring
+
+The node fills the key ring with its vault, holder and peer keys, sealed
+under the vault passphrase, each time the vault is unlocked. Stop the node before
+importing or deleting keys. Passphrases are read from VAULT_PASSPHRASE, or
+else from standard input.
+`
+
+// runKeys implements the `keys` subcommands and returns the exit code.
+func runKeys(args []string, log *logrus.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, keysUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	dataDir := fs.String("data", "./data", "Node data directory")
+
+	var err error
+	switch args[0] {
+	case "list":
+		fs.Parse(args[1:])
+		err = keysList(*dataDir)
+
+	case "export":
+		name := fs.String("name", "", "Key to export (vault, holder, ...)")
+		out := fs.String("o", "", "Output file (default stdout)")
+		fs.Parse(args[1:])
+		err = keysExport(*dataDir, *name, *out, log)
+
+	case "import":
+		file := fs.String("file", "", "Exported key file")
+		name := fs.String("name", "", "Name to import the key as (default the name it was exported with)")
+		install := fs.Bool("install", true, "Make the key this node's vault, holder or peer key")
+		force := fs.Bool("force", false, "Replace a key of the same name, and this node's holder or peer key if it has another one")
+		fs.Parse(args[1:])
+		err = keysImport(*dataDir, *file, *name, *install, *force, log)
+
+	case "delete":
+		name := fs.String("name", "", "Key to delete")
+		fs.Parse(args[1:])
+		err = keysDelete(*dataDir, *name, log)
+
+	default:
+		fmt.Fprint(os.Stderr, keysUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Errorf("keys %s: %v", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func keyRingPath(dataDir string) string {
+	return filepath.Join(dataDir, "keys", "keyring.json")
+}
+
+func vaultKeystorePath(dataDir string) string {
+	return filepath.Join(dataDir, "vault", "keystore.json")
+}
+
+func holderKeyPath(dataDir string) string {
+	return filepath.Join(dataDir, "identity", "holder.pem")
+}
+
+func keysList(dataDir string) error {
+	ring, err := cryptopkg.OpenKeyRing(keyRingPath(dataDir))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tKEY\tCREATED\tLAST USED")
+	for _, k := range ring.List() {
+		key := k.KeyID
+		if k.PublicKey != "" {
+			key = k.PublicKey
+		}
+		used := "never"
+		if k.UsedAt != nil {
+			used = k.UsedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.Name, k.Type, key, k.CreatedAt.Local().Format(time.DateTime), used)
+	}
+	return w.Flush()
+}
+
+func keysExport(dataDir, name, out string, log *logrus.Logger) error {
+	if name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	ring, err := cryptopkg.OpenKeyRing(keyRingPath(dataDir))
+	if err != nil {
+		return err
+	}
+	// Check that the key opens before it is carried away.
+	passphrase, err := readPassphrase("Passphrase of key " + name + ": ")
+	if err != nil {
+		return err
+	}
+	key, _, err := ring.Open(name, passphrase)
+	if err != nil {
+		return err
+	}
+	clear(key)
+
+	data, err := ring.Export(name)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(out, data, 0600); err != nil {
+		return err
+	}
+	log.Infof("Exported key %s to %s; it opens with the passphrase it was sealed under", name, out)
+	return nil
+}
+
+// keysImport adds the key in file to the key ring and, with install, first
+// puts it to use: a vault master key seals this node's vault, a holder or
+// peer key replaces its identity.
+func keysImport(dataDir, file, name string, install, force bool, log *logrus.Logger) error {
+	if file == "" {
+		return fmt.Errorf("-file is required")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	ring, err := cryptopkg.OpenKeyRing(keyRingPath(dataDir))
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase("Passphrase of the exported key: ")
+	if err != nil {
+		return err
+	}
+
+	info, key, err := cryptopkg.OpenKeyFile(data, passphrase)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+	if name == "" {
+		name = info.Name
+	}
+	if _, ok := ring.Info(name); ok && !force {
+		return fmt.Errorf("the key ring already has a key %s; pass -name to import it as another, or -force to replace it", name)
+	}
+
+	if install {
+		if err := installKey(dataDir, info, key, passphrase, force, log); err != nil {
+			return err
+		}
+	}
+	if info, err = ring.Import(data, name, force); err != nil {
+		return err
+	}
+	log.Infof("Imported %s key %s", info.Type, info.Name)
+	return nil
+}
+
+func installKey(dataDir string, info cryptopkg.KeyInfo, key []byte, passphrase string, force bool, log *logrus.Logger) error {
+	switch info.Type {
+	case cryptopkg.KeyTypeVaultMaster:
+		vault, err := cryptopkg.OpenKeystore(vaultKeystorePath(dataDir))
+		if err != nil {
+			return err
+		}
+		err = vault.ImportMasterKey(key, passphrase)
+		if errors.Is(err, cryptopkg.ErrRotationInProgress) {
+			return fmt.Errorf("the vault is rotating its master key; unlock it to let the rotation finish, then import again")
+		}
+		if err != nil {
+			return err
+		}
+		log.Infof("Vault master key %s installed; unlock the vault with the same passphrase", info.KeyID)
+
+	case cryptopkg.KeyTypeHolder:
+		identity, err := cryptopkg.HolderIdentityFromSeed(key)
+		if err != nil {
+			return err
+		}
+		path := holderKeyPath(dataDir)
+		if _, err := os.Stat(path); err == nil && !force {
+			current, _, err := cryptopkg.LoadHolderIdentity(path)
+			if err != nil {
+				return err
+			}
+			if current.PublicKey() != identity.PublicKey() {
+				return fmt.Errorf("this node already has holder identity %s; pass -force to replace it", current.PublicKey())
+			}
+		}
+		if err := identity.Save(path); err != nil {
+			return err
+		}
+		log.Infof("Holder identity %s installed", identity.PublicKey())
+
+	case cryptopkg.KeyTypePeer:
+		priv, err := p2p.IdentityFromSeed(key)
+		if err != nil {
+			return err
+		}
+		id, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(p2p.IdentityPath(dataDir)); err == nil && !force {
+			current, _, err := p2p.LoadIdentity(dataDir)
+			if err != nil {
+				return err
+			}
+			if !current.Equals(priv) {
+				currentID, _ := peer.IDFromPrivateKey(current)
+				return fmt.Errorf("this node already has peer ID %s; pass -force to replace it", currentID)
+			}
+		}
+		if err := p2p.SaveIdentity(dataDir, priv); err != nil {
+			return err
+		}
+		log.Infof("Peer ID %s installed", id)
+
+	default:
+		return fmt.Errorf("cannot install a %s key", info.Type)
+	}
+	return nil
+}
+
+func keysDelete(dataDir, name string, log *logrus.Logger) error {
+	if name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	ring, err := cryptopkg.OpenKeyRing(keyRingPath(dataDir))
+	if err != nil {
+		return err
+	}
+	if err := ring.Delete(name); err != nil {
+		return err
+	}
+	log.Infof("Deleted key %s", name)
+	return nil
+}
+
+// readPassphrase takes the passphrase from VAULT_PASSPHRASE or, if unset,
+// a line of standard input.
+func readPassphrase(prompt string) (string, error) {
+	if p := os.Getenv("VAULT_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
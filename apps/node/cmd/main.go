@@ -6,32 +6,58 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/joho/godotenv"
 	"github.com/lairik-pulse/node/internal/api"
+	"github.com/lairik-pulse/node/internal/config"
 	"github.com/lairik-pulse/node/internal/database"
 	"github.com/lairik-pulse/node/internal/ipfs"
 	"github.com/lairik-pulse/node/internal/nlp"
 	"github.com/lairik-pulse/node/internal/p2p"
 	"github.com/lairik-pulse/node/internal/zkp"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
 	"github.com/sirupsen/logrus"
 )
 
+var defaults = config.Default().Node
+
 var (
-	_         = flag.String("config", "", "Path to config file")
-	port      = flag.Int("port", 8080, "API server port")
-	p2pPort   = flag.Int("p2p-port", 0, "P2P port (0 for random)")
-	dataDir   = flag.String("data", "./data", "Data directory")
+	configPath = flag.String("config", "", "Mesh config file, such as infrastructure/mesh-config/bootstrap.yaml (or CONFIG_FILE)")
+	port       = flag.Int("port", defaults.APIPort, "API server port")
+	p2pPort    = flag.Int("p2p-port", defaults.P2PPort, "P2P port (0 for random)")
+	dataDir    = flag.String("data", defaults.DataDir, "Data directory")
+	ipfsAPI    = flag.String("ipfs-api", defaults.IPFSAPI, "IPFS daemon API address")
+	workers    = flag.Int("proof-workers", defaults.ProofWorkers, "Proofs generated at once; each already uses every core")
+	maxAge     = flag.Duration("max-proof-age", defaults.MaxProofAge, "Reject proofs made longer ago than this, e.g. 720h (0 accepts any age)")
+	plonk      = flag.String("plonk", strings.Join(defaults.Plonk, ","), "Comma-separated proof types to prove with PLONK instead of Groth16")
+	srsPath    = flag.String("plonk-srs", defaults.PlonkSRS, "PLONK SRS file (default <data>/zkp/srs/bn254.srs)")
+	origins    = flag.String("frontend-origins", strings.Join(defaults.FrontendOrigins, ","), "Comma-separated web app origins allowed to call the API")
 )
 
 func main() {
-	flag.Parse()
-
 	log := logrus.New()
 	log.SetLevel(logrus.InfoLevel)
 	log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ceremony":
+			os.Exit(runCeremony(os.Args[2:], log))
+		case "issuer":
+			os.Exit(runIssuer(os.Args[2:], log))
+		case "verifier":
+			os.Exit(runVerifier(os.Args[2:], log))
+		case "keys":
+			os.Exit(runKeys(os.Args[2:], log))
+		case "peer":
+			os.Exit(runPeer(os.Args[2:], log))
+		}
+	}
+
+	flag.Parse()
 	log.Info("Starting Lairik-Pulse Node...")
 
 	// Load .env file
@@ -39,23 +65,33 @@ func main() {
 		log.Warn("No .env file found or unable to load it. Ignoring...")
 	}
 
-	// Override flags with env vars if present
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		if p, err := strconv.Atoi(envPort); err == nil {
-			*port = p
-		}
+	// ── Configuration: defaults, then the config file, env vars, flags ─
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
 	}
-	if envP2PPort := os.Getenv("P2P_PORT"); envP2PPort != "" {
-		if p, err := strconv.Atoi(envP2PPort); err == nil {
-			*p2pPort = p
-		}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.ApplyEnv(os.Getenv); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+	applyFlags(&cfg.Node)
+	warnings, err := cfg.Validate()
+	for _, w := range warnings {
+		log.Warn(w)
 	}
-	if envDataDir := os.Getenv("DATA_DIR"); envDataDir != "" {
-		*dataDir = envDataDir
+	if err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+	if path != "" {
+		log.Infof("Mesh %s (%s) configured from %s", cfg.Network.Name, cfg.Network.Region, path)
 	}
+	node := cfg.Node
 
 	// Create data directory
-	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+	if err := os.MkdirAll(node.DataDir, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
@@ -63,17 +99,60 @@ func main() {
 	defer cancel()
 
 	// ── Database ──────────────────────────────────────────────────────
-	db, err := database.Open(*dataDir, log)
+	db, err := database.Open(node.DataDir, log)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.Close()
 
+	// ── Vault keystore (sealed until the holder unlocks it) ───────────
+	vault, err := cryptopkg.OpenKeystore(vaultKeystorePath(node.DataDir))
+	if err != nil {
+		log.Fatalf("Failed to open vault keystore: %v", err)
+	}
+	if vault.Initialized() {
+		log.Infof("Vault is locked (master key %s); unlock it with POST /vault/unlock", vault.KeyID())
+	} else {
+		log.Info("Vault has no master key yet; the first POST /vault/unlock creates one")
+	}
+
+	// ── Holder identity ───────────────────────────────────────────────
+	identity, created, err := cryptopkg.LoadHolderIdentity(holderKeyPath(node.DataDir))
+	if err != nil {
+		log.Fatalf("Failed to load holder identity: %v", err)
+	}
+	if created {
+		log.Infof("Created holder identity %s", identity.PublicKey())
+	} else {
+		log.Infof("Holder identity %s", identity.PublicKey())
+	}
+
+	keys, err := cryptopkg.OpenKeyRing(keyRingPath(node.DataDir))
+	if err != nil {
+		log.Fatalf("Failed to open key ring: %v", err)
+	}
+
 	// ── P2P Node ─────────────────────────────────────────────────────
+	// The config was validated above, so the mesh settings all resolve.
+	bootstrap, _ := cfg.BootstrapPeers()
+	emergency, _ := cfg.EmergencyPeers()
+	swarmKey, _ := cfg.SwarmKey()
 	p2pNode, err := p2p.NewNode(ctx, p2p.Config{
-		Port:    *p2pPort,
-		DataDir: *dataDir,
-		Logger:  log,
+		Port:            node.P2PPort,
+		DataDir:         node.DataDir,
+		Logger:          log,
+		DB:              db,
+		BootstrapPeers:  bootstrap,
+		ProtectedPeers:  emergency,
+		ServiceTag:      cfg.MDNSServiceTag(),
+		DisableMDNS:     !cfg.Network.Discovery.MDNS.Enabled,
+		PrivateNetwork:  swarmKey,
+		LowConnections:  cfg.Resources.MaxPeers,
+		HighConnections: cfg.Resources.MaxConnections,
+		DialTimeout:     cfg.Resources.ConnectionTimeout.Duration(),
+		FloodSub:        !cfg.Routing.GossipSub.Enabled,
+		GossipSub:       cfg.GossipSubParams(),
+		SeenMessagesTTL: cfg.Routing.MessageTTL.Duration(),
 	})
 	if err != nil {
 		log.Fatalf("Failed to create P2P node: %v", err)
@@ -81,7 +160,8 @@ func main() {
 
 	// ── IPFS ─────────────────────────────────────────────────────────
 	ipfsNode, err := ipfs.NewNode(ctx, ipfs.Config{
-		RepoPath: fmt.Sprintf("%s/ipfs", *dataDir),
+		RepoPath: fmt.Sprintf("%s/ipfs", node.DataDir),
+		APIAddr:  node.IPFSAPI,
 		Logger:   log,
 	})
 	if err != nil {
@@ -89,10 +169,16 @@ func main() {
 		ipfsNode = nil // Ensuring it stays nil
 	}
 
-	// ── ZKP (compiles circuit at startup) ─────────────────────────────
+	// ── ZKP (loads or generates circuit keys at startup) ──────────────
+	backends := make(map[string]zkp.Backend)
+	for _, name := range node.Plonk {
+		backends[name] = zkp.BackendPlonk
+	}
 	zkpService, err := zkp.NewService(zkp.Config{
-		DataDir: *dataDir,
-		Logger:  log,
+		DataDir:  node.DataDir,
+		Logger:   log,
+		Backends: backends,
+		PlonkSRS: node.PlonkSRS,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create ZKP service: %v", err)
@@ -103,13 +189,22 @@ func main() {
 
 	// ── API Server ────────────────────────────────────────────────────
 	apiServer := api.NewServer(api.Config{
-		Port:     *port,
-		P2PNode:  p2pNode,
-		IPFSNode: ipfsNode,
-		ZKP:      zkpService,
-		DB:       db,
-		NLP:      nlpService,
-		Logger:   log,
+		Port:         node.APIPort,
+		P2PNode:      p2pNode,
+		IPFSNode:     ipfsNode,
+		ZKP:          zkpService,
+		DB:           db,
+		NLP:          nlpService,
+		Logger:       log,
+		Vault:        vault,
+		Identity:     identity,
+		Keys:         keys,
+		MaxProofAge:  node.MaxProofAge,
+		ProofWorkers: node.ProofWorkers,
+		Mesh:         cfg,
+
+		AllowedOrigins: node.FrontendOrigins,
+		APIToken:       node.APIToken,
 	})
 
 	// Start background services
@@ -136,10 +231,10 @@ func main() {
 	log.Info("═══════════════════════════════════════════════════")
 	log.Info("  Lairik-Pulse Node Ready")
 	log.Info("═══════════════════════════════════════════════════")
-	log.Infof("  API Server : http://localhost:%d", *port)
+	log.Infof("  API Server : http://localhost:%d", node.APIPort)
 	log.Infof("  P2P Node   : %s", p2pNode.ID())
-	log.Infof("  Database   : %s/pulse.db", *dataDir)
-	log.Infof("  WebSocket  : ws://localhost:%d/p2p/ws", *port)
+	log.Infof("  Database   : %s/pulse.db", node.DataDir)
+	log.Infof("  WebSocket  : ws://localhost:%d/p2p/ws", node.APIPort)
 	log.Info("═══════════════════════════════════════════════════")
 	log.Info("Press Ctrl+C to stop")
 
@@ -157,3 +252,29 @@ func main() {
 	log.Info("Shutdown complete")
 }
 
+// applyFlags overrides node with the flags given on the command line, which
+// take precedence over the config file and environment variables.
+func applyFlags(node *config.Node) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			node.APIPort = *port
+		case "p2p-port":
+			node.P2PPort = *p2pPort
+		case "data":
+			node.DataDir = *dataDir
+		case "ipfs-api":
+			node.IPFSAPI = *ipfsAPI
+		case "proof-workers":
+			node.ProofWorkers = *workers
+		case "max-proof-age":
+			node.MaxProofAge = *maxAge
+		case "plonk":
+			node.Plonk = config.SplitList(*plonk)
+		case "plonk-srs":
+			node.PlonkSRS = *srsPath
+		case "frontend-origins":
+			node.FrontendOrigins = config.SplitList(*origins)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lairik-pulse/node/internal/p2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/sirupsen/logrus"
+)
+
+const peerUsage = `Usage: lairik-node peer <command> [flags]
+
+Commands:
+  show    print this node's peer ID
+  rotate  replace the peer key, and so the peer ID (stop the node first)
+`
+
+// runPeer implements the `peer` subcommands and returns the exit code.
+func runPeer(args []string, log *logrus.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, peerUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("peer "+args[0], flag.ExitOnError)
+	dataDir := fs.String("data", "./data", "Node data directory")
+
+	var err error
+	switch args[0] {
+	case "show":
+		fs.Parse(args[1:])
+		err = peerShow(*dataDir, log)
+
+	case "rotate":
+		fs.Parse(args[1:])
+		err = peerRotate(*dataDir, log)
+
+	default:
+		fmt.Fprint(os.Stderr, peerUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Errorf("peer %s: %v", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func peerShow(dataDir string, log *logrus.Logger) error {
+	key, created, err := p2p.LoadIdentity(dataDir)
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Infof("Created peer key %s", p2p.IdentityPath(dataDir))
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+	return nil
+}
+
+// peerRotate gives the node a new peer ID. Peers forget the trust they
+// built with the old one, and trustees holding its recovery shares know it
+// by the old ID.
+func peerRotate(dataDir string, log *logrus.Logger) error {
+	oldID, newID, err := p2p.RotateIdentity(dataDir)
+	if err != nil {
+		return err
+	}
+	log.Infof("Peer ID changed from %s; the old key is kept in %s.prev until the node stores the new one in the key ring", oldID, p2p.IdentityPath(dataDir))
+	log.Info("Unlock the vault once to store the new key in the key ring")
+	fmt.Println(newID)
+	return nil
+}
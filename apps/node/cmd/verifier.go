@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	gnarklogger "github.com/consensys/gnark/logger"
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/zkp"
+	"github.com/sirupsen/logrus"
+)
+
+const verifierUsage = `Usage: lairik-node verifier <command> [flags]
+
+Commands:
+  export    write a Solidity contract that verifies proofs of one type
+  calldata  encode a stored or bundled proof as a call to that contract
+`
+
+// runVerifier implements the `verifier` subcommands and returns the exit code.
+func runVerifier(args []string, log *logrus.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, verifierUsage)
+		return 2
+	}
+
+	// The contract and calldata go to stdout, where gnark logs by default.
+	gnarklogger.SetOutput(os.Stderr)
+
+	fs := flag.NewFlagSet("verifier "+args[0], flag.ExitOnError)
+	dataDir := fs.String("data", "./data", "Node data directory")
+
+	var err error
+	switch args[0] {
+	case "export":
+		proofType := fs.String("type", "degree", "Proof type to verify (degree, identity, residency, age_over, disclosure)")
+		backend := fs.String("backend", string(zkp.BackendGroth16), "Backend the node proves this type with (groth16, plonk)")
+		out := fs.String("o", "", "Output file (default stdout)")
+		fs.Parse(args[1:])
+		err = verifierExport(*dataDir, *proofType, *backend, *out, log)
+
+	case "calldata":
+		proofHash := fs.String("proof", "", "Hash of a proof stored in -data")
+		bundleFile := fs.String("bundle", "", "Exported proof bundle, in any encoding")
+		fs.Parse(args[1:])
+		err = verifierCalldata(*dataDir, *proofHash, *bundleFile, log)
+
+	default:
+		fmt.Fprint(os.Stderr, verifierUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Errorf("verifier %s: %v", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func verifierExport(dataDir, proofType, backendName, out string, log *logrus.Logger) error {
+	backend, err := zkp.ParseBackend(backendName)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	vkFingerprint, err := zkp.ExportSolidityFromDisk(dataDir, proofType, backend, w)
+	if err != nil {
+		return err
+	}
+	log.Infof("Exported %s verifier for %s proofs, verifying key %s", backend, proofType, vkFingerprint[:16])
+	return nil
+}
+
+func verifierCalldata(dataDir, proofHash, bundleFile string, log *logrus.Logger) error {
+	var backend string
+	var proof, publicWitness []byte
+	switch {
+	case (proofHash == "") == (bundleFile == ""):
+		return fmt.Errorf("set exactly one of -proof and -bundle")
+
+	case bundleFile != "":
+		data, err := os.ReadFile(bundleFile)
+		if err != nil {
+			return err
+		}
+		bundle, err := zkp.ParseBundle(data)
+		if err != nil {
+			return err
+		}
+		backend, proof, publicWitness = bundle.Backend, bundle.Proof, bundle.PublicWitness
+
+	default:
+		db, err := database.Open(dataDir, log)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		record, err := db.GetProofByHash(proofHash)
+		if err != nil {
+			return err
+		}
+		backend, proof, publicWitness = record.Backend, record.ProofData, record.PublicWitness
+	}
+
+	call, err := zkp.EncodeSolidityCalldata(backend, proof, publicWitness)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(call)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/zkp"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+	"github.com/lairik-pulse/node/pkg/types"
+)
+
+// The holder identity signs a statement for every vault document, and every
+// proof bundle the node exports, so peers handed either can tell which
+// holder it came from.
+
+// documentStatementVersion is the statement format signDocument writes.
+// Version 1 embedded the metadata itself, custom claims included, so every
+// peer handed a statement learnt them; documents signed in it are signed
+// again.
+const documentStatementVersion = 2
+
+// documentStatement is what the holder signs for a document. It is stored
+// and handed out as the exact JSON that was signed. The metadata is only
+// committed to by its hash (see metadataHash), so the statement discloses
+// nothing the holder does not hand over alongside it.
+type documentStatement struct {
+	Version      int    `json:"v"`
+	DocumentID   string `json:"document_id"`
+	Hash         string `json:"hash"`
+	Commitment   string `json:"commitment,omitempty"`
+	MetadataHash string `json:"metadata_hash,omitempty"`
+	SignedAt     int64  `json:"signed_at"`
+}
+
+// metadataHash hashes the canonical JSON of meta: its fields in declaration
+// order and custom claims sorted by name, as encoding/json writes them.
+func metadataHash(meta *types.Metadata) (string, error) {
+	canonical, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	return cryptopkg.Hash(canonical), nil
+}
+
+// signDocument signs the current hash and metadata of document id.
+func (s *Server) signDocument(id string) (*database.DocumentSignatureRecord, error) {
+	doc, err := s.config.DB.GetDocument(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	st := documentStatement{
+		Version:    documentStatementVersion,
+		DocumentID: doc.ID,
+		Hash:       doc.Hash,
+		Commitment: doc.Commitment,
+		SignedAt:   now.Unix(),
+	}
+	meta, err := s.config.DB.GetDocumentMetadata(id)
+	if err == nil {
+		st.MetadataHash, err = metadataHash(&types.Metadata{Title: meta.Title, Description: meta.Description, Tags: meta.Tags, Custom: meta.Custom})
+		if err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	statement, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	r := database.DocumentSignatureRecord{
+		DocumentID: id,
+		PublicKey:  s.config.Identity.PublicKey(),
+		Statement:  string(statement),
+		Signature:  s.config.Identity.Sign(cryptopkg.DomainDocumentStatement, statement),
+		SignedAt:   now,
+	}
+	if err := s.config.DB.SaveDocumentSignature(r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// signUnsignedDocuments signs the documents stored before holder signatures,
+// signed by a previous holder key, such as those restored on a new device, or
+// signed in an older statement format.
+func (s *Server) signUnsignedDocuments() {
+	ids, err := s.config.DB.UnsignedDocumentIDs(s.config.Identity.PublicKey(), documentStatementVersion)
+	if err != nil {
+		s.config.Logger.Warnf("identity: %v", err)
+		return
+	}
+	signed := 0
+	for _, id := range ids {
+		if _, err := s.signDocument(id); err != nil {
+			s.config.Logger.Warnf("identity: failed to sign document %s: %v", id, err)
+			continue
+		}
+		signed++
+	}
+	if signed > 0 {
+		s.config.Logger.Infof("identity: signed %d document(s)", signed)
+	}
+}
+
+// signBundle attributes bundle to this node's holder.
+func (s *Server) signBundle(bundle *zkp.Bundle) error {
+	msg, err := bundle.SigningBytes()
+	if err != nil {
+		return err
+	}
+	bundle.Holder = &zkp.BundleHolder{
+		PublicKey: s.config.Identity.PublicKey(),
+		Signature: s.config.Identity.Sign(cryptopkg.DomainProofBundle, msg),
+	}
+	return nil
+}
+
+// bundleHolderInfo reports who a bundle claims to come from, and whether
+// their signature holds.
+func bundleHolderInfo(bundle *zkp.Bundle) gin.H {
+	if bundle.Holder == nil {
+		return nil
+	}
+	info := gin.H{"public_key": bundle.Holder.PublicKey, "verified": false}
+	msg, err := bundle.SigningBytes()
+	if err == nil {
+		err = cryptopkg.VerifyHolderSignature(bundle.Holder.PublicKey, cryptopkg.DomainProofBundle, msg, bundle.Holder.Signature)
+	}
+	if err != nil {
+		info["error"] = err.Error()
+		return info
+	}
+	info["verified"] = true
+	return info
+}
+
+func documentSignatureInfo(r *database.DocumentSignatureRecord) gin.H {
+	return gin.H{
+		"document_id": r.DocumentID,
+		"public_key":  r.PublicKey,
+		"statement":   r.Statement,
+		"signature":   r.Signature,
+		"signed_at":   r.SignedAt,
+	}
+}
+
+// ──────────────────────────────────────────────
+// Holder Identity
+// ──────────────────────────────────────────────
+
+func (s *Server) handleIdentity(c *gin.Context) {
+	resp := gin.H{
+		"public_key": s.config.Identity.PublicKey(),
+		"algorithm":  "ed25519",
+	}
+	if s.config.P2PNode != nil {
+		resp["peer_id"] = s.config.P2PNode.ID()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleVerifyHolderSignature checks a document statement a peer was handed
+// against the holder key it claims.
+func (s *Server) handleVerifyHolderSignature(c *gin.Context) {
+	var req struct {
+		PublicKey string `json:"public_key" binding:"required"`
+		Statement string `json:"statement" binding:"required"`
+		Signature []byte `json:"signature" binding:"required"`
+		// Metadata, if given, is checked against the statement's
+		// metadata hash.
+		Metadata *types.Metadata `json:"metadata"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := cryptopkg.VerifyHolderSignature(req.PublicKey, cryptopkg.DomainDocumentStatement, []byte(req.Statement), req.Signature)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "error": err.Error()})
+		return
+	}
+	var st documentStatement
+	if err := json.Unmarshal([]byte(req.Statement), &st); err != nil {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "error": "invalid statement: " + err.Error()})
+		return
+	}
+	if req.Metadata != nil {
+		hash, err := metadataHash(req.Metadata)
+		if err != nil || hash != st.MetadataHash {
+			c.JSON(http.StatusOK, gin.H{"valid": false, "error": "metadata does not match the statement"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":     true,
+		"own":       req.PublicKey == s.config.Identity.PublicKey(),
+		"statement": st,
+	})
+}
+
+func (s *Server) handleDocumentSignature(c *gin.Context) {
+	id := c.Param("id")
+	r, err := s.config.DB.GetDocumentSignature(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.config.DB.GetDocument(id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+			return
+		}
+		// Stored before holder signatures and not signed yet.
+		r, err = s.signDocument(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, documentSignatureInfo(r))
+}
@@ -0,0 +1,391 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lairik-pulse/node/internal/database"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+)
+
+// jobPollInterval bounds how long a queued job waits if a wake-up is missed,
+// e.g. for jobs requeued at startup.
+const jobPollInterval = 5 * time.Second
+
+// jobQueue runs proof jobs stored in SQLite on a bounded pool of workers, so
+// proving never runs inside a request and parallel requests don't fight over
+// the CPU. Jobs survive restarts: those interrupted while running are queued
+// again.
+type jobQueue struct {
+	s       *Server
+	workers int
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	waiters map[string]chan jobOutcome
+}
+
+// jobRequest is a generateRequest as stored with its job. Claims overrides
+// can be private, such as a birth date, so they are sealed under the vault
+// master key like the documents they stand in for; the rest are the
+// public parameters of the proof. The database forgets the request once
+// the job ends.
+type jobRequest struct {
+	generateRequest
+	SealedClaims []byte `json:"sealed_claims,omitempty"`
+}
+
+// jobClaimsLabel, followed by the job ID, binds sealed claims to their job.
+const jobClaimsLabel = "lairik-pulse-proof-job-claims:"
+
+// jobOutcome is delivered to a caller waiting on a job.
+type jobOutcome struct {
+	result gin.H
+	err    error
+}
+
+func newJobQueue(s *Server, workers int) *jobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobQueue{
+		s:       s,
+		workers: max(workers, 1),
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]context.CancelFunc),
+		waiters: make(map[string]chan jobOutcome),
+	}
+}
+
+// start requeues interrupted jobs and starts the workers.
+func (q *jobQueue) start() {
+	if n, err := q.s.config.DB.RequeueRunningProofJobs(); err != nil {
+		q.s.config.Logger.Warnf("Failed to requeue proof jobs: %v", err)
+	} else if n > 0 {
+		q.s.config.Logger.Infof("Requeued %d interrupted proof job(s)", n)
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// stop cancels running jobs and waits for the workers to exit.
+func (q *jobQueue) stop() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// enqueue stores a job for req. If wait is set, the returned channel
+// receives the job's outcome.
+func (q *jobQueue) enqueue(req generateRequest, wait bool) (*database.ProofJobRecord, <-chan jobOutcome, error) {
+	id := uuid.New().String()
+	stored := jobRequest{generateRequest: req}
+	stored.Claims = nil
+	if len(req.Claims) > 0 {
+		claims, err := json.Marshal(req.Claims)
+		if err != nil {
+			return nil, nil, err
+		}
+		stored.SealedClaims, err = q.s.config.Vault.Seal(claims, jobClaimsLabel+id)
+		clear(claims)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	job := &database.ProofJobRecord{
+		ID:         id,
+		DocumentID: req.DocumentID,
+		ProofType:  req.ProofType,
+		Request:    string(raw),
+		Status:     database.JobQueued,
+		CreatedAt:  time.Now(),
+	}
+
+	// Register the waiter first: a worker may pick the job up at once.
+	var outcome chan jobOutcome
+	if wait {
+		outcome = make(chan jobOutcome, 1)
+		q.mu.Lock()
+		q.waiters[job.ID] = outcome
+		q.mu.Unlock()
+	}
+	if err := q.s.config.DB.CreateProofJob(*job); err != nil {
+		q.deliver(job.ID, jobOutcome{err: err})
+		return nil, nil, err
+	}
+
+	q.s.broadcastJob(job.ID, database.JobQueued, "", nil)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, outcome, nil
+}
+
+// cancelJob cancels a queued or running job. It reports false if the job had
+// already finished.
+func (q *jobQueue) cancelJob(id string) (bool, error) {
+	cancelled, err := q.s.config.DB.CancelProofJob(id)
+	if err != nil || !cancelled {
+		return false, err
+	}
+
+	q.mu.Lock()
+	if cancel, ok := q.running[id]; ok {
+		cancel()
+	}
+	q.mu.Unlock()
+
+	q.s.broadcastJob(id, database.JobCancelled, "", nil)
+	q.deliver(id, jobOutcome{err: statusError(http.StatusConflict, fmt.Errorf("proof job was cancelled"))})
+	return true, nil
+}
+
+func (q *jobQueue) work() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := q.s.config.DB.ClaimProofJob()
+		switch {
+		case err == nil:
+			q.run(job)
+			continue
+		case !errors.Is(err, sql.ErrNoRows):
+			q.s.config.Logger.Warnf("Proof job queue: %v", err)
+		}
+
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *jobQueue) run(job *database.ProofJobRecord) {
+	ctx, cancel := context.WithCancel(q.ctx)
+	defer cancel()
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+	}()
+
+	q.s.broadcastJob(job.ID, database.JobRunning, "", nil)
+
+	var result gin.H
+	req, err := q.request(job)
+	if err == nil {
+		result, err = q.s.generateProof(ctx, req, func(stage string) bool {
+			running, err := q.s.config.DB.SetProofJobStage(job.ID, stage)
+			if err != nil {
+				q.s.config.Logger.Warnf("Proof job %s: %v", job.ID, err)
+				return false
+			}
+			if !running {
+				return false
+			}
+			q.s.broadcastJob(job.ID, database.JobRunning, stage, nil)
+			return true
+		})
+	}
+	if ctx.Err() != nil {
+		// Cancelled, or the node is stopping and the job will be requeued.
+		return
+	}
+
+	status, resultJSON, errMsg := database.JobDone, "", ""
+	if err != nil {
+		status, errMsg = database.JobFailed, err.Error()
+	} else if raw, merr := json.Marshal(result); merr == nil {
+		resultJSON = string(raw)
+	}
+	finished, ferr := q.s.config.DB.FinishProofJob(job.ID, status, resultJSON, errMsg)
+	if ferr != nil {
+		q.s.config.Logger.Warnf("Proof job %s: %v", job.ID, ferr)
+	}
+	if !finished {
+		return
+	}
+
+	event := result
+	if err != nil {
+		event = gin.H{"error": errMsg}
+	}
+	q.s.broadcastJob(job.ID, status, "", event)
+	q.deliver(job.ID, jobOutcome{result: result, err: err})
+}
+
+// request rebuilds the generateRequest job was queued with, opening its
+// claims overrides with the vault master key.
+func (q *jobQueue) request(job *database.ProofJobRecord) (generateRequest, error) {
+	var stored jobRequest
+	if err := json.Unmarshal([]byte(job.Request), &stored); err != nil {
+		return generateRequest{}, fmt.Errorf("invalid proof job request: %w", err)
+	}
+	req := stored.generateRequest
+	if stored.SealedClaims == nil {
+		return req, nil
+	}
+	claims, err := q.s.config.Vault.Open(stored.SealedClaims, jobClaimsLabel+job.ID)
+	if errors.Is(err, cryptopkg.ErrVaultLocked) {
+		return req, statusError(http.StatusLocked, err)
+	}
+	if err != nil {
+		return req, fmt.Errorf("failed to open proof job claims: %w", err)
+	}
+	defer clear(claims)
+	if err := json.Unmarshal(claims, &req.Claims); err != nil {
+		return req, fmt.Errorf("invalid proof job claims: %w", err)
+	}
+	return req, nil
+}
+
+// deliver hands the outcome of job id to its waiter, if any.
+func (q *jobQueue) deliver(id string, o jobOutcome) {
+	q.mu.Lock()
+	ch, ok := q.waiters[id]
+	delete(q.waiters, id)
+	q.mu.Unlock()
+	if ok {
+		ch <- o
+	}
+}
+
+// broadcastJob announces a job's progress over the WebSocket.
+func (s *Server) broadcastJob(id, status, stage string, fields gin.H) {
+	payload := gin.H{"job_id": id, "status": status}
+	if stage != "" {
+		payload["stage"] = stage
+	}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	s.broadcastWS(gin.H{
+		"type":      "proof_job",
+		"payload":   payload,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// httpError carries the status a failed request or job should report.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+func (e *httpError) Unwrap() error { return e.err }
+
+func statusError(status int, err error) error {
+	return &httpError{status: status, err: err}
+}
+
+// errorStatus is the HTTP status for err, 500 unless it carries one.
+func errorStatus(err error) int {
+	var he *httpError
+	if errors.As(err, &he) {
+		return he.status
+	}
+	return http.StatusInternalServerError
+}
+
+// jobInfo describes a proof job to clients; finished jobs include the
+// generate response or the error.
+func jobInfo(j *database.ProofJobRecord) gin.H {
+	info := gin.H{
+		"job_id":      j.ID,
+		"document_id": j.DocumentID,
+		"proof_type":  j.ProofType,
+		"status":      j.Status,
+		"status_url":  "/zkp/jobs/" + j.ID,
+		"created_at":  j.CreatedAt,
+	}
+	if j.Stage != "" && j.Status == database.JobRunning {
+		info["stage"] = j.Stage
+	}
+	if j.StartedAt != nil {
+		info["started_at"] = *j.StartedAt
+	}
+	if j.FinishedAt != nil {
+		info["finished_at"] = *j.FinishedAt
+	}
+	if j.Result != "" {
+		info["result"] = json.RawMessage(j.Result)
+	}
+	if j.Error != "" {
+		info["error"] = j.Error
+	}
+	return info
+}
+
+func (s *Server) handleGetJob(c *gin.Context) {
+	job, err := s.config.DB.GetProofJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, jobInfo(job))
+}
+
+func (s *Server) handleListJobs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	jobs, err := s.config.DB.ListProofJobs(c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := make([]gin.H, len(jobs))
+	for i := range jobs {
+		result[i] = jobInfo(&jobs[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": result, "count": len(result)})
+}
+
+func (s *Server) handleCancelJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := s.config.DB.GetProofJob(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	cancelled, err := s.jobs.cancelJob(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "job already finished or is saving its proof"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job_id": id, "status": database.JobCancelled})
+}
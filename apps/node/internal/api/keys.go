@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/lairik-pulse/node/internal/p2p"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+)
+
+// Key ring names of the node's own keys.
+const (
+	keyRingVault  = "vault"
+	keyRingHolder = "holder"
+	keyRingPeer   = "peer"
+)
+
+// storeKeys seals the vault master key, the holder key and the peer key in
+// the key ring under passphrase, the vault passphrase, so that `keys export`
+// can move them to another device. The API never serves the ring: keys
+// leave the node only through that command, run on the node's machine with
+// the passphrase. Keys the ring already holds are only sealed again
+// if reseal is set, as after a passphrase change. It runs in the background:
+// every key costs an Argon2id derivation.
+//
+// Calls are serialised, and each first checks that passphrase still opens
+// the vault, so an unlock racing a passphrase change or a rotation cannot
+// seal the keys under the old passphrase after the change sealed them under
+// the new one. A call with another passphrase than the last one written
+// seals every key again.
+func (s *Server) storeKeys(passphrase string, reseal bool) {
+	if s.config.Keys == nil {
+		return
+	}
+	go func() {
+		s.keysMu.Lock()
+		defer s.keysMu.Unlock()
+
+		master, err := s.config.Vault.ExportMasterKey(passphrase)
+		switch {
+		case errors.Is(err, cryptopkg.ErrRotationInProgress):
+			// Stored once the rotation is done.
+			return
+		case errors.Is(err, cryptopkg.ErrWrongPassphrase):
+			// The passphrase changed since; that change stores the keys.
+			return
+		case err != nil:
+			s.config.Logger.Warnf("keys: %v", err)
+			return
+		}
+		sealedUnder := sha256.Sum256([]byte(passphrase))
+		if s.keysSealedUnder != ([sha256.Size]byte{}) && s.keysSealedUnder != sealedUnder {
+			reseal = true
+		}
+
+		info, ok := s.config.Keys.Info(keyRingVault)
+		if !ok || info.KeyID != s.config.Vault.KeyID() || reseal {
+			err = s.config.Keys.Put(keyRingVault, cryptopkg.KeyTypeVaultMaster, master, passphrase)
+		}
+		clear(master)
+		if err != nil {
+			s.config.Logger.Warnf("keys: failed to store the vault master key: %v", err)
+			return
+		}
+		s.keysSealedUnder = sealedUnder
+
+		s.storeSeed(keyRingHolder, cryptopkg.KeyTypeHolder, s.config.Identity.Seed(), passphrase, reseal)
+
+		if s.config.P2PNode != nil {
+			seed, err := p2p.IdentitySeed(s.config.P2PNode.PrivateKey())
+			if err != nil {
+				s.config.Logger.Warnf("keys: %v", err)
+				return
+			}
+			if !s.storeSeed(keyRingPeer, cryptopkg.KeyTypePeer, seed, passphrase, reseal) {
+				return
+			}
+			// The ring now holds the new key after a `peer rotate`, so the
+			// plaintext copy of the old one need not stay on disk.
+			if err := s.config.P2PNode.DiscardPreviousIdentity(); err != nil {
+				s.config.Logger.Warnf("keys: %v", err)
+			}
+		}
+	}()
+}
+
+// storeSeed seals the Ed25519 seed under name unless the ring has it
+// already. It reports whether the ring holds the seed.
+func (s *Server) storeSeed(name string, typ cryptopkg.KeyType, seed []byte, passphrase string, reseal bool) bool {
+	defer clear(seed)
+	pub := hex.EncodeToString(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	if info, ok := s.config.Keys.Info(name); ok && info.PublicKey == pub && !reseal {
+		return true
+	}
+	if err := s.config.Keys.Put(name, typ, seed, passphrase); err != nil {
+		s.config.Logger.Warnf("keys: failed to store the %s key: %v", name, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,702 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/p2p"
+	"github.com/lairik-pulse/node/internal/zkp"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+	"github.com/lairik-pulse/node/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Social recovery: the holder splits the vault master key into N-of-M
+// shares and hands them to trusted peers over the mesh or on paper as QR
+// codes. Trustees also keep the holder's manifest, the list of documents
+// with their wrapped keys and CIDs, sealed under the master key. On a new
+// device, enough shares rebuild the master key, the manifest restores the
+// document records, and the documents themselves come back from IPFS.
+
+// manifestLabel binds sealed manifests to their purpose.
+const manifestLabel = "lairik-pulse-vault-manifest"
+
+// vaultManifest lists the documents recoverable with one master key.
+type vaultManifest struct {
+	KeyID     string             `json:"key_id"`
+	CreatedAt time.Time          `json:"created_at"`
+	Documents []manifestDocument `json:"documents"`
+}
+
+type manifestDocument struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Type           string          `json:"type"`
+	Size           int64           `json:"size"`
+	Hash           string          `json:"hash"`
+	CID            string          `json:"cid,omitempty"`
+	Commitment     string          `json:"commitment,omitempty"`
+	CommitmentSalt string          `json:"commitment_salt,omitempty"`
+	WrappedKey     []byte          `json:"wrapped_key"`
+	KeyID          string          `json:"key_id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Metadata       *types.Metadata `json:"metadata,omitempty"`
+}
+
+// sealManifest lists the documents under the current master key and seals
+// the list with it.
+func (s *Server) sealManifest() ([]byte, error) {
+	docs, err := s.config.DB.ListDocumentKeys()
+	if err != nil {
+		return nil, err
+	}
+	m := vaultManifest{KeyID: s.config.Vault.KeyID(), CreatedAt: time.Now().UTC()}
+	for _, d := range docs {
+		if d.WrappedKey == nil || d.KeyID != m.KeyID {
+			continue
+		}
+		md := manifestDocument{
+			ID:             d.ID,
+			Name:           d.Name,
+			Type:           d.Type,
+			Size:           d.Size,
+			Hash:           d.Hash,
+			CID:            d.CID,
+			Commitment:     d.Commitment,
+			CommitmentSalt: d.CommitmentSalt,
+			WrappedKey:     d.WrappedKey,
+			KeyID:          d.KeyID,
+			CreatedAt:      d.CreatedAt,
+		}
+		if meta, err := s.config.DB.GetDocumentMetadata(d.ID); err == nil {
+			md.Metadata = &types.Metadata{Title: meta.Title, Description: meta.Description, Tags: meta.Tags, Custom: meta.Custom}
+		}
+		m.Documents = append(m.Documents, md)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return s.config.Vault.Seal(data, manifestLabel)
+}
+
+// publishManifest sends the current manifest to the peers holding shares of
+// the current master key, so that a recovery also restores documents added
+// since the split. It is best effort; trustees out of reach get the next
+// one.
+func (s *Server) publishManifest() {
+	if s.config.P2PNode == nil || !s.config.Vault.Unlocked() {
+		return
+	}
+	keyID := s.config.Vault.KeyID()
+	peers, err := s.config.DB.RecoveryTrusteePeers(keyID)
+	if err != nil || len(peers) == 0 {
+		return
+	}
+	manifest, err := s.sealManifest()
+	if err != nil {
+		s.config.Logger.Warnf("recovery: failed to seal manifest: %v", err)
+		return
+	}
+	for _, p := range peers {
+		if err := s.config.P2PNode.SendRecoveryManifest(p, keyID, manifest); err != nil {
+			s.config.Logger.Debugf("recovery: manifest to %s: %v", p, err)
+		}
+	}
+}
+
+// restoreManifest opens a sealed manifest with the recovered master key and
+// adds the documents it lists that this node does not have. Their content is
+// fetched from IPFS in the background; documents never added to IPFS are
+// counted as unavailable.
+func (s *Server) restoreManifest(sealed []byte) (restored, skipped, unavailable int, err error) {
+	data, err := s.config.Vault.Open(sealed, manifestLabel)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var m vaultManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	var fetch []string
+	for _, md := range m.Documents {
+		if _, err := s.config.DB.GetDocument(md.ID); err == nil {
+			skipped++
+			continue
+		}
+		err := s.config.DB.AddDocument(database.DocumentRecord{
+			ID:             md.ID,
+			Name:           md.Name,
+			Type:           md.Type,
+			Size:           md.Size,
+			Hash:           md.Hash,
+			CID:            md.CID,
+			Encrypted:      true,
+			Commitment:     md.Commitment,
+			CommitmentSalt: md.CommitmentSalt,
+			WrappedKey:     md.WrappedKey,
+			KeyID:          md.KeyID,
+			CreatedAt:      md.CreatedAt,
+			UpdatedAt:      time.Now(),
+		})
+		if err != nil {
+			s.config.Logger.Warnf("recovery: document %s: %v", md.ID, err)
+			skipped++
+			continue
+		}
+		if md.Metadata != nil {
+			if err := s.config.DB.SaveDocumentMetadata(metadataRecord(md.ID, *md.Metadata)); err != nil {
+				s.config.Logger.Warnf("recovery: document %s metadata: %v", md.ID, err)
+			}
+		}
+		if _, err := s.signDocument(md.ID); err != nil {
+			s.config.Logger.Warnf("recovery: document %s signature: %v", md.ID, err)
+		}
+		if md.CID != "" {
+			fetch = append(fetch, md.ID)
+		} else {
+			unavailable++
+		}
+		restored++
+	}
+
+	go s.fetchDocuments(fetch)
+	return restored, skipped, unavailable, nil
+}
+
+// fetchDocuments caches the content of restored documents from IPFS.
+func (s *Server) fetchDocuments(ids []string) {
+	if s.config.IPFSNode == nil || len(ids) == 0 {
+		return
+	}
+	fetched := 0
+	for _, id := range ids {
+		doc, err := s.config.DB.GetDocument(id)
+		if err != nil {
+			continue
+		}
+		// documentContent stores the content once read to the end.
+		content, err := s.documentContent(doc)
+		if err == nil {
+			_, err = io.Copy(io.Discard, content)
+			content.Close()
+		}
+		if err != nil {
+			s.config.Logger.Debugf("recovery: fetch %s from IPFS: %v", doc.CID, err)
+			continue
+		}
+		fetched++
+	}
+	s.config.Logger.Infof("recovery: fetched %d of %d document(s) from IPFS", fetched, len(ids))
+}
+
+// recoverySession collects the shares a new device is given until there are
+// enough to rebuild the master key. It is kept in memory only, and takes
+// shares only while open: from the moment the user starts a recovery until
+// it succeeds or is cancelled. Shares from the mesh must come from one of
+// the trustees the user named.
+type recoverySession struct {
+	mu        sync.Mutex
+	open      bool
+	trustees  map[string]bool
+	shares    map[recoverySet]map[int]*cryptopkg.RecoveryShare // by index
+	manifests map[string][]byte                                // by master key ID
+}
+
+// recoverySet identifies the shares of one split. Shares claiming the same
+// set but another master key are kept apart, so they cannot mix.
+type recoverySet struct {
+	SetID, KeyID string
+}
+
+func newRecoverySession() *recoverySession {
+	return &recoverySession{
+		shares:    make(map[recoverySet]map[int]*cryptopkg.RecoveryShare),
+		manifests: make(map[string][]byte),
+	}
+}
+
+// start opens the session afresh, taking mesh shares from trustees only.
+func (r *recoverySession) start(trustees []string) {
+	r.reset()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.open = true
+	r.trustees = make(map[string]bool, len(trustees))
+	for _, t := range trustees {
+		r.trustees[t] = true
+	}
+}
+
+// add records a share, and the manifest it came with if any. from is the
+// trustee that returned it, or empty for a share the user entered. It
+// reports false if the session does not take the share.
+func (r *recoverySession) add(share *cryptopkg.RecoveryShare, manifest []byte, from string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.open || (from != "" && !r.trustees[from]) {
+		return false
+	}
+	key := recoverySet{SetID: share.SetID, KeyID: share.KeyID}
+	set := r.shares[key]
+	if set == nil {
+		set = make(map[int]*cryptopkg.RecoveryShare)
+		r.shares[key] = set
+	}
+	if old := set[share.Index]; old != nil {
+		clear(old.Value)
+	}
+	set[share.Index] = share
+	if len(manifest) > 0 {
+		r.manifests[share.KeyID] = manifest
+	}
+	return true
+}
+
+// sharesOf returns the shares collected for the split setID of master key
+// keyID.
+func (r *recoverySession) sharesOf(setID, keyID string) []cryptopkg.RecoveryShare {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var shares []cryptopkg.RecoveryShare
+	for _, sh := range r.shares[recoverySet{SetID: setID, KeyID: keyID}] {
+		shares = append(shares, *sh)
+	}
+	return shares
+}
+
+func (r *recoverySession) manifest(keyID string) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.manifests[keyID]
+}
+
+// reset forgets the collected shares and closes the session.
+func (r *recoverySession) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, set := range r.shares {
+		for _, sh := range set {
+			clear(sh.Value)
+		}
+	}
+	r.open = false
+	r.trustees = nil
+	r.shares = make(map[recoverySet]map[int]*cryptopkg.RecoveryShare)
+	r.manifests = make(map[string][]byte)
+}
+
+// status describes the session and its collected shares to clients, never
+// their values.
+func (r *recoverySession) status() gin.H {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sets := make([]gin.H, 0, len(r.shares))
+	for key, set := range r.shares {
+		var indexes []int
+		var first *cryptopkg.RecoveryShare
+		for i, sh := range set {
+			indexes = append(indexes, i)
+			first = sh
+		}
+		sort.Ints(indexes)
+		sets = append(sets, gin.H{
+			"set_id":       key.SetID,
+			"key_id":       key.KeyID,
+			"threshold":    first.Threshold,
+			"total":        first.Total,
+			"collected":    indexes,
+			"ready":        len(indexes) >= first.Threshold,
+			"has_manifest": r.manifests[key.KeyID] != nil,
+		})
+	}
+	trustees := make([]string, 0, len(r.trustees))
+	for t := range r.trustees {
+		trustees = append(trustees, t)
+	}
+	sort.Strings(trustees)
+	return gin.H{"open": r.open, "trustees": trustees, "sets": sets}
+}
+
+func (s *Server) receiveReturnedShare(rs *p2p.ReturnedShare) {
+	if !s.recovery.add(rs.Share, rs.Manifest, rs.From) {
+		clear(rs.Share.Value)
+		s.config.Logger.Warnf("recovery: dropped share of key %s from %s, who is not a trustee of an open recovery", rs.Share.KeyID, rs.From)
+		return
+	}
+	s.config.Logger.Infof("recovery: received share %d/%d of key %s from %s", rs.Share.Index, rs.Share.Total, rs.Share.KeyID, rs.From)
+	s.broadcastWS(gin.H{
+		"type":      "recovery_share_received",
+		"payload":   gin.H{"from": rs.From, "set_id": rs.Share.SetID, "key_id": rs.Share.KeyID, "index": rs.Share.Index},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ──────────────────────────────────────────────
+// Recovery: holder
+// ──────────────────────────────────────────────
+
+// handleRecoverySplit splits the master key into shares. Share i goes over
+// the mesh to trustees[i]; shares without a trustee are returned as text and
+// QR codes to hand out on paper.
+func (s *Server) handleRecoverySplit(c *gin.Context) {
+	var req struct {
+		Passphrase string   `json:"passphrase" binding:"required"`
+		Threshold  int      `json:"threshold" binding:"required"`
+		Total      int      `json:"total" binding:"required"`
+		Label      string   `json:"label"`
+		Trustees   []string `json:"trustees"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Trustees) > req.Total {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "more trustees than shares"})
+		return
+	}
+	if len(req.Trustees) > 0 && s.config.P2PNode == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "mesh is not available"})
+		return
+	}
+
+	shares, err := s.config.Vault.SplitMasterKey(req.Passphrase, req.Threshold, req.Total)
+	if err != nil {
+		status := vaultErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	// Each share's value is its own allocation.
+	defer func() {
+		for i := range shares {
+			clear(shares[i].Value)
+		}
+	}()
+
+	var manifest []byte
+	if len(req.Trustees) > 0 {
+		if manifest, err = s.sealManifest(); err != nil {
+			c.JSON(vaultErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	result := make([]gin.H, len(shares))
+	sent := 0
+	for i := range shares {
+		sh := &shares[i]
+		text, err := sh.Text()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		info := gin.H{"index": sh.Index}
+
+		if i < len(req.Trustees) {
+			peerID := req.Trustees[i]
+			info["peer_id"] = peerID
+			err := s.config.P2PNode.DepositRecoveryShare(peerID, text, req.Label, manifest)
+			if err == nil {
+				err = s.config.DB.AddRecoveryTrustee(database.RecoveryTrusteeRecord{
+					SetID: sh.SetID, Index: sh.Index, KeyID: sh.KeyID, PeerID: peerID, SentAt: time.Now(),
+				})
+			}
+			if err == nil {
+				info["sent"] = true
+				sent++
+				result[i] = info
+				continue
+			}
+			// Hand the share out on paper instead.
+			info["sent"] = false
+			info["error"] = err.Error()
+		}
+
+		info["share"] = text
+		if png, err := zkp.QRPNG(text, 512); err == nil {
+			info["qr_png"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+		}
+		result[i] = info
+	}
+
+	s.config.Logger.Infof("recovery: split master key %s into %d-of-%d shares, %d sent to trustees",
+		shares[0].KeyID, req.Threshold, req.Total, sent)
+	c.JSON(http.StatusOK, gin.H{
+		"set_id":    shares[0].SetID,
+		"key_id":    shares[0].KeyID,
+		"threshold": req.Threshold,
+		"total":     req.Total,
+		"shares":    result,
+	})
+}
+
+// handleRecoveryManifest downloads the sealed manifest, for keeping with
+// paper shares when no trustee holds it.
+func (s *Server) handleRecoveryManifest(c *gin.Context) {
+	manifest, err := s.sealManifest()
+	if err != nil {
+		c.JSON(vaultErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="vault-manifest-%s.bin"`, s.config.Vault.KeyID()))
+	c.Data(http.StatusOK, "application/octet-stream", manifest)
+}
+
+// ──────────────────────────────────────────────
+// Recovery: trustee
+// ──────────────────────────────────────────────
+
+func (s *Server) handleListHeldShares(c *gin.Context) {
+	held, err := s.config.DB.ListHeldShares()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]gin.H, len(held))
+	for i, h := range held {
+		result[i] = gin.H{
+			"id":            h.ID,
+			"owner_peer_id": h.OwnerPeerID,
+			"label":         h.Label,
+			"key_id":        h.KeyID,
+			"set_id":        h.SetID,
+			"index":         h.Index,
+			"threshold":     h.Threshold,
+			"total":         h.Total,
+			"has_manifest":  h.Manifest != nil,
+			"accepted":      h.Accepted,
+			"received_at":   h.ReceivedAt,
+			"updated_at":    h.UpdatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"shares": result, "count": len(result)})
+}
+
+// handleReturnHeldShare sends a held share and manifest to the holder's new
+// device. The trustee's user must be sure peer_id really is the holder.
+func (s *Server) handleReturnHeldShare(c *gin.Context) {
+	var req struct {
+		PeerID string `json:"peer_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h, ok := s.heldShare(c)
+	if !ok {
+		return
+	}
+	if !h.Accepted {
+		c.JSON(http.StatusConflict, gin.H{"error": "share has not been accepted"})
+		return
+	}
+	if s.config.P2PNode == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "mesh is not available"})
+		return
+	}
+	if err := s.config.P2PNode.ReturnRecoveryShare(req.PeerID, h.Share, h.Manifest); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	s.config.Logger.Infof("recovery: returned share %s to %s", h.ID, req.PeerID)
+	c.JSON(http.StatusOK, gin.H{"returned": h.ID, "peer_id": req.PeerID})
+}
+
+// handleHeldShareQR renders a held share as a QR code for the holder to
+// scan. The manifest does not fit; the holder gets it from another trustee
+// or a saved copy.
+func (s *Server) handleHeldShareQR(c *gin.Context) {
+	h, ok := s.heldShare(c)
+	if !ok {
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "512"))
+	if err != nil || size < 64 || size > 4096 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 64 and 4096 pixels"})
+		return
+	}
+	png, err := zkp.QRPNG(h.Share, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// handleAcceptHeldShare agrees to keep a share a peer deposited. Until then
+// the deposit only counts against that peer's pending allowance. Declining
+// is deleting it.
+func (s *Server) handleAcceptHeldShare(c *gin.Context) {
+	h, ok := s.heldShare(c)
+	if !ok {
+		return
+	}
+	if err := s.config.DB.AcceptHeldShare(h.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.config.Logger.Infof("recovery: accepted share %s from %s", h.ID, h.OwnerPeerID)
+	c.JSON(http.StatusOK, gin.H{"accepted": h.ID, "owner_peer_id": h.OwnerPeerID, "label": h.Label})
+}
+
+func (s *Server) handleDeleteHeldShare(c *gin.Context) {
+	h, ok := s.heldShare(c)
+	if !ok {
+		return
+	}
+	if err := s.config.DB.DeleteHeldShare(h.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": h.ID})
+}
+
+func (s *Server) heldShare(c *gin.Context) (*database.HeldShareRecord, bool) {
+	h, err := s.config.DB.GetHeldShare(c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "share not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return h, true
+}
+
+// ──────────────────────────────────────────────
+// Recovery: new device
+// ──────────────────────────────────────────────
+
+func (s *Server) handleRecoveryStatus(c *gin.Context) {
+	resp := s.recovery.status()
+	if s.config.P2PNode != nil {
+		// Trustees return shares to this peer ID.
+		resp["peer_id"] = s.config.P2PNode.ID()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleStartRecovery opens a recovery session, dropping any shares
+// collected before. Trustees lists the peer IDs that may return shares over
+// the mesh; shares from any other peer are refused.
+func (s *Server) handleStartRecovery(c *gin.Context) {
+	var req struct {
+		Trustees []string `json:"trustees"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, t := range req.Trustees {
+		if _, err := peer.Decode(t); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid trustee peer ID %q", t)})
+			return
+		}
+	}
+	s.recovery.start(req.Trustees)
+	if s.config.P2PNode != nil {
+		s.config.P2PNode.ExpectReturns(req.Trustees)
+	}
+	s.handleRecoveryStatus(c)
+}
+
+// handleCancelRecovery closes the recovery session and forgets its shares.
+func (s *Server) handleCancelRecovery(c *gin.Context) {
+	s.endRecovery()
+	c.JSON(http.StatusOK, gin.H{"open": false})
+}
+
+func (s *Server) endRecovery() {
+	if s.config.P2PNode != nil {
+		s.config.P2PNode.StopReturns()
+	}
+	s.recovery.reset()
+}
+
+// handleAddRecoveryShare adds a share scanned or typed in from paper.
+func (s *Server) handleAddRecoveryShare(c *gin.Context) {
+	var req struct {
+		Share string `json:"share" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	share, err := cryptopkg.ParseRecoveryShare(req.Share)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.recovery.add(share, nil, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "no recovery in progress; start one with POST /vault/recover/start"})
+		return
+	}
+	c.JSON(http.StatusOK, s.recovery.status())
+}
+
+// handleRecover rebuilds the master key from the shares collected for the
+// split the user chose from the recovery status, seals it under a new
+// passphrase and restores the documents in the manifest: one a trustee
+// returned, or one given as base64 in the request.
+func (s *Server) handleRecover(c *gin.Context) {
+	var req struct {
+		SetID         string `json:"set_id" binding:"required"`
+		KeyID         string `json:"key_id" binding:"required"`
+		NewPassphrase string `json:"new_passphrase" binding:"required"`
+		Manifest      []byte `json:"manifest"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shares := s.recovery.sharesOf(req.SetID, req.KeyID)
+	if len(shares) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no recovery shares collected for that set and key"})
+		return
+	}
+	if err := s.config.Vault.Recover(shares, req.NewPassphrase); err != nil {
+		status := vaultErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	keyID := s.config.Vault.KeyID()
+	s.config.Logger.Infof("recovery: rebuilt master key %s from %d shares", keyID, len(shares))
+	s.storeKeys(req.NewPassphrase, true)
+
+	resp := gin.H{"key_id": keyID, "unlocked": true}
+	manifest := req.Manifest
+	if manifest == nil {
+		manifest = s.recovery.manifest(keyID)
+	}
+	if manifest != nil {
+		restored, skipped, unavailable, err := s.restoreManifest(manifest)
+		if err != nil {
+			resp["manifest_error"] = err.Error()
+		} else {
+			resp["restored"], resp["skipped"], resp["unavailable"] = restored, skipped, unavailable
+		}
+	}
+	s.endRecovery()
+	c.JSON(http.StatusOK, resp)
+}
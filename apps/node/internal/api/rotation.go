@@ -0,0 +1,272 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lairik-pulse/node/internal/database"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+)
+
+// A vault rotation moves every document key from the retiring master key to
+// a new one, for when the passphrase or the keystore may have leaked. Each
+// document moves in a single database update, and both master keys stay in
+// the keystore until the last one has, so the rotation can stop at any point
+// (crash, lock) and carry on after the next unlock.
+
+// startRotation runs the rotation r in the background unless one already is.
+func (s *Server) startRotation(r *database.VaultRotationRecord) {
+	s.rotationMu.Lock()
+	defer s.rotationMu.Unlock()
+	if s.rotating {
+		return
+	}
+	s.rotating = true
+
+	go func() {
+		s.runRotation(r)
+		s.rotationMu.Lock()
+		s.rotating = false
+		s.rotationMu.Unlock()
+	}()
+}
+
+func (s *Server) runRotation(r *database.VaultRotationRecord) {
+	log := s.config.Logger
+	done := r.Done
+	var lastErr error
+	for {
+		ids, err := s.config.DB.DocumentIDsByKey(r.FromKeyID)
+		if err != nil {
+			log.Warnf("vault: rotation %s: %v", r.ID, err)
+			return
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		total := done + len(ids)
+		failed := 0
+		for _, id := range ids {
+			err := s.rotateDocument(r, id)
+			if errors.Is(err, cryptopkg.ErrVaultLocked) {
+				log.Infof("vault: rotation %s paused at %d/%d, the vault was locked", r.ID, done, total)
+				s.config.DB.SetVaultRotationProgress(r.ID, total, done, "")
+				return
+			}
+			if err != nil {
+				log.Warnf("vault: rotation %s: document %s: %v", r.ID, id, err)
+				lastErr = fmt.Errorf("document %s: %w", id, err)
+				failed++
+				continue
+			}
+			done++
+			if err := s.config.DB.SetVaultRotationProgress(r.ID, total, done, ""); err != nil {
+				log.Warnf("vault: rotation %s: %v", r.ID, err)
+			}
+			s.broadcastRotation(r.ID, database.RotationRunning, total, done)
+		}
+
+		// Documents that failed keep the retiring key needed to open them;
+		// the rotation stays running and retries them after the next unlock.
+		if failed > 0 {
+			s.config.DB.SetVaultRotationProgress(r.ID, total, done, lastErr.Error())
+			log.Warnf("vault: rotation %s left %d document(s) on key %s; unlock again to retry", r.ID, failed, r.FromKeyID)
+			return
+		}
+	}
+
+	if err := s.config.Vault.FinishRotation(); err != nil {
+		log.Warnf("vault: rotation %s: %v", r.ID, err)
+		return
+	}
+	if err := s.config.DB.FinishVaultRotation(r.ID, database.RotationDone, ""); err != nil {
+		log.Warnf("vault: rotation %s: %v", r.ID, err)
+	}
+	log.Infof("vault: rotation %s done, %d document(s) moved to key %s", r.ID, done, r.ToKeyID)
+	s.broadcastRotation(r.ID, database.RotationDone, done, done)
+}
+
+// rotateDocument moves one document off the retiring master key: its key is
+// rewrapped, or with r.Reencrypt replaced along with the content.
+func (s *Server) rotateDocument(r *database.VaultRotationRecord, id string) error {
+	doc, err := s.config.DB.GetDocument(id)
+	if err != nil {
+		return err
+	}
+	if doc.KeyID != r.FromKeyID {
+		return nil
+	}
+
+	if !r.Reencrypt {
+		wrapped, keyID, err := s.config.Vault.RewrapDocumentKey(doc.ID, doc.KeyID, doc.WrappedKey)
+		if err != nil {
+			return err
+		}
+		_, err = s.config.DB.RewrapDocumentKey(doc.ID, r.FromKeyID, wrapped, keyID)
+		return err
+	}
+
+	content, err := s.documentContent(doc)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	plaintext, err := s.openDocument(doc, content)
+	if err != nil {
+		return err
+	}
+	return s.replaceDocumentContent(doc, plaintext)
+}
+
+// resumeRotation reconciles the latest rotation with the keystore after an
+// unlock, and resumes it if it was interrupted. It returns the rotation if
+// one is running.
+func (s *Server) resumeRotation() *database.VaultRotationRecord {
+	log := s.config.Logger
+	retiring := s.config.Vault.RetiringKeyID()
+
+	r, err := s.config.DB.LatestVaultRotation()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Warnf("vault: %v", err)
+		return nil
+	}
+
+	if r != nil && r.Status == database.RotationRunning {
+		switch {
+		case retiring == r.FromKeyID && s.config.Vault.KeyID() == r.ToKeyID:
+			log.Infof("vault: resuming rotation %s at %d/%d", r.ID, r.Done, r.Total)
+			s.startRotation(r)
+			return r
+		case retiring == "" && s.config.Vault.KeyID() == r.ToKeyID:
+			// The node stopped between dropping the retiring key and
+			// recording that the rotation was done.
+			s.config.DB.FinishVaultRotation(r.ID, database.RotationDone, "")
+			return nil
+		default:
+			s.config.DB.FinishVaultRotation(r.ID, database.RotationFailed, "interrupted before the new master key was stored")
+			r = nil
+		}
+	}
+
+	if retiring == "" {
+		return nil
+	}
+	// A keystore mid-rotation without a running record, e.g. a restored
+	// backup: move the keys over.
+	r = &database.VaultRotationRecord{
+		ID:        uuid.New().String(),
+		FromKeyID: retiring,
+		ToKeyID:   s.config.Vault.KeyID(),
+		Status:    database.RotationRunning,
+		StartedAt: time.Now(),
+	}
+	r.UpdatedAt = r.StartedAt
+	if err := s.config.DB.CreateVaultRotation(*r); err != nil {
+		log.Warnf("vault: %v", err)
+		return nil
+	}
+	s.startRotation(r)
+	return r
+}
+
+func (s *Server) broadcastRotation(id, status string, total, done int) {
+	s.broadcastWS(gin.H{
+		"type":      "vault_rotation",
+		"payload":   gin.H{"rotation_id": id, "status": status, "total": total, "done": done},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// rotationInfo describes a rotation and its progress to clients.
+func rotationInfo(r *database.VaultRotationRecord) gin.H {
+	info := gin.H{
+		"rotation_id": r.ID,
+		"from_key_id": r.FromKeyID,
+		"to_key_id":   r.ToKeyID,
+		"reencrypt":   r.Reencrypt,
+		"status":      r.Status,
+		"total":       r.Total,
+		"done":        r.Done,
+		"started_at":  r.StartedAt,
+		"updated_at":  r.UpdatedAt,
+	}
+	if r.Error != "" {
+		info["error"] = r.Error
+	}
+	if r.FinishedAt != nil {
+		info["finished_at"] = r.FinishedAt
+	}
+	return info
+}
+
+// ──────────────────────────────────────────────
+// Vault Rotation
+// ──────────────────────────────────────────────
+
+// handleVaultRotate replaces the master key and seals the new one under a new
+// passphrase, then moves every document key over in the background. With
+// "reencrypt" every document also gets a new key and is encrypted again.
+func (s *Server) handleVaultRotate(c *gin.Context) {
+	var req struct {
+		Passphrase    string `json:"passphrase" binding:"required"`
+		NewPassphrase string `json:"new_passphrase" binding:"required"`
+		Reencrypt     bool   `json:"reencrypt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r := &database.VaultRotationRecord{
+		ID:        uuid.New().String(),
+		Reencrypt: req.Reencrypt,
+		Status:    database.RotationRunning,
+		StartedAt: time.Now(),
+	}
+	r.UpdatedAt = r.StartedAt
+	recorded := false
+	err := s.config.Vault.BeginRotation(req.Passphrase, req.NewPassphrase, func(from, to string) error {
+		ids, err := s.config.DB.DocumentIDsByKey(from)
+		if err != nil {
+			return err
+		}
+		r.FromKeyID, r.ToKeyID, r.Total = from, to, len(ids)
+		if err := s.config.DB.CreateVaultRotation(*r); err != nil {
+			return err
+		}
+		recorded = true
+		return nil
+	})
+	if err != nil {
+		if recorded {
+			s.config.DB.FinishVaultRotation(r.ID, database.RotationFailed, err.Error())
+		}
+		c.JSON(vaultErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	s.config.Logger.Infof("vault: rotating from key %s to %s (%d documents)", r.FromKeyID, r.ToKeyID, r.Total)
+	s.storeKeys(req.NewPassphrase, true)
+	s.startRotation(r)
+	c.JSON(http.StatusAccepted, rotationInfo(r))
+}
+
+// handleVaultRotation reports the progress of the latest rotation.
+func (s *Server) handleVaultRotation(c *gin.Context) {
+	r, err := s.config.DB.LatestVaultRotation()
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the vault was never rotated"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rotationInfo(r))
+}
@@ -2,9 +2,18 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,12 +22,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/lairik-pulse/node/internal/config"
 	"github.com/lairik-pulse/node/internal/database"
 	"github.com/lairik-pulse/node/internal/ipfs"
 	"github.com/lairik-pulse/node/internal/nlp"
 	"github.com/lairik-pulse/node/internal/p2p"
 	"github.com/lairik-pulse/node/internal/zkp"
 	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+	"github.com/lairik-pulse/node/pkg/types"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/sirupsen/logrus"
 )
@@ -32,6 +43,25 @@ type Config struct {
 	DB       *database.DB
 	NLP      *nlp.Service
 	Logger   *logrus.Logger
+	// Vault holds the master key that wraps each document's key.
+	Vault *cryptopkg.Keystore
+	// Identity signs the holder's documents and exported proofs.
+	Identity *cryptopkg.HolderIdentity
+	// Mesh describes the mesh the node belongs to, from bootstrap.yaml.
+	Mesh *config.Config
+	// Keys, if set, keeps a copy of the vault and holder keys sealed under
+	// the vault passphrase.
+	Keys *cryptopkg.KeyRing
+	// MaxProofAge, if set, fails verification of proofs made longer ago.
+	MaxProofAge time.Duration
+	// ProofWorkers bounds how many proofs are generated at once.
+	ProofWorkers int
+	// AllowedOrigins are the web app origins whose pages may call the API
+	// and open its WebSocket.
+	AllowedOrigins []string
+	// APIToken, if set, admits requests from other machines to the vault
+	// and recovery routes as "Authorization: Bearer <token>".
+	APIToken string
 }
 
 // Server is the HTTP/WebSocket server.
@@ -40,9 +70,20 @@ type Server struct {
 	router    *gin.Engine
 	server    *http.Server
 	upgrader  websocket.Upgrader
-	enc       *cryptopkg.EncryptionService
+	legacyEnc *cryptopkg.EncryptionService
 	wsClients map[string]chan interface{}
 	wsMu      sync.RWMutex
+	jobs      *jobQueue
+
+	rotationMu sync.Mutex
+	rotating   bool
+
+	// keysMu serialises storeKeys; keysSealedUnder is the SHA-256 of the
+	// passphrase it last sealed the key ring under, zero until it has.
+	keysMu          sync.Mutex
+	keysSealedUnder [sha256.Size]byte
+
+	recovery *recoverySession
 }
 
 // NewServer creates and configures the server.
@@ -50,21 +91,20 @@ func NewServer(cfg Config) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	router.Use(corsMiddleware(cfg.AllowedOrigins))
 
 	s := &Server{
-		config: cfg,
-		router: router,
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				origin := r.Header.Get("Origin")
-				return origin == "http://localhost:3000" || origin == "http://localhost:3001" || true // Explicitly accept Next.js dev domains, true for wildcard dev
-			},
-		},
-		enc:       cryptopkg.NewEncryptionService("lairik-pulse-vault-key"),
+		config:    cfg,
+		router:    router,
+		legacyEnc: cryptopkg.NewEncryptionService(legacyVaultPassword),
 		wsClients: make(map[string]chan interface{}),
+		recovery:  newRecoverySession(),
 	}
 
+	s.upgrader.CheckOrigin = func(r *http.Request) bool {
+		return s.originAllowed(r.Header.Get("Origin"))
+	}
+	s.jobs = newJobQueue(s, cfg.ProofWorkers)
 	s.setupRoutes()
 	return s
 }
@@ -76,6 +116,7 @@ func (s *Server) setupRoutes() {
 	// P2P
 	s.router.GET("/p2p/status", s.handleP2PStatus)
 	s.router.GET("/p2p/peers", s.handleP2PPeers)
+	s.router.GET("/p2p/emergency", s.handleEmergencyNodes)
 	s.router.GET("/p2p/ws", s.handleP2PWebSocket)
 	s.router.GET("/ws", s.handleP2PWebSocket) // Alias for convenience
 
@@ -84,14 +125,60 @@ func (s *Server) setupRoutes() {
 	s.router.GET("/ipfs/get/:cid", s.handleIPFSGet)
 
 	// ZKP
-	s.router.POST("/zkp/generate", s.handleZKPGenerate)
 	s.router.POST("/zkp/verify", s.handleZKPVerify)
-
-	// Document vault
-	s.router.POST("/vault/documents", s.handleAddDocument)
-	s.router.GET("/vault/documents", s.handleListDocuments)
-	s.router.GET("/vault/documents/:id", s.handleGetDocument)
-	s.router.DELETE("/vault/documents/:id", s.handleDeleteDocument)
+	s.router.POST("/zkp/verify/batch", s.handleZKPVerifyBatch)
+	s.router.GET("/zkp/types", s.handleListProofTypes)
+	s.router.POST("/zkp/import", s.handleZKPImport)
+	s.router.POST("/zkp/qr/decode", s.handleZKPQRDecode)
+	s.router.GET("/zkp/verifier/:type", s.handleSolidityVerifier)
+	s.router.GET("/zkp/issuers", s.handleListIssuers)
+	s.router.GET("/zkp/revocations", s.handleListRevocations)
+
+	// Proving reads vault documents, and jobs and proofs describe them, so
+	// these are local too
+	zkp := s.router.Group("/zkp", s.requireLocal)
+	zkp.POST("/generate", s.handleZKPGenerate)
+	zkp.GET("/jobs", s.handleListJobs)
+	zkp.GET("/jobs/:id", s.handleGetJob)
+	zkp.DELETE("/jobs/:id", s.handleCancelJob)
+	zkp.GET("/export/:hash", s.handleZKPExport)
+	zkp.GET("/qr/:hash", s.handleZKPQR)
+	zkp.GET("/calldata/:hash", s.handleSolidityCalldata)
+	zkp.POST("/revocations", s.handleRevoke)
+
+	// Document vault, only for this machine or API token holders
+	vault := s.router.Group("/vault", s.requireLocal)
+	vault.GET("/status", s.handleVaultStatus)
+	vault.POST("/unlock", s.handleVaultUnlock)
+	vault.POST("/lock", s.handleVaultLock)
+	vault.POST("/passphrase", s.handleVaultPassphrase)
+	vault.POST("/rotate", s.handleVaultRotate)
+	vault.GET("/rotation", s.handleVaultRotation)
+	vault.POST("/documents", s.handleAddDocument)
+	vault.GET("/documents", s.handleListDocuments)
+	vault.GET("/documents/:id", s.handleGetDocument)
+	vault.DELETE("/documents/:id", s.handleDeleteDocument)
+	vault.PUT("/documents/:id/metadata", s.handleUpdateDocumentMetadata)
+
+	// Holder identity
+	s.router.GET("/identity", s.handleIdentity)
+	s.router.POST("/identity/verify", s.handleVerifyHolderSignature)
+	vault.GET("/documents/:id/signature", s.handleDocumentSignature)
+
+	// Social recovery
+	vault.POST("/recovery/split", s.handleRecoverySplit)
+	vault.GET("/recovery/manifest", s.handleRecoveryManifest)
+	vault.GET("/recover", s.handleRecoveryStatus)
+	vault.POST("/recover/start", s.handleStartRecovery)
+	vault.DELETE("/recover", s.handleCancelRecovery)
+	vault.POST("/recover/shares", s.handleAddRecoveryShare)
+	vault.POST("/recover", s.handleRecover)
+	held := s.router.Group("/recovery/held", s.requireLocal)
+	held.GET("", s.handleListHeldShares)
+	held.POST("/:id/accept", s.handleAcceptHeldShare)
+	held.POST("/:id/return", s.handleReturnHeldShare)
+	held.GET("/:id/qr", s.handleHeldShareQR)
+	held.DELETE("/:id", s.handleDeleteHeldShare)
 
 	// NLP
 	s.router.POST("/nlp/translate", s.handleNLPTranslate)
@@ -109,6 +196,12 @@ func (s *Server) Start() error {
 	// Start P2P event broadcaster
 	go s.runP2PBroadcaster()
 
+	// Start proof workers
+	s.jobs.start()
+
+	// Sign documents stored before holder signatures
+	go s.signUnsignedDocuments()
+
 	return s.server.ListenAndServe()
 }
 
@@ -145,6 +238,14 @@ func (s *Server) runP2PBroadcaster() {
 				"payload":   string(msg),
 				"timestamp": time.Now().Unix(),
 			})
+		case r := <-s.config.P2PNode.Revocations:
+			s.broadcastWS(gin.H{
+				"type":      "revocation_received",
+				"payload":   gin.H{"id": r.ID(), "revocation": r},
+				"timestamp": time.Now().Unix(),
+			})
+		case rs := <-s.config.P2PNode.ReturnedShares:
+			s.receiveReturnedShare(rs)
 		}
 	}
 }
@@ -154,6 +255,7 @@ func (s *Server) Stop() {
 	if s.server == nil {
 		return
 	}
+	s.jobs.stop()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := s.server.Shutdown(ctx); err != nil {
@@ -180,12 +282,40 @@ func (s *Server) handleHealth(c *gin.Context) {
 func (s *Server) handleP2PStatus(c *gin.Context) {
 	peers := s.config.P2PNode.Peers()
 	peerList := s.buildPeerList(peers)
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"connected":  true,
 		"node_id":    s.config.P2PNode.ID(),
 		"peer_count": len(peers),
 		"peers":      peerList,
-	})
+	}
+	if m := s.config.Mesh; m != nil {
+		resp["network"] = gin.H{
+			"name":            m.Network.Name,
+			"version":         m.Network.Version,
+			"region":          m.Network.Region,
+			"private":         m.Security.PrivateNetwork.Enabled,
+			"bootstrap_peers": len(m.Network.BootstrapPeers),
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleEmergencyNodes lists the relief points from the mesh configuration
+// and whether the node is connected to each.
+func (s *Server) handleEmergencyNodes(c *gin.Context) {
+	nodes := []gin.H{}
+	if s.config.Mesh != nil {
+		for _, n := range s.config.Mesh.Regional.EmergencyNodes {
+			id, err := peer.Decode(n.PeerID)
+			nodes = append(nodes, gin.H{
+				"name":      n.Name,
+				"location":  n.Location,
+				"peer_id":   n.PeerID,
+				"connected": err == nil && s.config.P2PNode.Connected(id),
+			})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"nodes": nodes, "count": len(nodes)})
 }
 
 func (s *Server) handleP2PPeers(c *gin.Context) {
@@ -304,13 +434,7 @@ func (s *Server) handleIPFSAdd(c *gin.Context) {
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file: " + err.Error()})
-		return
-	}
-
-	cid, err := s.config.IPFSNode.Add(data)
+	cid, err := s.config.IPFSNode.AddReader(file)
 	if err != nil {
 		// IPFS may not be running in dev
 		s.config.Logger.Warnf("IPFS add failed (is IPFS daemon running?): %v", err)
@@ -323,23 +447,38 @@ func (s *Server) handleIPFSAdd(c *gin.Context) {
 
 func (s *Server) handleIPFSGet(c *gin.Context) {
 	cid := c.Param("cid")
-	data, err := s.config.IPFSNode.Get(cid)
+	r, err := s.config.IPFSNode.Cat(cid)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.Data(http.StatusOK, "application/octet-stream", data)
+	defer r.Close()
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", r, nil)
 }
 
 // ──────────────────────────────────────────────
 // ZKP
 // ──────────────────────────────────────────────
 
+// generateRequest asks for a proof over a vault document. Proof jobs keep it
+// as JSON until a worker runs it.
+type generateRequest struct {
+	DocumentID string `json:"document_id" binding:"required"`
+	ProofType  string `json:"proof_type"`
+	// Claims override the claims stored in the document's metadata.
+	Claims zkp.Claims `json:"claims,omitempty"`
+	// ThresholdYears and ReferenceDate parameterise age_over proofs.
+	ThresholdYears int    `json:"threshold_years,omitempty"`
+	ReferenceDate  string `json:"reference_date,omitempty"`
+	// Disclose names the claims a disclosure proof reveals.
+	Disclose []string `json:"disclose,omitempty"`
+}
+
+// handleZKPGenerate queues a proof job and returns 202 with its ID; poll
+// /zkp/jobs/:id or listen for proof_job WebSocket events. With ?wait=true
+// it responds once the proof is ready, as a synchronous call.
 func (s *Server) handleZKPGenerate(c *gin.Context) {
-	var req struct {
-		DocumentID string `json:"document_id" binding:"required"`
-		ProofType  string `json:"proof_type"`
-	}
+	var req generateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -347,61 +486,119 @@ func (s *Server) handleZKPGenerate(c *gin.Context) {
 	if req.ProofType == "" {
 		req.ProofType = "degree"
 	}
+	if _, err := zkp.LookupProofType(req.ProofType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := s.config.DB.GetDocument(req.DocumentID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found: " + err.Error()})
+		return
+	}
+	if !s.config.Vault.Unlocked() {
+		c.JSON(http.StatusLocked, gin.H{"error": cryptopkg.ErrVaultLocked.Error()})
+		return
+	}
 
-	// Fetch document from DB
-	doc, err := s.config.DB.GetDocument(req.DocumentID)
+	wait := c.Query("wait") == "true"
+	job, outcome, err := s.jobs.enqueue(req, wait)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "document not found: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !wait {
+		c.JSON(http.StatusAccepted, jobInfo(job))
 		return
 	}
 
-	// Use content from database if available
-	var witnessData []byte
-	
-	if len(doc.Content) > 0 {
-		witnessData, err = s.enc.Decrypt(doc.Content)
-		if err != nil {
-			s.config.Logger.Warnf("failed to decrypt cached DB document: %v", err)
+	select {
+	case o := <-outcome:
+		if o.err != nil {
+			c.JSON(errorStatus(o.err), gin.H{"error": o.err.Error(), "job_id": job.ID})
+			return
 		}
+		c.JSON(http.StatusOK, o.result)
+	case <-c.Request.Context().Done():
+		// The client gave up; the job keeps running and can be polled.
 	}
-	
-	// Fallback to fetching encrypted content from IPFS mesh
-	if len(witnessData) == 0 && doc.CID != "" && s.config.IPFSNode != nil {
-		s.config.Logger.Infof("fetching document %s from local IPFS mesh %s", doc.ID, doc.CID)
-		ipfsData, ipfsErr := s.config.IPFSNode.Get(doc.CID)
-		if ipfsErr == nil {
-			witnessData, err = s.enc.Decrypt(ipfsData)
-			if err != nil {
-				s.config.Logger.Warnf("failed to decrypt IPFS document: %v", err)
-			} else {
-				// Cache back into SQLite
-				doc.Content = ipfsData
-				s.config.DB.AddDocument(*doc) // Acts as upsert depending on schema
-			}
-		}
+}
+
+// generateProof runs a proof job: it loads the document and hashes it as it
+// is decrypted, proves over its digest, stores and broadcasts the proof, and
+// returns the API response.
+// Cancelling ctx abandons the job between stages; a running groth16 prover
+// finishes, but its proof is discarded. stage reports the job's progress
+// and returns false if the job is no longer running. Once it accepts the
+// saving stage the job can no longer be cancelled, so a proof is stored and
+// broadcast only for a job that completes.
+func (s *Server) generateProof(ctx context.Context, req generateRequest, stage func(string) bool) (gin.H, error) {
+	stage("loading_document")
+
+	// Fetch document from DB
+	doc, err := s.config.DB.GetDocument(req.DocumentID)
+	if err != nil {
+		return nil, statusError(http.StatusNotFound, fmt.Errorf("document not found: %w", err))
 	}
 
-	if len(witnessData) == 0 {
-		return
+	// Hash as it is decrypted from the local copy, or the IPFS mesh
+	digest, err := s.documentDigest(doc)
+	if errors.Is(err, cryptopkg.ErrVaultLocked) {
+		return nil, statusError(http.StatusLocked, err)
+	}
+	if err != nil {
+		s.config.Logger.Warnf("failed to read document %s: %v", doc.ID, err)
+		return nil, fmt.Errorf("document content unavailable")
 	}
 
-	result, err := s.config.ZKP.GenerateProof(witnessData, req.ProofType)
+	claims, err := s.documentClaims(req.DocumentID, req.Claims)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "proof generation failed: " + err.Error()})
-		return
+		return nil, err
+	}
+	if req.ThresholdYears != 0 {
+		claims[zkp.ParamThresholdYears] = strconv.Itoa(req.ThresholdYears)
+	}
+	if req.ReferenceDate != "" {
+		claims[zkp.ParamReferenceDate] = req.ReferenceDate
+	}
+	if len(req.Disclose) > 0 {
+		claims[zkp.ParamDisclose] = strings.Join(req.Disclose, ",")
+	}
+
+	salt, err := s.commitmentSalt(doc, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stage("proving")
+	result, err := s.config.ZKP.GenerateProof(zkp.Document{Digest: digest, Salt: salt}, req.ProofType, claims)
+	if errors.Is(err, zkp.ErrInvalidClaims) || errors.Is(err, zkp.ErrUnknownProofType) {
+		return nil, statusError(http.StatusBadRequest, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("proof generation failed: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !stage(database.JobStageSaving) {
+		return nil, fmt.Errorf("proof job is no longer running")
 	}
 
 	// Persist proof
 	proofRecord := database.ProofRecord{
-		ID:               uuid.New().String(),
-		DocumentID:       req.DocumentID,
-		ProofHash:        result.Hash,
-		ProofType:        req.ProofType,
-		ProofData:        result.ProofBytes,
-		PublicWitness:    result.PublicWitnessBytes,
-		VerificationTime: result.VerificationTime,
-		SizeBytes:        result.SizeBytes,
-		CreatedAt:        time.Now(),
+		ID:                 uuid.New().String(),
+		DocumentID:         req.DocumentID,
+		ProofHash:          result.Hash,
+		ProofType:          req.ProofType,
+		ProofData:          result.ProofBytes,
+		PublicWitness:      result.PublicWitnessBytes,
+		VerificationTime:   result.VerificationTime,
+		SizeBytes:          result.SizeBytes,
+		CircuitFingerprint: result.Fingerprint,
+		Backend:            string(result.Backend),
+		CreatedAt:          time.Now(),
 	}
 	if err := s.config.DB.SaveProof(proofRecord); err != nil {
 		s.config.Logger.Warnf("failed to save proof: %v", err)
@@ -415,17 +612,62 @@ func (s *Server) handleZKPGenerate(c *gin.Context) {
 		s.config.Logger.Warnf("Failed to broadcast verification: %v", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"proof_hash":        result.Hash,
-		"type":              req.ProofType,
-		"verification_time": result.VerificationTime,
-		"size_bytes":        result.SizeBytes,
-	})
+	return gin.H{
+		"proof_hash":          result.Hash,
+		"type":                req.ProofType,
+		"backend":             result.Backend,
+		"document_commitment": doc.Commitment,
+		"verification_time":   result.VerificationTime,
+		"size_bytes":          result.SizeBytes,
+	}, nil
+}
+
+// commitmentSalt returns the salt of doc's commitment, first committing to
+// documents stored before commitments existed.
+func (s *Server) commitmentSalt(doc *database.DocumentRecord, digest []byte) (*big.Int, error) {
+	if salt, ok := new(big.Int).SetString(doc.CommitmentSalt, 10); ok {
+		return salt, nil
+	}
+
+	salt, err := zkp.NewCommitmentSalt()
+	if err != nil {
+		return nil, err
+	}
+	doc.Commitment = zkp.CommitDocumentDigest(digest, salt).String()
+	doc.CommitmentSalt = salt.String()
+	if err := s.config.DB.SetDocumentCommitment(doc.ID, doc.Commitment, doc.CommitmentSalt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// documentClaims merges the claims stored in a document's metadata with
+// those supplied in the request, which take precedence.
+func (s *Server) documentClaims(documentID string, override zkp.Claims) (zkp.Claims, error) {
+	claims := zkp.Claims{}
+
+	meta, err := s.config.DB.GetDocumentMetadata(documentID)
+	switch {
+	case err == nil:
+		for k, v := range meta.Custom {
+			claims[k] = v
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("failed to load document metadata: %w", err)
+	}
+
+	for k, v := range override {
+		claims[k] = v
+	}
+	return claims, nil
 }
 
 func (s *Server) handleZKPVerify(c *gin.Context) {
 	var req struct {
 		ProofHash string `json:"proof_hash" binding:"required"`
+		// DocumentCommitment, if set, must be the commitment the proof
+		// publishes, tying it to a specific vault document.
+		DocumentCommitment string `json:"document_commitment"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -439,72 +681,698 @@ func (s *Server) handleZKPVerify(c *gin.Context) {
 		return
 	}
 
-	valid, err := s.config.ZKP.VerifyProofFromBytes(proof.ProofData, proof.PublicWitness)
+	valid, err := s.config.ZKP.VerifyProofFromBytes(proof.ProofType, proof.Backend, proof.ProofData, proof.PublicWitness, proof.CircuitFingerprint)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "verification error: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"valid":      valid,
-		"proof_hash": req.ProofHash,
-		"proof_type": proof.ProofType,
-	})
+	resp := s.verificationResult(checkedProof{
+		valid:         valid,
+		proofType:     proof.ProofType,
+		backend:       proof.Backend,
+		proof:         proof.ProofData,
+		publicWitness: proof.PublicWitness,
+		createdAt:     proof.CreatedAt,
+	}, req.DocumentCommitment)
+	resp["proof_hash"] = req.ProofHash
+	c.JSON(http.StatusOK, resp)
+}
+
+// maxBatchItems bounds one batch verification request.
+const maxBatchItems = 256
+
+// handleZKPVerifyBatch verifies many stored proofs and/or bundles at once and
+// returns one result per item, in request order.
+func (s *Server) handleZKPVerifyBatch(c *gin.Context) {
+	var req struct {
+		Items []struct {
+			ProofHash string `json:"proof_hash"`
+			// Bundle is an exported bundle, as a JSON object or a string
+			// in any of its encodings.
+			Bundle             json.RawMessage `json:"bundle"`
+			DocumentCommitment string          `json:"document_commitment"`
+		} `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxBatchItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("items must hold between 1 and %d proofs", maxBatchItems)})
+		return
+	}
+
+	var hashes []string
+	for _, item := range req.Items {
+		if item.ProofHash != "" {
+			hashes = append(hashes, item.ProofHash)
+		}
+	}
+	stored, err := s.config.DB.GetProofsByHashes(hashes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]gin.H, len(req.Items))
+	var (
+		batch   []zkp.BatchItem
+		indexes []int
+		created []time.Time
+		holders []gin.H
+	)
+	for i, item := range req.Items {
+		var (
+			entry     zkp.BatchItem
+			createdAt time.Time
+			holder    gin.H
+		)
+		switch {
+		case item.ProofHash != "" && len(item.Bundle) > 0:
+			results[i] = gin.H{"valid": false, "error": "set proof_hash or bundle, not both"}
+			continue
+		case item.ProofHash != "":
+			proof, ok := stored[item.ProofHash]
+			if !ok {
+				results[i] = gin.H{"valid": false, "error": "proof not found"}
+				continue
+			}
+			entry = zkp.BatchItem{
+				ProofType:     proof.ProofType,
+				Backend:       proof.Backend,
+				Fingerprint:   proof.CircuitFingerprint,
+				Proof:         proof.ProofData,
+				PublicWitness: proof.PublicWitness,
+			}
+			createdAt = proof.CreatedAt
+		case len(item.Bundle) > 0:
+			bundle, err := parseInlineBundle(item.Bundle)
+			if err != nil {
+				results[i] = gin.H{"valid": false, "error": err.Error()}
+				continue
+			}
+			entry = zkp.BatchItem{
+				ProofType:     bundle.ProofType,
+				Backend:       bundle.Backend,
+				Fingerprint:   bundle.Fingerprint,
+				Proof:         bundle.Proof,
+				PublicWitness: bundle.PublicWitness,
+				VerifyingKey:  bundle.VerifyingKey,
+			}
+			createdAt = bundle.Created()
+			holder = bundleHolderInfo(bundle)
+		default:
+			results[i] = gin.H{"valid": false, "error": "proof_hash or bundle is required"}
+			continue
+		}
+		batch = append(batch, entry)
+		indexes = append(indexes, i)
+		created = append(created, createdAt)
+		holders = append(holders, holder)
+	}
+
+	for j, r := range s.config.ZKP.VerifyBatch(batch) {
+		i := indexes[j]
+		if r.Err != nil {
+			results[i] = gin.H{"valid": false, "error": "verification error: " + r.Err.Error()}
+			continue
+		}
+		results[i] = s.verificationResult(checkedProof{
+			valid:         r.Valid,
+			proofType:     batch[j].ProofType,
+			backend:       batch[j].Backend,
+			proof:         batch[j].Proof,
+			publicWitness: batch[j].PublicWitness,
+			createdAt:     created[j],
+			holder:        holders[j],
+		}, req.Items[i].DocumentCommitment)
+	}
+
+	validCount := 0
+	for i, r := range results {
+		r["index"] = i
+		if hash := req.Items[i].ProofHash; hash != "" {
+			r["proof_hash"] = hash
+		}
+		if r["valid"] == true {
+			validCount++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "count": len(results), "valid_count": validCount})
+}
+
+// parseInlineBundle decodes a bundle embedded in a JSON request, either as an
+// object or as a string in any bundle encoding.
+func parseInlineBundle(raw json.RawMessage) (*zkp.Bundle, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return zkp.ParseBundle([]byte(text))
+	}
+	return zkp.ParseBundle(raw)
+}
+
+// checkedProof is a proof whose pairing check has run, from the store or
+// from a bundle.
+type checkedProof struct {
+	valid         bool
+	proofType     string
+	backend       string
+	proof         []byte
+	publicWitness []byte
+	// createdAt is when the proof was made; for a bundle it is what the
+	// exporting node claims.
+	createdAt time.Time
+	// holder is who a bundle claims to come from (see bundleHolderInfo).
+	holder gin.H
+}
+
+func bundleProof(valid bool, b *zkp.Bundle) checkedProof {
+	return checkedProof{
+		valid:         valid,
+		proofType:     b.ProofType,
+		backend:       b.Backend,
+		proof:         b.Proof,
+		publicWitness: b.PublicWitness,
+		createdAt:     b.Created(),
+		holder:        bundleHolderInfo(b),
+	}
+}
+
+// verificationResult describes a checked proof: its decoded public inputs,
+// its issuer, whether it expired, is older than MaxProofAge or was revoked,
+// and whether it matches the expected document commitment, if one was given.
+func (s *Server) verificationResult(p checkedProof, commitment string) gin.H {
+	proofType, pubWitness := p.proofType, p.publicWitness
+	resp := gin.H{
+		"valid":      p.valid,
+		"proof_type": proofType,
+	}
+	if backend, err := zkp.ParseBackend(p.backend); err == nil {
+		resp["backend"] = backend
+	}
+
+	now := time.Now()
+	if validUntil, err := zkp.DecodePublicDate(proofType, pubWitness, zkp.ClaimValidUntil); err == nil {
+		expired := now.After(validUntil)
+		resp["valid_until"] = validUntil
+		resp["expired"] = expired
+		if expired {
+			resp["valid"] = false
+		}
+	}
+	if p.holder != nil {
+		resp["holder"] = p.holder
+	}
+	if !p.createdAt.IsZero() {
+		resp["created_at"] = p.createdAt.UTC()
+		if s.config.MaxProofAge > 0 {
+			stale := now.Sub(p.createdAt) > s.config.MaxProofAge
+			resp["stale"] = stale
+			if stale {
+				resp["valid"] = false
+			}
+		}
+	}
+
+	inputs, err := zkp.DecodePublicInputs(proofType, pubWitness)
+	if err == nil {
+		resp["public_inputs"] = inputs
+		if disclosed := zkp.DisclosedClaims(proofType, inputs); disclosed != nil {
+			resp["disclosed"] = disclosed
+		}
+		if key, ok := inputs[zkp.ClaimIssuerPublicKey]; ok {
+			resp["issuer"] = s.issuerInfo(key)
+
+			revocation, err := s.config.DB.FindRevocation(key, zkp.ProofHash(p.proof, pubWitness), inputs[zkp.PublicDocumentCommitment])
+			switch {
+			case err == nil:
+				resp["revoked"] = true
+				resp["revocation"] = revocationInfo(revocation)
+				resp["valid"] = false
+			case errors.Is(err, sql.ErrNoRows):
+				resp["revoked"] = false
+			default:
+				s.config.Logger.Warnf("revocation lookup failed: %v", err)
+			}
+		}
+	}
+	if commitment != "" {
+		matches := inputs[zkp.PublicDocumentCommitment] == commitment
+		resp["commitment_matches"] = matches
+		resp["valid"] = resp["valid"] == true && matches
+	}
+	return resp
+}
+
+// maxBundleBody bounds an imported proof bundle.
+const maxBundleBody = 1 << 20
+
+func (s *Server) handleZKPExport(c *gin.Context) {
+	proof, err := s.config.DB.GetProofByHash(c.Param("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proof not found: " + err.Error()})
+		return
+	}
+
+	bundle, err := s.proofBundle(proof)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json":
+		c.JSON(http.StatusOK, bundle)
+	case "cbor":
+		data, err := bundle.CBOR()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "application/cbor", data)
+	case "text":
+		text, err := bundle.Text()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, text)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown format " + format + " (use json, cbor or text)"})
+	}
+}
+
+// proofBundle packs a stored proof into a portable bundle.
+func (s *Server) proofBundle(proof *database.ProofRecord) (*zkp.Bundle, error) {
+	fingerprint := proof.CircuitFingerprint
+	if fingerprint == "" {
+		fp, err := s.config.ZKP.Fingerprint(proof.ProofType)
+		if err != nil {
+			return nil, err
+		}
+		fingerprint = fp
+	}
+	vkFingerprint, err := s.config.ZKP.VerifyingKeyFingerprint(proof.ProofType, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &zkp.Bundle{
+		Version:       zkp.BundleVersion,
+		ProofType:     proof.ProofType,
+		Fingerprint:   fingerprint,
+		VerifyingKey:  vkFingerprint,
+		Proof:         proof.ProofData,
+		PublicWitness: proof.PublicWitness,
+		CreatedAt:     proof.CreatedAt.Unix(),
+		Backend:       proof.Backend,
+	}
+	if inputs, err := zkp.DecodePublicInputs(proof.ProofType, proof.PublicWitness); err == nil {
+		if key, ok := inputs[zkp.ClaimIssuerPublicKey]; ok {
+			bundle.Issuer = &zkp.BundleIssuer{PublicKey: key}
+			if issuer, err := s.config.DB.GetIssuer(key); err == nil {
+				bundle.Issuer.Name = issuer.Name
+			}
+		}
+	}
+	if err := s.signBundle(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// handleZKPImport verifies a proof bundle exported by any node, in JSON, CBOR
+// or text form. Nothing is stored.
+func (s *Server) handleZKPImport(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBundleBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read error: " + err.Error()})
+		return
+	}
+	bundle, err := zkp.ParseBundle(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	valid, err := s.config.ZKP.VerifyBundle(bundle)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "verification error: " + err.Error()})
+		return
+	}
+
+	resp := s.verificationResult(bundleProof(valid, bundle), c.Query("document_commitment"))
+	resp["fingerprint"] = bundle.Fingerprint
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) handleListProofTypes(c *gin.Context) {
+	types := zkp.ProofTypes()
+	result := make([]gin.H, len(types))
+	for i, pt := range types {
+		result[i] = gin.H{
+			"name":          pt.Name,
+			"description":   pt.Description,
+			"public_inputs": pt.Public,
+		}
+		if backend, err := s.config.ZKP.Backend(pt.Name); err == nil {
+			result[i]["backend"] = backend
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"types": result, "count": len(result)})
+}
+
+// issuerInfo describes the issuer whose key a proof was checked against, and
+// whether it is a registered institution.
+func (s *Server) issuerInfo(publicKey string) gin.H {
+	info := gin.H{"public_key": publicKey, "registered": false}
+	if issuer, err := s.config.DB.GetIssuer(publicKey); err == nil {
+		info["registered"] = true
+		info["name"] = issuer.Name
+	}
+	return info
+}
+
+func (s *Server) handleListIssuers(c *gin.Context) {
+	issuers, err := s.config.DB.ListIssuers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := make([]gin.H, len(issuers))
+	for i, iss := range issuers {
+		result[i] = gin.H{
+			"public_key": iss.PublicKey,
+			"name":       iss.Name,
+			"created_at": iss.CreatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"issuers": result, "count": len(result)})
+}
+
+// revocationInfo describes a stored revocation to clients.
+func revocationInfo(r *database.RevocationRecord) gin.H {
+	info := gin.H{
+		"id":                r.ID,
+		"issuer_public_key": r.IssuerPublicKey,
+		"reason":            r.Reason,
+		"revoked_at":        time.Unix(r.RevokedAt, 0).UTC(),
+	}
+	if r.ProofHash != "" {
+		info["proof_hash"] = r.ProofHash
+	}
+	if r.DocumentCommitment != "" {
+		info["document_commitment"] = r.DocumentCommitment
+	}
+	return info
+}
+
+// handleListRevocations pages through the local revocation registry in the
+// order this node learned of them; pass the returned next as after.
+func (s *Server) handleListRevocations(c *gin.Context) {
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	revocations, err := s.config.DB.ListRevocationsAfter(after, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]gin.H, len(revocations))
+	next := after
+	for i := range revocations {
+		result[i] = revocationInfo(&revocations[i])
+		next = revocations[i].Seq
+	}
+	c.JSON(http.StatusOK, gin.H{"revocations": result, "count": len(result), "next": next})
+}
+
+// handleRevoke accepts an issuer-signed revocation (see `issuer revoke`),
+// stores it and gossips it to the mesh.
+func (s *Server) handleRevoke(c *gin.Context) {
+	var r zkp.Revocation
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	added, err := s.config.P2PNode.PublishRevocation(&r)
+	if err != nil && !added {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.config.Logger.Warnf("Failed to gossip revocation: %v", err)
+	}
+
+	status := http.StatusCreated
+	if !added {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{"id": r.ID(), "added": added})
+}
+
+// handleZKPQR renders a stored proof's bundle as QR codes. Bundles longer
+// than segment_size characters span several codes; X-QR-Parts gives the
+// count and part selects one, so clients can page or animate through them.
+func (s *Server) handleZKPQR(c *gin.Context) {
+	proof, err := s.config.DB.GetProofByHash(c.Param("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proof not found: " + err.Error()})
+		return
+	}
+
+	segmentSize, err := strconv.Atoi(c.DefaultQuery("segment_size", strconv.Itoa(zkp.DefaultQRSegmentSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid segment_size"})
+		return
+	}
+	part, err := strconv.Atoi(c.DefaultQuery("part", "1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid part"})
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "512"))
+	if err != nil || size < 64 || size > 4096 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 64 and 4096 pixels"})
+		return
+	}
+
+	bundle, err := s.proofBundle(proof)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	text, err := bundle.Text()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	segments, err := zkp.QRSegments(text, segmentSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", "png")
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"parts": len(segments), "segments": segments})
+		return
+	}
+	if part < 1 || part > len(segments) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("part must be between 1 and %d", len(segments))})
+		return
+	}
+
+	c.Header("X-QR-Parts", strconv.Itoa(len(segments)))
+	switch format {
+	case "png":
+		png, err := zkp.QRPNG(segments[part-1], size)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", png)
+	case "svg":
+		svg, err := zkp.QRSVG(segments[part-1], size)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "image/svg+xml", svg)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown format " + format + " (use png, svg or json)"})
+	}
+}
+
+// handleZKPQRDecode reassembles scanned QR payloads into a bundle and
+// verifies it.
+func (s *Server) handleZKPQRDecode(c *gin.Context) {
+	var req struct {
+		Segments           []string `json:"segments" binding:"required"`
+		DocumentCommitment string   `json:"document_commitment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	text, err := zkp.JoinQRSegments(req.Segments)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bundle, err := zkp.ParseBundle([]byte(text))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	valid, err := s.config.ZKP.VerifyBundle(bundle)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "verification error: " + err.Error()})
+		return
+	}
+
+	resp := s.verificationResult(bundleProof(valid, bundle), req.DocumentCommitment)
+	resp["fingerprint"] = bundle.Fingerprint
+	c.JSON(http.StatusOK, resp)
 }
 
 // ──────────────────────────────────────────────
 // Document Vault
 // ──────────────────────────────────────────────
 
+// maxMetadataSize bounds the metadata field of an upload.
+const maxMetadataSize = 1 << 20
+
+// handleAddDocument streams the uploaded file through encryption into its
+// content file, so no upload is ever held in memory whole.
 func (s *Server) handleAddDocument(c *gin.Context) {
-	file, header, err := c.Request.FormFile("file")
+	mr, err := c.Request.MultipartReader()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing file: " + err.Error()})
 		return
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "read error: " + err.Error()})
+	id := uuid.New().String()
+	var (
+		sealed *sealedDocument
+		name   string
+		ctype  string
+		// Optional JSON metadata; its custom fields carry the credential
+		// claims used for proof generation.
+		meta *types.Metadata
+	)
+	stored := false
+	defer func() {
+		if sealed != nil && !stored {
+			s.config.DB.RemoveContentFile(sealed.file)
+		}
+	}()
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "read error: " + err.Error()})
+			return
+		}
+
+		switch part.FormName() {
+		case "metadata":
+			raw, err := io.ReadAll(io.LimitReader(part, maxMetadataSize))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "read error: " + err.Error()})
+				return
+			}
+			if len(raw) > 0 {
+				meta = &types.Metadata{}
+				if err := json.Unmarshal(raw, meta); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metadata: " + err.Error()})
+					return
+				}
+			}
+		case "file":
+			if sealed != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "only one file per upload"})
+				return
+			}
+			name, ctype = part.FileName(), part.Header.Get("Content-Type")
+			// Encrypt content under a key of its own, wrapped by the vault
+			// master key, hashing it on the way
+			sealed, err = s.sealDocument(id, part)
+			if err != nil {
+				c.JSON(vaultErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+		}
+		part.Close()
+	}
+	if sealed == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing file: no file field"})
 		return
 	}
 
-	// Compute hash
-	hash := cryptopkg.Hash(data)
-
-	// Encrypt content
-	encrypted, err := s.enc.Encrypt(data)
+	// Commit to the document so proofs over it can be tied back to it
+	salt, err := zkp.NewCommitmentSalt()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Store encrypted payload on IPFS mesh
-	cid := ""
-	if s.config.IPFSNode != nil {
-		if c, ipfsErr := s.config.IPFSNode.Add(encrypted); ipfsErr == nil {
-			cid = c
-		}
-	}
+	cid := s.addToIPFS(sealed.file)
 
 	doc := database.DocumentRecord{
-		ID:        uuid.New().String(),
-		Name:      header.Filename,
-		Type:      header.Header.Get("Content-Type"),
-		Size:      int64(len(data)),
-		Hash:      hash,
-		CID:       cid,
-		Encrypted: true,
-		Content:   encrypted,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          id,
+		Name:        name,
+		Type:        ctype,
+		Size:        sealed.size,
+		Hash:        sealed.hash,
+		CID:         cid,
+		Encrypted:   true,
+		ContentFile: sealed.file,
+		// The salt is stored in the clear like the rest of the row; it only
+		// has to stay private from verifiers, not from the vault owner.
+		Commitment:     zkp.CommitDocumentDigest(sealed.digest, salt).String(),
+		CommitmentSalt: salt.String(),
+		WrappedKey:     sealed.wrappedKey,
+		KeyID:          sealed.keyID,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	if err := s.config.DB.AddDocument(doc); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
 		return
 	}
+	stored = true
+	if meta != nil {
+		if err := s.config.DB.SaveDocumentMetadata(metadataRecord(doc.ID, *meta)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+			return
+		}
+	}
+
+	// Sign the document's hash and metadata as the holder
+	signature, err := s.signDocument(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "signing failed: " + err.Error()})
+		return
+	}
 
 	// Broadcast upload finalized
 	s.broadcastWS(gin.H{
@@ -512,15 +1380,19 @@ func (s *Server) handleAddDocument(c *gin.Context) {
 		"payload":   gin.H{"document_id": doc.ID, "hash": doc.Hash},
 		"timestamp": time.Now().Unix(),
 	})
+	go s.publishManifest()
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":         doc.ID,
 		"name":       doc.Name,
 		"hash":       doc.Hash,
+		"commitment": doc.Commitment,
 		"cid":        doc.CID,
 		"size":       doc.Size,
 		"encrypted":  true,
 		"created_at": doc.CreatedAt,
+		"holder":     signature.PublicKey,
+		"signature":  signature.Signature,
 	})
 }
 
@@ -539,6 +1411,7 @@ func (s *Server) handleListDocuments(c *gin.Context) {
 			"type":       d.Type,
 			"size":       d.Size,
 			"hash":       d.Hash,
+			"commitment": d.Commitment,
 			"cid":        d.CID,
 			"encrypted":  d.Encrypted,
 			"created_at": d.CreatedAt,
@@ -555,15 +1428,74 @@ func (s *Server) handleGetDocument(c *gin.Context) {
 		return
 	}
 
-	// Decrypt for download
-	plaintext, err := s.enc.Decrypt(doc.Content)
+	// Documents restored from a recovery manifest may only be on IPFS yet.
+	content, err := s.documentContent(doc)
+	if errors.Is(err, database.ErrNoContent) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	// Decrypt for download as it is sent
+	plaintext, err := s.openDocument(doc, content)
+	if errors.Is(err, cryptopkg.ErrVaultLocked) {
+		c.JSON(http.StatusLocked, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "decryption failed"})
 		return
 	}
 
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", doc.Name))
-	c.Data(http.StatusOK, doc.Type, plaintext)
+	c.Header("Content-Length", strconv.FormatInt(doc.Size, 10))
+	c.Header("Content-Type", doc.Type)
+	c.Status(http.StatusOK)
+	// A chunk failing to open cuts the response short of Content-Length,
+	// which clients report as an error.
+	if _, err := io.Copy(c.Writer, plaintext); err != nil {
+		s.config.Logger.Warnf("download of document %s cut short: %v", doc.ID, err)
+	}
+}
+
+func (s *Server) handleUpdateDocumentMetadata(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := s.config.DB.GetDocument(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	var meta types.Metadata
+	if err := c.ShouldBindJSON(&meta); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.config.DB.SaveDocumentMetadata(metadataRecord(id, meta)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// The signature covers the metadata, so sign again
+	signature, err := s.signDocument(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "signing failed: " + err.Error()})
+		return
+	}
+	go s.publishManifest()
+	c.JSON(http.StatusOK, gin.H{"id": id, "metadata": meta, "signature": documentSignatureInfo(signature)})
+}
+
+func metadataRecord(documentID string, m types.Metadata) database.MetadataRecord {
+	return database.MetadataRecord{
+		DocumentID:  documentID,
+		Title:       m.Title,
+		Description: m.Description,
+		Tags:        m.Tags,
+		Custom:      m.Custom,
+	}
 }
 
 func (s *Server) handleDeleteDocument(c *gin.Context) {
@@ -572,6 +1504,7 @@ func (s *Server) handleDeleteDocument(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	go s.publishManifest()
 	c.JSON(http.StatusOK, gin.H{"deleted": id})
 }
 
@@ -611,16 +1544,11 @@ func (s *Server) handleNLPSummarize(c *gin.Context) {
 // Middleware
 // ──────────────────────────────────────────────
 
-func corsMiddleware() gin.HandlerFunc {
+// corsMiddleware lets pages from the configured frontend origins, and no
+// others, read API responses.
+func corsMiddleware(origins []string) gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins: []string{
-			"http://localhost:3000",
-			"http://localhost:3001",
-		},
-		AllowOriginFunc: func(origin string) bool {
-			// Allow all Vercel preview/production deployments
-			return strings.HasSuffix(origin, ".vercel.app")
-		},
+		AllowOrigins:     origins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Accept", "X-Requested-With", "Cache-Control"},
 		ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
@@ -628,3 +1556,31 @@ func corsMiddleware() gin.HandlerFunc {
 		MaxAge:           12 * time.Hour,
 	})
 }
+
+// originAllowed reports whether a page from origin may call the API. A
+// request without an Origin header comes from a program, not a web page.
+func (s *Server) originAllowed(origin string) bool {
+	return origin == "" || slices.Contains(s.config.AllowedOrigins, origin)
+}
+
+// requireLocal guards the routes that unlock, rotate, split or recover the
+// vault, read its documents or prove over them. Browsers send even requests
+// CORS forbids, so pages from other origins are refused here rather than left
+// to CORS. The rest must come from this machine or carry the API token.
+func (s *Server) requireLocal(c *gin.Context) {
+	if !s.originAllowed(c.GetHeader("Origin")) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+		return
+	}
+	if token := s.config.APIToken; token != "" {
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return
+		}
+	}
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if ip := net.ParseIP(host); err == nil && ip != nil && ip.IsLoopback() {
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "this route is only served to this machine or with the API token"})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lairik-pulse/node/internal/zkp"
+)
+
+// handleSolidityVerifier returns a Solidity contract verifying proofs of a
+// proof type with the current keys, or with the key set named by
+// ?fingerprint=. The contract embeds the verifying key, reported in the
+// X-Verifying-Key header.
+func (s *Server) handleSolidityVerifier(c *gin.Context) {
+	proofType := c.Param("type")
+	var buf bytes.Buffer
+	vkFingerprint, err := s.config.ZKP.ExportSolidity(proofType, c.Query("fingerprint"), &buf)
+	switch {
+	case errors.Is(err, zkp.ErrUnknownProofType):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Verifying-Key", vkFingerprint)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_verifier.sol"`, proofType))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+}
+
+// handleSolidityCalldata encodes a stored proof as a call to the verifier
+// contract of the key set it was made with.
+func (s *Server) handleSolidityCalldata(c *gin.Context) {
+	proof, err := s.config.DB.GetProofByHash(c.Param("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proof not found: " + err.Error()})
+		return
+	}
+	bundle, err := s.proofBundle(proof)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	call, err := zkp.EncodeSolidityCalldata(bundle.Backend, bundle.Proof, bundle.PublicWitness)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"proof_hash":    proof.ProofHash,
+		"proof_type":    proof.ProofType,
+		"fingerprint":   bundle.Fingerprint,
+		"verifying_key": bundle.VerifyingKey,
+		"backend":       call.Backend,
+		"function":      call.Function,
+		"proof":         call.Proof,
+		"inputs":        call.Inputs,
+		"data":          call.Data,
+	})
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lairik-pulse/node/internal/database"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+)
+
+// legacyVaultPassword derived the single key every document was encrypted
+// with before the keystore. It is only used to read and migrate those
+// documents.
+const legacyVaultPassword = "lairik-pulse-vault-key"
+
+// maxLegacyCiphertext bounds the legacy ciphertext read into memory. Legacy
+// documents were stored in a database row, which SQLite caps at this size by
+// default.
+const maxLegacyCiphertext = 1_000_000_000
+
+// sealedDocument is document content sealed into a content file.
+type sealedDocument struct {
+	file       string
+	wrappedKey []byte
+	keyID      string
+	size       int64
+	// digest is the SHA-256 of the plaintext, and hash its encoding as
+	// stored with the document.
+	digest []byte
+	hash   string
+}
+
+// sealDocument streams plaintext into a new content file for document id,
+// sealed with a new key of its own. The key is returned wrapped by the vault
+// master key, with the ID of that master key.
+func (s *Server) sealDocument(id string, plaintext io.Reader) (*sealedDocument, error) {
+	key, wrappedKey, keyID, err := s.config.Vault.NewDocumentKey(id)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	enc, err := cryptopkg.NewEncryptionServiceFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.config.DB.NewContentWriter(id)
+	if err != nil {
+		return nil, err
+	}
+	sw, err := enc.SealStream(w, id)
+	if err == nil {
+		_, err = io.Copy(sw, plaintext)
+	}
+	if err == nil {
+		err = sw.Close()
+	}
+	if err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	file, err := w.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &sealedDocument{
+		file:       file,
+		wrappedKey: wrappedKey,
+		keyID:      keyID,
+		size:       sw.Size(),
+		digest:     sw.Sum(),
+		hash:       sw.Hash(),
+	}, nil
+}
+
+// addToIPFS adds a content file to IPFS and returns its CID, or "" if IPFS
+// is unavailable.
+func (s *Server) addToIPFS(file string) string {
+	if s.config.IPFSNode == nil {
+		return ""
+	}
+	f, err := s.config.DB.OpenContentFile(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	cid, err := s.config.IPFSNode.AddReader(f)
+	if err != nil {
+		return ""
+	}
+	return cid
+}
+
+// openDocument decrypts the ciphertext of doc as it is read from ciphertext.
+// Documents without a wrapped key predate the keystore and are under the
+// legacy key; they too are only opened while the vault is unlocked.
+// Ciphertext sealed before envelopes is still read, unbound to the document.
+func (s *Server) openDocument(doc *database.DocumentRecord, ciphertext io.Reader) (io.Reader, error) {
+	if !s.config.Vault.Unlocked() {
+		return nil, cryptopkg.ErrVaultLocked
+	}
+	if doc.WrappedKey == nil {
+		// Legacy ciphertext is one GCM message, which cannot be authenticated
+		// before it was read whole. It is migrated to streamed content on
+		// unlock, so this only runs until then.
+		data, err := io.ReadAll(io.LimitReader(ciphertext, maxLegacyCiphertext+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxLegacyCiphertext {
+			return nil, fmt.Errorf("legacy document is larger than %d bytes", maxLegacyCiphertext)
+		}
+		plaintext, err := s.legacyEnc.Decrypt(data)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(plaintext), nil
+	}
+
+	key, err := s.config.Vault.DocumentKey(doc.ID, doc.KeyID, doc.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	enc, err := cryptopkg.NewEncryptionServiceFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return enc.OpenStream(ciphertext, cryptopkg.AssociatedData{DocumentID: doc.ID, Hash: doc.Hash})
+}
+
+// documentDigest returns the SHA-256 of doc's plaintext, for the prover. The
+// plaintext is hashed chunk by chunk as it is decrypted, never held whole,
+// and must match the hash stored at upload. Local content that fails to open
+// is fetched again from IPFS.
+func (s *Server) documentDigest(doc *database.DocumentRecord) ([]byte, error) {
+	content, err := s.documentContent(doc)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := s.hashDocument(doc, content)
+	if err == nil || errors.Is(err, cryptopkg.ErrVaultLocked) || !s.hasLocalContent(doc) || doc.CID == "" || s.config.IPFSNode == nil {
+		return digest, err
+	}
+
+	s.config.Logger.Warnf("failed to decrypt stored document %s, fetching it from IPFS: %v", doc.ID, err)
+	content, err = s.config.IPFSNode.Cat(doc.CID)
+	if err != nil {
+		return nil, err
+	}
+	return s.hashDocument(doc, content)
+}
+
+func (s *Server) hashDocument(doc *database.DocumentRecord, content io.ReadCloser) ([]byte, error) {
+	defer content.Close()
+	plaintext, err := s.openDocument(doc, content)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, plaintext); err != nil {
+		return nil, err
+	}
+	digest := h.Sum(nil)
+	// doc.Hash is encoded like cryptopkg.Hash.
+	if base64.URLEncoding.EncodeToString(digest) != doc.Hash {
+		return nil, errors.New("content does not match the document hash")
+	}
+	return digest, nil
+}
+
+// migrateLegacyDocuments re-encrypts the documents still under the legacy
+// key with keys of their own, and re-adds them to IPFS. It returns how many
+// were migrated; a document that fails is left as it was for the next unlock.
+func (s *Server) migrateLegacyDocuments() int {
+	ids, err := s.config.DB.LegacyDocumentIDs()
+	if err != nil {
+		s.config.Logger.Warnf("vault: failed to list legacy documents: %v", err)
+		return 0
+	}
+
+	migrated := 0
+	for _, id := range ids {
+		if err := s.reencryptDocument(id); err != nil {
+			s.config.Logger.Warnf("vault: failed to migrate document %s: %v", id, err)
+			continue
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		s.config.Logger.Infof("vault: moved %d document(s) off the legacy key", migrated)
+	}
+	return migrated
+}
+
+// reencryptDocument encrypts document id again under a new key of its own.
+func (s *Server) reencryptDocument(id string) error {
+	doc, err := s.config.DB.GetDocument(id)
+	if err != nil {
+		return err
+	}
+
+	content, err := s.documentContent(doc)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	plaintext, err := s.openDocument(doc, content)
+	if err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+	return s.replaceDocumentContent(doc, plaintext)
+}
+
+// replaceDocumentContent streams plaintext of doc into a new content file
+// under a new key of its own and re-adds it to IPFS. The old CID names the
+// old ciphertext; it is replaced, or dropped if IPFS is unavailable.
+func (s *Server) replaceDocumentContent(doc *database.DocumentRecord, plaintext io.Reader) error {
+	sealed, err := s.sealDocument(doc.ID, plaintext)
+	if err != nil {
+		return err
+	}
+	if sealed.hash != doc.Hash {
+		s.config.DB.RemoveContentFile(sealed.file)
+		return fmt.Errorf("content does not match the document hash")
+	}
+
+	cid := s.addToIPFS(sealed.file)
+	if err := s.config.DB.ReplaceDocumentContent(doc.ID, sealed.file, sealed.wrappedKey, sealed.keyID, cid); err != nil {
+		s.config.DB.RemoveContentFile(sealed.file)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) hasLocalContent(doc *database.DocumentRecord) bool {
+	return doc.ContentFile != "" || len(doc.Content) > 0
+}
+
+// documentContent opens the stored ciphertext of doc, from the database or
+// else streamed from IPFS. Content from IPFS is stored once read to the end.
+func (s *Server) documentContent(doc *database.DocumentRecord) (io.ReadCloser, error) {
+	content, err := s.config.DB.OpenDocumentContent(doc)
+	if !errors.Is(err, database.ErrNoContent) || doc.CID == "" || s.config.IPFSNode == nil {
+		return content, err
+	}
+
+	r, err := s.config.IPFSNode.Cat(doc.CID)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := s.config.DB.NewContentWriter(doc.ID)
+	if err != nil {
+		return r, nil
+	}
+	return &cachingReader{ReadCloser: r, db: s.config.DB, id: doc.ID, cache: cache}, nil
+}
+
+// cachingReader copies content fetched from IPFS into a content file, and
+// stores it as the document's content once it was read to the end.
+type cachingReader struct {
+	io.ReadCloser
+	db    *database.DB
+	id    string
+	cache *database.ContentWriter
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.cache == nil {
+		return n, err
+	}
+	if _, werr := r.cache.Write(p[:n]); werr != nil {
+		r.cache.Abort()
+		r.cache = nil
+		return n, err
+	}
+	if errors.Is(err, io.EOF) {
+		if file, cerr := r.cache.Commit(); cerr == nil {
+			r.db.CacheDocumentContent(r.id, file)
+		}
+		r.cache = nil
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	if r.cache != nil {
+		r.cache.Abort()
+		r.cache = nil
+	}
+	return r.ReadCloser.Close()
+}
+
+// vaultErrorStatus is the HTTP status for a keystore or document key error.
+func vaultErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, cryptopkg.ErrVaultLocked):
+		return http.StatusLocked
+	case errors.Is(err, cryptopkg.ErrWrongPassphrase):
+		return http.StatusUnauthorized
+	case errors.Is(err, cryptopkg.ErrWeakPassphrase):
+		return http.StatusBadRequest
+	case errors.Is(err, cryptopkg.ErrRotationInProgress):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// ──────────────────────────────────────────────
+// Vault Keystore
+// ──────────────────────────────────────────────
+
+func (s *Server) handleVaultStatus(c *gin.Context) {
+	legacy, err := s.config.DB.LegacyDocumentIDs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"initialized":      s.config.Vault.Initialized(),
+		"unlocked":         s.config.Vault.Unlocked(),
+		"key_id":           s.config.Vault.KeyID(),
+		"retiring_key_id":  s.config.Vault.RetiringKeyID(),
+		"legacy_documents": len(legacy),
+	})
+}
+
+// handleVaultUnlock opens the vault with the holder's passphrase. On a new
+// node the first unlock creates the master key and seals it under the
+// passphrase given. Documents from before the keystore are then migrated, and
+// an interrupted key rotation resumes.
+func (s *Server) handleVaultUnlock(c *gin.Context) {
+	var req struct {
+		Passphrase string `json:"passphrase" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := s.config.Vault.Unlock(req.Passphrase)
+	if err != nil {
+		c.JSON(vaultErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if created {
+		s.config.Logger.Infof("vault: created master key %s", s.config.Vault.KeyID())
+	}
+	s.storeKeys(req.Passphrase, false)
+
+	// Document keys wrapped before key IDs were recorded are all under the
+	// first master key: the retiring one if a rotation is in progress.
+	firstKeyID := s.config.Vault.RetiringKeyID()
+	if firstKeyID == "" {
+		firstKeyID = s.config.Vault.KeyID()
+	}
+	if _, err := s.config.DB.AssignDocumentKeyID(firstKeyID); err != nil {
+		s.config.Logger.Warnf("vault: %v", err)
+	}
+
+	resp := gin.H{
+		"unlocked": true,
+		"created":  created,
+		"key_id":   s.config.Vault.KeyID(),
+		"migrated": s.migrateLegacyDocuments(),
+	}
+	if r := s.resumeRotation(); r != nil {
+		resp["rotation"] = rotationInfo(r)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleVaultPassphrase seals the master key under a new passphrase. Use
+// /vault/rotate instead if the master key itself may have leaked.
+func (s *Server) handleVaultPassphrase(c *gin.Context) {
+	var req struct {
+		Passphrase    string `json:"passphrase" binding:"required"`
+		NewPassphrase string `json:"new_passphrase" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.config.Vault.ChangePassphrase(req.Passphrase, req.NewPassphrase); err != nil {
+		c.JSON(vaultErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	s.config.Logger.Info("vault: passphrase changed")
+	s.storeKeys(req.NewPassphrase, true)
+	c.JSON(http.StatusOK, gin.H{"key_id": s.config.Vault.KeyID()})
+}
+
+func (s *Server) handleVaultLock(c *gin.Context) {
+	s.config.Vault.Lock()
+	c.JSON(http.StatusOK, gin.H{"unlocked": false})
+}
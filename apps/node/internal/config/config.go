@@ -0,0 +1,435 @@
+// Package config loads the node's configuration: the mesh settings of
+// infrastructure/mesh-config/bootstrap.yaml, plus the node's own settings,
+// overridden in turn by environment variables and command-line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/multiformats/go-multiaddr"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the whole node configuration. Its YAML form is bootstrap.yaml,
+// with an optional node section.
+type Config struct {
+	Node      Node      `yaml:"node"`
+	Network   Network   `yaml:"network"`
+	Security  Security  `yaml:"security"`
+	Resources Resources `yaml:"resources"`
+	Routing   Routing   `yaml:"routing"`
+	Regional  Regional  `yaml:"regional"`
+}
+
+// Node holds the settings of this node rather than of the mesh. Each can
+// also be set by an environment variable or a flag.
+type Node struct {
+	APIPort int    `yaml:"api_port"`
+	P2PPort int    `yaml:"p2p_port"`
+	DataDir string `yaml:"data_dir"`
+	// IPFSAPI is the host:port of the IPFS daemon's API.
+	IPFSAPI      string        `yaml:"ipfs_api"`
+	ProofWorkers int           `yaml:"proof_workers"`
+	MaxProofAge  time.Duration `yaml:"max_proof_age"`
+	// Plonk lists the proof types proved with PLONK instead of Groth16.
+	Plonk    []string `yaml:"plonk"`
+	PlonkSRS string   `yaml:"plonk_srs"`
+	// FrontendOrigins are the web app origins whose pages may call the API,
+	// such as https://lairik-pulse.vercel.app.
+	FrontendOrigins []string `yaml:"frontend_origins"`
+	// APIToken, if set, lets requests from other machines reach the vault
+	// and recovery routes. It is a secret, so only API_TOKEN sets it.
+	APIToken string `yaml:"-"`
+}
+
+type Network struct {
+	Name           string          `yaml:"name"`
+	Version        string          `yaml:"version"`
+	Region         string          `yaml:"region"`
+	BootstrapPeers []BootstrapPeer `yaml:"bootstrap_peers"`
+	// Protocols documents the protocols the mesh speaks; the node does not
+	// act on it.
+	Protocols []string  `yaml:"protocols"`
+	Discovery Discovery `yaml:"discovery"`
+}
+
+// BootstrapPeer is a peer the node dials at start, and again while it is out
+// of reach.
+type BootstrapPeer struct {
+	ID      string `yaml:"id"`
+	Address string `yaml:"address"`
+	Region  string `yaml:"region"`
+}
+
+type Discovery struct {
+	MDNS struct {
+		Enabled bool `yaml:"enabled"`
+		// Interval is accepted for compatibility; libp2p's mDNS keeps its
+		// own schedule.
+		Interval Seconds `yaml:"interval"`
+	} `yaml:"mdns"`
+	DHT struct {
+		Enabled bool   `yaml:"enabled"`
+		Mode    string `yaml:"mode"`
+	} `yaml:"dht"`
+	// Rendezvous, when enabled, names the mDNS service, so that only nodes
+	// of the same mesh find each other.
+	Rendezvous struct {
+		Enabled bool   `yaml:"enabled"`
+		String  string `yaml:"string"`
+	} `yaml:"rendezvous"`
+}
+
+type Security struct {
+	Noise struct {
+		Enabled  bool   `yaml:"enabled"`
+		Protocol string `yaml:"protocol"`
+	} `yaml:"noise"`
+	// PrivateNetwork limits the mesh to nodes holding the same swarm key,
+	// given inline or as a file in the IPFS swarm.key format.
+	PrivateNetwork struct {
+		Enabled      bool   `yaml:"enabled"`
+		SwarmKey     string `yaml:"swarm_key"`
+		SwarmKeyFile string `yaml:"swarm_key_file"`
+	} `yaml:"private_network"`
+}
+
+type Resources struct {
+	MaxConnections int `yaml:"max_connections"`
+	// MaxPeers is how many connections the node trims back to once it has
+	// more than MaxConnections.
+	MaxPeers          int     `yaml:"max_peers"`
+	ConnectionTimeout Seconds `yaml:"connection_timeout"`
+}
+
+type Routing struct {
+	GossipSub struct {
+		// Enabled false falls back to FloodSub.
+		Enabled           bool    `yaml:"enabled"`
+		HeartbeatInterval Seconds `yaml:"heartbeat_interval"`
+		HistoryLength     int     `yaml:"history_length"`
+		HistoryGossip     int     `yaml:"history_gossip"`
+	} `yaml:"gossipsub"`
+	// MessageTTL is how long a mesh message is remembered, so that it is
+	// not delivered twice.
+	MessageTTL Seconds `yaml:"message_ttl"`
+}
+
+type Regional struct {
+	PrimaryLanguage    string          `yaml:"primary_language"`
+	SupportedLanguages []string        `yaml:"supported_languages"`
+	EmergencyNodes     []EmergencyNode `yaml:"emergency_nodes"`
+}
+
+// EmergencyNode is a relief point the node never disconnects from.
+type EmergencyNode struct {
+	Name     string `yaml:"name"`
+	Location string `yaml:"location"`
+	PeerID   string `yaml:"peer_id"`
+}
+
+// Seconds is a duration written as a whole number of seconds.
+type Seconds int
+
+func (s Seconds) Duration() time.Duration { return time.Duration(s) * time.Second }
+
+// Default returns the configuration of a node started without a config
+// file, environment variables or flags.
+func Default() *Config {
+	c := &Config{
+		Node: Node{
+			APIPort:      8080,
+			DataDir:      "./data",
+			IPFSAPI:      "localhost:5001",
+			ProofWorkers: 1,
+			FrontendOrigins: []string{
+				"http://localhost:3000",
+				"http://localhost:3001",
+			},
+		},
+		Network: Network{Name: "lairik-pulse-mesh"},
+		Resources: Resources{
+			MaxConnections:    100,
+			MaxPeers:          50,
+			ConnectionTimeout: 30,
+		},
+	}
+	c.Network.Discovery.MDNS.Enabled = true
+	c.Network.Discovery.MDNS.Interval = 30
+	c.Network.Discovery.DHT.Mode = "client"
+	c.Security.Noise.Enabled = true
+	c.Routing.GossipSub.Enabled = true
+	c.Routing.GossipSub.HeartbeatInterval = 1
+	c.Routing.GossipSub.HistoryLength = 5
+	c.Routing.GossipSub.HistoryGossip = 3
+	c.Routing.MessageTTL = 120
+	return c
+}
+
+// Load reads the YAML file at path over the defaults; an empty path gives
+// the defaults. Unknown keys are an error, so that a misspelt setting is
+// not silently ignored.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides node settings from environment variables, read with
+// getenv. Values that do not parse are reported rather than ignored.
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	var errs []error
+	setInt := func(dst *int, names ...string) {
+		for _, name := range names {
+			if v := getenv(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					continue
+				}
+				*dst = n
+			}
+		}
+	}
+	setInt(&c.Node.APIPort, "API_PORT", "PORT")
+	setInt(&c.Node.P2PPort, "P2P_PORT")
+	setInt(&c.Node.ProofWorkers, "PROOF_WORKERS")
+	if v := getenv("DATA_DIR"); v != "" {
+		c.Node.DataDir = v
+	}
+	if v := getenv("IPFS_API"); v != "" {
+		c.Node.IPFSAPI = v
+	}
+	if v := getenv("FRONTEND_ORIGINS"); v != "" {
+		c.Node.FrontendOrigins = SplitList(v)
+	}
+	if v := getenv("API_TOKEN"); v != "" {
+		c.Node.APIToken = v
+	}
+	if v := getenv("MAX_PROOF_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MAX_PROOF_AGE: %w", err))
+		} else {
+			c.Node.MaxProofAge = d
+		}
+	}
+	if v := getenv("SWARM_KEY_FILE"); v != "" {
+		c.Security.PrivateNetwork.Enabled = true
+		c.Security.PrivateNetwork.SwarmKey, c.Security.PrivateNetwork.SwarmKeyFile = "", v
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the configuration as a whole. It returns warnings for
+// settings the node accepts but cannot honour, and an error listing every
+// setting that is wrong.
+func (c *Config) Validate() (warnings []string, err error) {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	n := c.Node
+	if n.APIPort < 1 || n.APIPort > 65535 {
+		fail("node.api_port: %d is not a port", n.APIPort)
+	}
+	if n.P2PPort < 0 || n.P2PPort > 65535 {
+		fail("node.p2p_port: %d is not a port (0 picks one)", n.P2PPort)
+	}
+	if n.DataDir == "" {
+		fail("node.data_dir is required")
+	}
+	if n.IPFSAPI == "" {
+		fail("node.ipfs_api is required")
+	}
+	if n.ProofWorkers < 1 {
+		fail("node.proof_workers must be at least 1")
+	}
+	if n.MaxProofAge < 0 {
+		fail("node.max_proof_age must not be negative")
+	}
+	for _, origin := range n.FrontendOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			fail("node.frontend_origins: %q is not an origin such as https://example.org", origin)
+		}
+	}
+	if n.APIToken != "" && len(n.APIToken) < 16 {
+		fail("API_TOKEN must be at least 16 characters")
+	}
+
+	if _, err := c.BootstrapPeers(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, p := range c.Network.Protocols {
+		if !strings.HasPrefix(p, "/") {
+			fail("network.protocols: %q is not a protocol ID", p)
+		}
+	}
+	d := c.Network.Discovery
+	if d.MDNS.Enabled && d.MDNS.Interval <= 0 {
+		fail("network.discovery.mdns.interval must be positive")
+	}
+	if d.DHT.Mode != "client" && d.DHT.Mode != "server" {
+		fail("network.discovery.dht.mode must be client or server, not %q", d.DHT.Mode)
+	}
+	if d.DHT.Enabled {
+		warnings = append(warnings, "network.discovery.dht: this node has no DHT; it discovers peers by mDNS and bootstrap peers only")
+	}
+	if d.Rendezvous.Enabled && d.Rendezvous.String == "" {
+		fail("network.discovery.rendezvous.string is required when rendezvous is enabled")
+	}
+
+	if !c.Security.Noise.Enabled {
+		fail("security.noise.enabled: Noise is the only transport security and cannot be turned off")
+	}
+	if _, err := c.SwarmKey(); err != nil {
+		errs = append(errs, err)
+	}
+
+	r := c.Resources
+	if r.MaxConnections < 1 {
+		fail("resources.max_connections must be at least 1")
+	}
+	if r.MaxPeers < 1 || r.MaxPeers > r.MaxConnections {
+		fail("resources.max_peers must be between 1 and max_connections (%d)", r.MaxConnections)
+	}
+	if r.ConnectionTimeout <= 0 {
+		fail("resources.connection_timeout must be positive")
+	}
+
+	g := c.Routing.GossipSub
+	if g.Enabled {
+		if g.HeartbeatInterval <= 0 {
+			fail("routing.gossipsub.heartbeat_interval must be positive")
+		}
+		if g.HistoryGossip < 1 || g.HistoryGossip > g.HistoryLength {
+			fail("routing.gossipsub.history_gossip must be between 1 and history_length (%d)", g.HistoryLength)
+		}
+	}
+	if c.Routing.MessageTTL <= 0 {
+		fail("routing.message_ttl must be positive")
+	}
+
+	if _, err := c.EmergencyPeers(); err != nil {
+		errs = append(errs, err)
+	}
+	return warnings, errors.Join(errs...)
+}
+
+// BootstrapPeers resolves the bootstrap peers to dialable addresses. An
+// address may end in /p2p/<id> instead of giving the ID apart.
+func (c *Config) BootstrapPeers() ([]peer.AddrInfo, error) {
+	var peers []peer.AddrInfo
+	for i, p := range c.Network.BootstrapPeers {
+		addr, err := multiaddr.NewMultiaddr(p.Address)
+		if err != nil {
+			return nil, fmt.Errorf("network.bootstrap_peers[%d]: invalid address %q: %w", i, p.Address, err)
+		}
+		transport, id := peer.SplitAddr(addr)
+		if p.ID != "" {
+			given, err := peer.Decode(p.ID)
+			if err != nil {
+				return nil, fmt.Errorf("network.bootstrap_peers[%d]: invalid peer ID %q: %w", i, p.ID, err)
+			}
+			if id != "" && id != given {
+				return nil, fmt.Errorf("network.bootstrap_peers[%d]: address is for peer %s, not %s", i, id, given)
+			}
+			id = given
+		}
+		if id == "" {
+			return nil, fmt.Errorf("network.bootstrap_peers[%d]: peer ID is required", i)
+		}
+		peers = append(peers, peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{transport}})
+	}
+	return peers, nil
+}
+
+// EmergencyPeers returns the peer IDs of the emergency nodes.
+func (c *Config) EmergencyPeers() ([]peer.ID, error) {
+	var ids []peer.ID
+	for i, n := range c.Regional.EmergencyNodes {
+		id, err := peer.Decode(n.PeerID)
+		if err != nil {
+			return nil, fmt.Errorf("regional.emergency_nodes[%d] (%s): invalid peer ID %q: %w", i, n.Name, n.PeerID, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SwarmKey returns the private network key, or nil when the mesh is public.
+func (c *Config) SwarmKey() (pnet.PSK, error) {
+	p := c.Security.PrivateNetwork
+	if !p.Enabled {
+		return nil, nil
+	}
+	var key []byte
+	switch {
+	case (p.SwarmKey == "") == (p.SwarmKeyFile == ""):
+		return nil, errors.New("security.private_network: set exactly one of swarm_key and swarm_key_file")
+	case p.SwarmKeyFile != "":
+		data, err := os.ReadFile(p.SwarmKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("security.private_network.swarm_key_file: %w", err)
+		}
+		key = data
+	default:
+		key = []byte(p.SwarmKey)
+	}
+	psk, err := pnet.DecodeV1PSK(bytes.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("security.private_network: invalid swarm key: %w", err)
+	}
+	return psk, nil
+}
+
+// SplitList splits a comma-separated setting, dropping blanks.
+func SplitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// MDNSServiceTag is the mDNS service nodes of this mesh announce.
+func (c *Config) MDNSServiceTag() string {
+	if r := c.Network.Discovery.Rendezvous; r.Enabled {
+		return r.String
+	}
+	return ""
+}
+
+// GossipSubParams returns GossipSub's defaults tuned by routing.gossipsub.
+func (c *Config) GossipSubParams() *pubsub.GossipSubParams {
+	g := c.Routing.GossipSub
+	params := pubsub.DefaultGossipSubParams()
+	params.HeartbeatInterval = g.HeartbeatInterval.Duration()
+	params.HistoryLength = g.HistoryLength
+	params.HistoryGossip = g.HistoryGossip
+	return &params
+}
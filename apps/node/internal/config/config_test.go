@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadBootstrap loads the bootstrap.yaml shipped with the mesh, so that
+// a change to either the file or Config that breaks the other fails here
+// rather than at node start.
+func TestLoadBootstrap(t *testing.T) {
+	c, err := Load(filepath.Join("..", "..", "..", "..", "infrastructure", "mesh-config", "bootstrap.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Network.Name != "lairik-pulse-mesh" || c.Routing.MessageTTL != 300 {
+		t.Fatalf("network %q, message TTL %d", c.Network.Name, c.Routing.MessageTTL)
+	}
+	if _, err := c.BootstrapPeers(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.EmergencyPeers(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings the file leaves out keep their defaults.
+	if c.Node.APIPort != Default().Node.APIPort {
+		t.Fatalf("API port %d, want the default", c.Node.APIPort)
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bootstrap.yaml")
+	if err := os.WriteFile(path, []byte("network:\n  nmae: typo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("loaded a config with a misspelt key")
+	}
+}
@@ -1,10 +1,15 @@
 package database
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -15,6 +20,8 @@ import (
 type DB struct {
 	conn   *sql.DB
 	logger *logrus.Logger
+	// contentDir holds document ciphertext, one file per document.
+	contentDir string
 }
 
 // Open creates (or opens) the SQLite database at dataDir/lairik.db and runs migrations.
@@ -29,7 +36,13 @@ func Open(dataDir string, logger *logrus.Logger) (*DB, error) {
 		return nil, fmt.Errorf("failed to open sqlite: %w", err)
 	}
 
-	db := &DB{conn: conn, logger: logger}
+	contentDir := filepath.Join(dataDir, "documents")
+	if err := os.MkdirAll(contentDir, 0700); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create content dir: %w", err)
+	}
+
+	db := &DB{conn: conn, logger: logger, contentDir: contentDir}
 	if err := db.migrate(); err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("migration failed: %w", err)
@@ -56,6 +69,10 @@ CREATE TABLE IF NOT EXISTS documents (
 	cid TEXT,
 	encrypted INTEGER DEFAULT 0,
 	content BLOB,
+	commitment TEXT,
+	commitment_salt TEXT,
+	wrapped_key BLOB,
+	key_id TEXT,
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -69,6 +86,15 @@ CREATE TABLE IF NOT EXISTS document_metadata (
 	FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS document_signatures (
+	document_id TEXT PRIMARY KEY,
+	public_key TEXT NOT NULL,
+	statement TEXT NOT NULL,
+	signature BLOB NOT NULL,
+	signed_at DATETIME NOT NULL,
+	FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS proofs (
 	id TEXT PRIMARY KEY,
 	document_id TEXT NOT NULL,
@@ -78,10 +104,88 @@ CREATE TABLE IF NOT EXISTS proofs (
 	public_witness BLOB,
 	verification_time_ms INTEGER,
 	size_bytes INTEGER,
+	circuit_fingerprint TEXT,
+	backend TEXT,
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS issuers (
+	public_key TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS revocations (
+	id TEXT PRIMARY KEY,
+	issuer_public_key TEXT NOT NULL,
+	proof_hash TEXT,
+	document_commitment TEXT,
+	reason TEXT NOT NULL,
+	revoked_at INTEGER NOT NULL,
+	signature TEXT NOT NULL,
+	received_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS revocation_sync (
+	peer_id TEXT PRIMARY KEY,
+	cursor INTEGER NOT NULL DEFAULT 0,
+	synced_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS proof_jobs (
+	id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	proof_type TEXT NOT NULL,
+	request TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	stage TEXT,
+	result TEXT,
+	error TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	started_at DATETIME,
+	finished_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS vault_rotations (
+	id TEXT PRIMARY KEY,
+	from_key_id TEXT NOT NULL,
+	to_key_id TEXT NOT NULL,
+	reencrypt INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	total INTEGER NOT NULL DEFAULT 0,
+	done INTEGER NOT NULL DEFAULT 0,
+	error TEXT,
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS recovery_held_shares (
+	id TEXT PRIMARY KEY,
+	owner_peer_id TEXT NOT NULL,
+	key_id TEXT NOT NULL,
+	set_id TEXT NOT NULL,
+	share_index INTEGER NOT NULL,
+	threshold INTEGER NOT NULL,
+	total INTEGER NOT NULL,
+	label TEXT,
+	share TEXT NOT NULL,
+	manifest BLOB,
+	accepted INTEGER NOT NULL DEFAULT 0,
+	received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recovery_trustees (
+	set_id TEXT NOT NULL,
+	share_index INTEGER NOT NULL,
+	key_id TEXT NOT NULL,
+	peer_id TEXT NOT NULL,
+	sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (set_id, share_index)
+);
+
 CREATE TABLE IF NOT EXISTS peers (
 	id TEXT PRIMARY KEY,
 	addresses TEXT,
@@ -104,6 +208,8 @@ CREATE INDEX IF NOT EXISTS idx_documents_hash ON documents(hash);
 CREATE INDEX IF NOT EXISTS idx_documents_cid ON documents(cid);
 CREATE INDEX IF NOT EXISTS idx_proofs_document ON proofs(document_id);
 CREATE INDEX IF NOT EXISTS idx_peers_status ON peers(status);
+CREATE INDEX IF NOT EXISTS idx_revocations_issuer ON revocations(issuer_public_key);
+CREATE INDEX IF NOT EXISTS idx_proof_jobs_status ON proof_jobs(status, created_at);
 
 CREATE TRIGGER IF NOT EXISTS update_documents_timestamp
 AFTER UPDATE ON documents
@@ -111,7 +217,67 @@ BEGIN
 	UPDATE documents SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
 END;
 `
-	_, err := db.conn.Exec(schema)
+	if _, err := db.conn.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the first release; CREATE TABLE IF NOT EXISTS
+	// leaves existing databases without them.
+	for _, col := range []struct{ table, column, decl string }{
+		{"proofs", "circuit_fingerprint", "TEXT"},
+		{"proofs", "backend", "TEXT"},
+		{"documents", "commitment", "TEXT"},
+		{"documents", "commitment_salt", "TEXT"},
+		{"documents", "wrapped_key", "BLOB"},
+		{"documents", "key_id", "TEXT"},
+		{"documents", "content_file", "TEXT"},
+		// Shares held before deposits needed accepting were all taken on.
+		{"recovery_held_shares", "accepted", "INTEGER NOT NULL DEFAULT 1"},
+	} {
+		if err := db.addColumnIfMissing(col.table, col.column, col.decl); err != nil {
+			return err
+		}
+	}
+
+	// Jobs that ended before requests were cleared still hold theirs,
+	// claims included.
+	if _, err := db.conn.Exec(`UPDATE proof_jobs SET request = '' WHERE status NOT IN (?, ?) AND request != ''`, JobQueued, JobRunning); err != nil {
+		return err
+	}
+
+	_, err := db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_documents_key_id ON documents(key_id)`)
+	return err
+}
+
+// addColumnIfMissing adds column to table unless it already exists.
+func (db *DB) addColumnIfMissing(table, column, decl string) error {
+	rows, err := db.conn.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return fmt.Errorf("addColumnIfMissing: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return fmt.Errorf("addColumnIfMissing: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("addColumnIfMissing: %w", err)
+	}
+	rows.Close()
+
+	_, err = db.conn.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
 	return err
 }
 
@@ -126,18 +292,31 @@ type DocumentRecord struct {
 	Hash      string
 	CID       string
 	Encrypted bool
-	Content   []byte
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	// Content is the ciphertext of documents stored before content files;
+	// later ones keep it in ContentFile (see OpenDocumentContent).
+	Content     []byte
+	ContentFile string
+	// Commitment is the document commitment every proof over the document
+	// publishes; CommitmentSalt is the secret that blinds it. Both are
+	// decimal field elements, empty for documents stored before commitments.
+	Commitment     string
+	CommitmentSalt string
+	// WrappedKey is the document's own key, wrapped by the vault master key
+	// KeyID. It is nil for documents encrypted under the legacy vault key.
+	WrappedKey []byte
+	KeyID      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 // AddDocument inserts a document into the database.
 func (db *DB) AddDocument(doc DocumentRecord) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO documents (id, name, type, size, hash, cid, encrypted, content, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO documents (id, name, type, size, hash, cid, encrypted, content, content_file, commitment, commitment_salt, wrapped_key, key_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		doc.ID, doc.Name, doc.Type, doc.Size, doc.Hash,
-		doc.CID, boolToInt(doc.Encrypted), doc.Content,
+		doc.CID, boolToInt(doc.Encrypted), doc.Content, nullIfEmpty(doc.ContentFile),
+		doc.Commitment, doc.CommitmentSalt, doc.WrappedKey, nullIfEmpty(doc.KeyID),
 		doc.CreatedAt, doc.UpdatedAt,
 	)
 	if err != nil {
@@ -146,10 +325,11 @@ func (db *DB) AddDocument(doc DocumentRecord) error {
 	return nil
 }
 
-// GetDocument retrieves a document by ID including its content.
+// GetDocument retrieves a document by ID including its content column.
 func (db *DB) GetDocument(id string) (*DocumentRecord, error) {
 	row := db.conn.QueryRow(`
-		SELECT id, name, type, size, hash, COALESCE(cid,''), encrypted, content, created_at, updated_at
+		SELECT id, name, type, size, hash, COALESCE(cid,''), encrypted, content, COALESCE(content_file,''),
+			COALESCE(commitment,''), COALESCE(commitment_salt,''), wrapped_key, COALESCE(key_id,''), created_at, updated_at
 		FROM documents WHERE id = ?`, id)
 	return scanDocument(row)
 }
@@ -157,7 +337,8 @@ func (db *DB) GetDocument(id string) (*DocumentRecord, error) {
 // ListDocuments returns all document records (without content blobs).
 func (db *DB) ListDocuments() ([]DocumentRecord, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, name, type, size, hash, COALESCE(cid,''), encrypted, NULL, created_at, updated_at
+		SELECT id, name, type, size, hash, COALESCE(cid,''), encrypted, NULL, COALESCE(content_file,''),
+			COALESCE(commitment,''), NULL, NULL, COALESCE(key_id,''), created_at, updated_at
 		FROM documents ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("ListDocuments: %w", err)
@@ -175,10 +356,41 @@ func (db *DB) ListDocuments() ([]DocumentRecord, error) {
 	return docs, rows.Err()
 }
 
-// DeleteDocument removes a document by ID.
+// ListDocumentKeys returns every document record with its wrapped key and
+// commitment salt, but without content blobs.
+func (db *DB) ListDocumentKeys() ([]DocumentRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, name, type, size, hash, COALESCE(cid,''), encrypted, NULL, COALESCE(content_file,''),
+			COALESCE(commitment,''), COALESCE(commitment_salt,''), wrapped_key, COALESCE(key_id,''), created_at, updated_at
+		FROM documents ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("ListDocumentKeys: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []DocumentRecord
+	for rows.Next() {
+		doc, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+	return docs, rows.Err()
+}
+
+// DeleteDocument removes a document by ID, and its content file.
 func (db *DB) DeleteDocument(id string) error {
-	_, err := db.conn.Exec(`DELETE FROM documents WHERE id = ?`, id)
-	return err
+	var file sql.NullString
+	err := db.conn.QueryRow(`SELECT content_file FROM documents WHERE id = ?`, id).Scan(&file)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("DeleteDocument: %w", err)
+	}
+	if _, err := db.conn.Exec(`DELETE FROM documents WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("DeleteDocument: %w", err)
+	}
+	db.RemoveContentFile(file.String)
+	return nil
 }
 
 // UpdateDocumentCID sets the IPFS CID on a document.
@@ -187,6 +399,329 @@ func (db *DB) UpdateDocumentCID(id, cid string) error {
 	return err
 }
 
+// SetDocumentCommitment records the commitment and salt of a document stored
+// before commitments existed.
+func (db *DB) SetDocumentCommitment(id, commitment, salt string) error {
+	_, err := db.conn.Exec(`UPDATE documents SET commitment = ?, commitment_salt = ? WHERE id = ?`, commitment, salt, id)
+	if err != nil {
+		return fmt.Errorf("SetDocumentCommitment: %w", err)
+	}
+	return nil
+}
+
+// LegacyDocumentIDs lists the encrypted documents that have no wrapped key
+// yet, i.e. those still under the legacy vault key.
+func (db *DB) LegacyDocumentIDs() ([]string, error) {
+	rows, err := db.conn.Query(`SELECT id FROM documents WHERE encrypted = 1 AND wrapped_key IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("LegacyDocumentIDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("LegacyDocumentIDs: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReplaceDocumentContent points a document at the re-encrypted content file
+// contentFile, with the key that opens it, wrapped by master key keyID, and
+// the CID it was re-added to IPFS under. The old content is removed only once
+// nothing refers to it.
+func (db *DB) ReplaceDocumentContent(id, contentFile string, wrappedKey []byte, keyID, cid string) error {
+	var old sql.NullString
+	if err := db.conn.QueryRow(`SELECT content_file FROM documents WHERE id = ?`, id).Scan(&old); err != nil {
+		return fmt.Errorf("ReplaceDocumentContent: %w", err)
+	}
+	_, err := db.conn.Exec(`UPDATE documents SET content = NULL, content_file = ?, wrapped_key = ?, key_id = ?, cid = ? WHERE id = ?`,
+		contentFile, wrappedKey, nullIfEmpty(keyID), cid, id)
+	if err != nil {
+		return fmt.Errorf("ReplaceDocumentContent: %w", err)
+	}
+	if old.String != contentFile {
+		db.RemoveContentFile(old.String)
+	}
+	return nil
+}
+
+// CacheDocumentContent records contentFile, fetched from IPFS, as the content
+// of a document that had none. It reports false, and removes the file, if the
+// document has content by now.
+func (db *DB) CacheDocumentContent(id, contentFile string) (bool, error) {
+	res, err := db.conn.Exec(`UPDATE documents SET content_file = ? WHERE id = ? AND content IS NULL AND content_file IS NULL`,
+		contentFile, id)
+	if err != nil {
+		return false, fmt.Errorf("CacheDocumentContent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("CacheDocumentContent: %w", err)
+	}
+	if n == 0 {
+		db.RemoveContentFile(contentFile)
+	}
+	return n > 0, nil
+}
+
+// RewrapDocumentKey replaces the wrapped key of a document whose key is still
+// wrapped by master key fromKeyID. It reports false if it no longer is.
+func (db *DB) RewrapDocumentKey(id, fromKeyID string, wrappedKey []byte, toKeyID string) (bool, error) {
+	res, err := db.conn.Exec(`UPDATE documents SET wrapped_key = ?, key_id = ? WHERE id = ? AND key_id = ?`,
+		wrappedKey, toKeyID, id, fromKeyID)
+	if err != nil {
+		return false, fmt.Errorf("RewrapDocumentKey: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("RewrapDocumentKey: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DocumentIDsByKey lists the documents whose key is wrapped by master key
+// keyID.
+func (db *DB) DocumentIDsByKey(keyID string) ([]string, error) {
+	rows, err := db.conn.Query(`SELECT id FROM documents WHERE key_id = ? ORDER BY created_at, id`, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("DocumentIDsByKey: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("DocumentIDsByKey: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AssignDocumentKeyID records master key keyID on wrapped document keys
+// stored before key IDs were.
+func (db *DB) AssignDocumentKeyID(keyID string) (int64, error) {
+	res, err := db.conn.Exec(`UPDATE documents SET key_id = ? WHERE wrapped_key IS NOT NULL AND key_id IS NULL`, keyID)
+	if err != nil {
+		return 0, fmt.Errorf("AssignDocumentKeyID: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ─── Document Content ─────────────────────────────────────────────────────
+
+// ErrNoContent is returned for a document whose content is not on this node.
+var ErrNoContent = errors.New("document content is not stored on this node")
+
+// ContentWriter writes document ciphertext to a new content file. Nothing
+// refers to the file until its name is stored with a document.
+type ContentWriter struct {
+	f    *os.File
+	name string
+}
+
+// NewContentWriter creates a new content file for document id.
+func (db *DB) NewContentWriter(id string) (*ContentWriter, error) {
+	f, err := os.CreateTemp(db.contentDir, id+".*")
+	if err != nil {
+		return nil, fmt.Errorf("NewContentWriter: %w", err)
+	}
+	return &ContentWriter{f: f, name: filepath.Base(f.Name())}, nil
+}
+
+func (w *ContentWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+// Commit flushes the file to disk and returns its name.
+func (w *ContentWriter) Commit() (string, error) {
+	if err := w.f.Sync(); err != nil {
+		w.Abort()
+		return "", fmt.Errorf("Commit: %w", err)
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return "", fmt.Errorf("Commit: %w", err)
+	}
+	return w.name, nil
+}
+
+// Abort removes the file.
+func (w *ContentWriter) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// OpenDocumentContent opens the stored ciphertext of doc: its content file,
+// or the content column for documents stored before content files. It
+// returns ErrNoContent if the node has neither.
+func (db *DB) OpenDocumentContent(doc *DocumentRecord) (io.ReadCloser, error) {
+	if doc.ContentFile != "" {
+		f, err := db.OpenContentFile(doc.ContentFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoContent
+		}
+		return f, err
+	}
+	if len(doc.Content) > 0 {
+		return io.NopCloser(bytes.NewReader(doc.Content)), nil
+	}
+	return nil, ErrNoContent
+}
+
+// OpenContentFile opens the content file name.
+func (db *DB) OpenContentFile(name string) (*os.File, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("OpenContentFile: invalid name %q", name)
+	}
+	return os.Open(filepath.Join(db.contentDir, name))
+}
+
+// RemoveContentFile removes the content file name, if any.
+func (db *DB) RemoveContentFile(name string) {
+	if name == "" || filepath.Base(name) != name {
+		return
+	}
+	if err := os.Remove(filepath.Join(db.contentDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		db.logger.Warnf("failed to remove content file %s: %v", name, err)
+	}
+}
+
+// ─── Metadata Repository ──────────────────────────────────────────────────
+
+// MetadataRecord mirrors the document_metadata table row.
+type MetadataRecord struct {
+	DocumentID  string
+	Title       string
+	Description string
+	Tags        []string
+	Custom      map[string]string
+}
+
+// SaveDocumentMetadata inserts or replaces a document's metadata.
+func (db *DB) SaveDocumentMetadata(m MetadataRecord) error {
+	tags, err := json.Marshal(m.Tags)
+	if err != nil {
+		return fmt.Errorf("SaveDocumentMetadata: %w", err)
+	}
+	custom, err := json.Marshal(m.Custom)
+	if err != nil {
+		return fmt.Errorf("SaveDocumentMetadata: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO document_metadata (document_id, title, description, tags, custom)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(document_id) DO UPDATE SET
+			title = excluded.title, description = excluded.description,
+			tags = excluded.tags, custom = excluded.custom`,
+		m.DocumentID, m.Title, m.Description, string(tags), string(custom),
+	)
+	if err != nil {
+		return fmt.Errorf("SaveDocumentMetadata: %w", err)
+	}
+	return nil
+}
+
+// GetDocumentMetadata retrieves a document's metadata. It returns an error
+// wrapping sql.ErrNoRows when the document has none.
+func (db *DB) GetDocumentMetadata(docID string) (*MetadataRecord, error) {
+	row := db.conn.QueryRow(`
+		SELECT document_id, COALESCE(title,''), COALESCE(description,''), COALESCE(tags,''), COALESCE(custom,'')
+		FROM document_metadata WHERE document_id = ?`, docID)
+
+	m := &MetadataRecord{}
+	var tags, custom string
+	if err := row.Scan(&m.DocumentID, &m.Title, &m.Description, &tags, &custom); err != nil {
+		return nil, fmt.Errorf("GetDocumentMetadata: %w", err)
+	}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &m.Tags); err != nil {
+			return nil, fmt.Errorf("GetDocumentMetadata: tags: %w", err)
+		}
+	}
+	if custom != "" {
+		if err := json.Unmarshal([]byte(custom), &m.Custom); err != nil {
+			return nil, fmt.Errorf("GetDocumentMetadata: custom: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// ─── Document Signature Repository ────────────────────────────────────────
+
+// DocumentSignatureRecord mirrors the document_signatures table row: the
+// holder's signature over a statement of the document's hash and metadata.
+type DocumentSignatureRecord struct {
+	DocumentID string
+	// PublicKey is the hex-encoded holder key that signed Statement.
+	PublicKey string
+	Statement string
+	Signature []byte
+	SignedAt  time.Time
+}
+
+// SaveDocumentSignature inserts or replaces a document's signature.
+func (db *DB) SaveDocumentSignature(r DocumentSignatureRecord) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO document_signatures (document_id, public_key, statement, signature, signed_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(document_id) DO UPDATE SET
+			public_key = excluded.public_key, statement = excluded.statement,
+			signature = excluded.signature, signed_at = excluded.signed_at`,
+		r.DocumentID, r.PublicKey, r.Statement, r.Signature, r.SignedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("SaveDocumentSignature: %w", err)
+	}
+	return nil
+}
+
+// GetDocumentSignature retrieves a document's signature. It returns an error
+// wrapping sql.ErrNoRows when the document has none.
+func (db *DB) GetDocumentSignature(docID string) (*DocumentSignatureRecord, error) {
+	r := &DocumentSignatureRecord{}
+	err := db.conn.QueryRow(`
+		SELECT document_id, public_key, statement, signature, signed_at
+		FROM document_signatures WHERE document_id = ?`, docID,
+	).Scan(&r.DocumentID, &r.PublicKey, &r.Statement, &r.Signature, &r.SignedAt)
+	if err != nil {
+		return nil, fmt.Errorf("GetDocumentSignature: %w", err)
+	}
+	return r, nil
+}
+
+// UnsignedDocumentIDs lists the documents not signed by the holder key
+// publicKey in a statement of at least version: those stored before holder
+// signatures, signed by a previous holder key, or in an older statement
+// format.
+func (db *DB) UnsignedDocumentIDs(publicKey string, version int) ([]string, error) {
+	rows, err := db.conn.Query(`
+		SELECT d.id FROM documents d
+		LEFT JOIN document_signatures s ON s.document_id = d.id
+		WHERE s.public_key IS NULL OR s.public_key != ?
+			OR COALESCE(json_extract(s.statement, '$.v'), 0) < ?`, publicKey, version)
+	if err != nil {
+		return nil, fmt.Errorf("UnsignedDocumentIDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("UnsignedDocumentIDs: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // ─── Proof Repository ─────────────────────────────────────────────────────
 
 // ProofRecord mirrors the proofs table row.
@@ -199,16 +734,21 @@ type ProofRecord struct {
 	PublicWitness    []byte
 	VerificationTime int64
 	SizeBytes        int
-	CreatedAt        time.Time
+	// CircuitFingerprint identifies the key set the proof was made with.
+	CircuitFingerprint string
+	// Backend is the proving system, groth16 for proofs stored before it
+	// was recorded.
+	Backend   string
+	CreatedAt time.Time
 }
 
 // SaveProof inserts a generated proof.
 func (db *DB) SaveProof(p ProofRecord) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO proofs (id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO proofs (id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, circuit_fingerprint, backend, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		p.ID, p.DocumentID, p.ProofHash, p.ProofType,
-		p.ProofData, p.PublicWitness, p.VerificationTime, p.SizeBytes, p.CreatedAt,
+		p.ProofData, p.PublicWitness, p.VerificationTime, p.SizeBytes, p.CircuitFingerprint, p.Backend, p.CreatedAt,
 	)
 	return err
 }
@@ -216,22 +756,56 @@ func (db *DB) SaveProof(p ProofRecord) error {
 // GetProofByHash retrieves a proof record by its hash.
 func (db *DB) GetProofByHash(hash string) (*ProofRecord, error) {
 	row := db.conn.QueryRow(`
-		SELECT id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, created_at
+		SELECT id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, COALESCE(circuit_fingerprint,''), COALESCE(backend,'groth16'), created_at
 		FROM proofs WHERE proof_hash = ?`, hash)
 
 	p := &ProofRecord{}
 	err := row.Scan(&p.ID, &p.DocumentID, &p.ProofHash, &p.ProofType,
-		&p.ProofData, &p.PublicWitness, &p.VerificationTime, &p.SizeBytes, &p.CreatedAt)
+		&p.ProofData, &p.PublicWitness, &p.VerificationTime, &p.SizeBytes, &p.CircuitFingerprint, &p.Backend, &p.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("GetProofByHash: %w", err)
 	}
 	return p, nil
 }
 
+// GetProofsByHashes retrieves the proofs with the given hashes in one query,
+// keyed by hash. Unknown hashes are left out.
+func (db *DB) GetProofsByHashes(hashes []string) (map[string]*ProofRecord, error) {
+	proofs := make(map[string]*ProofRecord, len(hashes))
+	if len(hashes) == 0 {
+		return proofs, nil
+	}
+
+	args := make([]any, len(hashes))
+	for i, h := range hashes {
+		args[i] = h
+	}
+	rows, err := db.conn.Query(`
+		SELECT id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, COALESCE(circuit_fingerprint,''), COALESCE(backend,'groth16'), created_at
+		FROM proofs WHERE proof_hash IN (?`+strings.Repeat(",?", len(hashes)-1)+`) ORDER BY rowid`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetProofsByHashes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := &ProofRecord{}
+		if err := rows.Scan(&p.ID, &p.DocumentID, &p.ProofHash, &p.ProofType,
+			&p.ProofData, &p.PublicWitness, &p.VerificationTime, &p.SizeBytes, &p.CircuitFingerprint, &p.Backend, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("GetProofsByHashes: %w", err)
+		}
+		// Like GetProofByHash, the first row wins if a hash repeats.
+		if _, seen := proofs[p.ProofHash]; !seen {
+			proofs[p.ProofHash] = p
+		}
+	}
+	return proofs, rows.Err()
+}
+
 // ListProofsByDocument returns all proofs for a given document.
 func (db *DB) ListProofsByDocument(docID string) ([]ProofRecord, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, created_at
+		SELECT id, document_id, proof_hash, proof_type, proof_data, public_witness, verification_time_ms, size_bytes, COALESCE(circuit_fingerprint,''), COALESCE(backend,'groth16'), created_at
 		FROM proofs WHERE document_id = ? ORDER BY created_at DESC`, docID)
 	if err != nil {
 		return nil, err
@@ -242,7 +816,7 @@ func (db *DB) ListProofsByDocument(docID string) ([]ProofRecord, error) {
 	for rows.Next() {
 		var p ProofRecord
 		if err := rows.Scan(&p.ID, &p.DocumentID, &p.ProofHash, &p.ProofType,
-			&p.ProofData, &p.PublicWitness, &p.VerificationTime, &p.SizeBytes, &p.CreatedAt); err != nil {
+			&p.ProofData, &p.PublicWitness, &p.VerificationTime, &p.SizeBytes, &p.CircuitFingerprint, &p.Backend, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		proofs = append(proofs, p)
@@ -250,6 +824,604 @@ func (db *DB) ListProofsByDocument(docID string) ([]ProofRecord, error) {
 	return proofs, rows.Err()
 }
 
+// ─── Issuer Repository ────────────────────────────────────────────────────
+
+// IssuerRecord mirrors the issuers table row: an institution whose degree
+// signatures this node recognises.
+type IssuerRecord struct {
+	PublicKey string
+	Name      string
+	CreatedAt time.Time
+}
+
+// SaveIssuer registers an issuer, renaming it if the key is already known.
+func (db *DB) SaveIssuer(i IssuerRecord) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO issuers (public_key, name, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(public_key) DO UPDATE SET name = excluded.name`,
+		i.PublicKey, i.Name, i.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("SaveIssuer: %w", err)
+	}
+	return nil
+}
+
+// GetIssuer retrieves a registered issuer by public key.
+func (db *DB) GetIssuer(publicKey string) (*IssuerRecord, error) {
+	row := db.conn.QueryRow(`SELECT public_key, name, created_at FROM issuers WHERE public_key = ?`, publicKey)
+
+	i := &IssuerRecord{}
+	if err := row.Scan(&i.PublicKey, &i.Name, &i.CreatedAt); err != nil {
+		return nil, fmt.Errorf("GetIssuer: %w", err)
+	}
+	return i, nil
+}
+
+// IsIssuer reports whether publicKey is a registered issuer.
+func (db *DB) IsIssuer(publicKey string) (bool, error) {
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM issuers WHERE public_key = ?`, publicKey).Scan(&n); err != nil {
+		return false, fmt.Errorf("IsIssuer: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteIssuer stops recognising the issuer with publicKey, wrapping
+// sql.ErrNoRows if it was not registered.
+func (db *DB) DeleteIssuer(publicKey string) error {
+	res, err := db.conn.Exec(`DELETE FROM issuers WHERE public_key = ?`, publicKey)
+	if err != nil {
+		return fmt.Errorf("DeleteIssuer: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("DeleteIssuer: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ListIssuers returns every registered issuer.
+func (db *DB) ListIssuers() ([]IssuerRecord, error) {
+	rows, err := db.conn.Query(`SELECT public_key, name, created_at FROM issuers ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("ListIssuers: %w", err)
+	}
+	defer rows.Close()
+
+	var issuers []IssuerRecord
+	for rows.Next() {
+		var i IssuerRecord
+		if err := rows.Scan(&i.PublicKey, &i.Name, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		issuers = append(issuers, i)
+	}
+	return issuers, rows.Err()
+}
+
+// ─── Revocation Repository ────────────────────────────────────────────────
+
+// RevocationRecord mirrors the revocations table row: an issuer-signed
+// revocation of one proof or of every proof over a document. Seq is the local
+// insertion order, which the mesh sync protocol pages by.
+type RevocationRecord struct {
+	Seq                int64
+	ID                 string
+	IssuerPublicKey    string
+	ProofHash          string
+	DocumentCommitment string
+	Reason             string
+	RevokedAt          int64
+	Signature          string
+	ReceivedAt         time.Time
+}
+
+// SaveRevocation stores a revocation, reporting false if it was already
+// known.
+func (db *DB) SaveRevocation(r RevocationRecord) (bool, error) {
+	res, err := db.conn.Exec(`
+		INSERT INTO revocations (id, issuer_public_key, proof_hash, document_commitment, reason, revoked_at, signature, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO NOTHING`,
+		r.ID, r.IssuerPublicKey, nullIfEmpty(r.ProofHash), nullIfEmpty(r.DocumentCommitment),
+		r.Reason, r.RevokedAt, r.Signature, time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("SaveRevocation: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SaveRevocation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CountUnregisteredRevocations counts the stored revocations whose issuer
+// is not registered.
+func (db *DB) CountUnregisteredRevocations() (int, error) {
+	var n int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM revocations
+		WHERE issuer_public_key NOT IN (SELECT public_key FROM issuers)`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("CountUnregisteredRevocations: %w", err)
+	}
+	return n, nil
+}
+
+// FindRevocation returns the earliest revocation by issuerPublicKey of the
+// proof with proofHash or of the document with commitment, or sql.ErrNoRows.
+func (db *DB) FindRevocation(issuerPublicKey, proofHash, commitment string) (*RevocationRecord, error) {
+	row := db.conn.QueryRow(`
+		SELECT rowid, id, issuer_public_key, COALESCE(proof_hash,''), COALESCE(document_commitment,''), reason, revoked_at, signature, received_at
+		FROM revocations
+		WHERE issuer_public_key = ? AND (proof_hash = ? OR document_commitment = ?)
+		ORDER BY revoked_at LIMIT 1`,
+		issuerPublicKey, nullIfEmpty(proofHash), nullIfEmpty(commitment))
+
+	r, err := scanRevocation(row)
+	if err != nil {
+		return nil, fmt.Errorf("FindRevocation: %w", err)
+	}
+	return r, nil
+}
+
+// ListRevocationsAfter returns up to limit revocations stored after seq, in
+// insertion order.
+func (db *DB) ListRevocationsAfter(seq int64, limit int) ([]RevocationRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT rowid, id, issuer_public_key, COALESCE(proof_hash,''), COALESCE(document_commitment,''), reason, revoked_at, signature, received_at
+		FROM revocations WHERE rowid > ? ORDER BY rowid LIMIT ?`, seq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListRevocationsAfter: %w", err)
+	}
+	defer rows.Close()
+
+	var revocations []RevocationRecord
+	for rows.Next() {
+		r, err := scanRevocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListRevocationsAfter: %w", err)
+		}
+		revocations = append(revocations, *r)
+	}
+	return revocations, rows.Err()
+}
+
+// RevocationSyncCursor returns the last Seq of peerID's registry this node
+// has caught up to, 0 if it never synced with the peer.
+func (db *DB) RevocationSyncCursor(peerID string) (int64, error) {
+	var cursor int64
+	err := db.conn.QueryRow(`SELECT cursor FROM revocation_sync WHERE peer_id = ?`, peerID).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("RevocationSyncCursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// SetRevocationSyncCursor records how far this node has synced peerID's
+// registry.
+func (db *DB) SetRevocationSyncCursor(peerID string, cursor int64) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO revocation_sync (peer_id, cursor, synced_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(peer_id) DO UPDATE SET cursor = excluded.cursor, synced_at = excluded.synced_at`,
+		peerID, cursor, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("SetRevocationSyncCursor: %w", err)
+	}
+	return nil
+}
+
+// ─── Proof Job Repository ─────────────────────────────────────────────────
+
+// Proof job statuses. Queued and running jobs are pending; the others are
+// final.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobStageSaving is the stage of a running job whose proof is being stored
+// and broadcast. The job can no longer be cancelled.
+const JobStageSaving = "saving"
+
+// ProofJobRecord mirrors the proof_jobs table row: a queued proof generation.
+// Request and Result hold the API request and response as JSON. The request
+// is cleared once the job ends.
+type ProofJobRecord struct {
+	ID         string
+	DocumentID string
+	ProofType  string
+	Request    string
+	Status     string
+	Stage      string
+	Result     string
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+const proofJobColumns = `id, document_id, proof_type, request, status, COALESCE(stage,''), COALESCE(result,''), COALESCE(error,''), created_at, started_at, finished_at`
+
+// CreateProofJob queues a proof job.
+func (db *DB) CreateProofJob(j ProofJobRecord) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO proof_jobs (id, document_id, proof_type, request, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		j.ID, j.DocumentID, j.ProofType, j.Request, JobQueued, j.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("CreateProofJob: %w", err)
+	}
+	return nil
+}
+
+// GetProofJob retrieves a proof job by ID.
+func (db *DB) GetProofJob(id string) (*ProofJobRecord, error) {
+	row := db.conn.QueryRow(`SELECT `+proofJobColumns+` FROM proof_jobs WHERE id = ?`, id)
+	j, err := scanProofJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("GetProofJob: %w", err)
+	}
+	return j, nil
+}
+
+// ListProofJobs returns the most recent proof jobs, optionally only those
+// with status.
+func (db *DB) ListProofJobs(status string, limit int) ([]ProofJobRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+proofJobColumns+` FROM proof_jobs
+		WHERE ? = '' OR status = ?
+		ORDER BY created_at DESC LIMIT ?`, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListProofJobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []ProofJobRecord
+	for rows.Next() {
+		j, err := scanProofJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListProofJobs: %w", err)
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimProofJob marks the oldest queued job as running and returns it, or
+// sql.ErrNoRows if the queue is empty. Concurrent callers never get the same
+// job.
+func (db *DB) ClaimProofJob() (*ProofJobRecord, error) {
+	row := db.conn.QueryRow(`
+		UPDATE proof_jobs SET status = ?, started_at = ?
+		WHERE id = (SELECT id FROM proof_jobs WHERE status = ? ORDER BY created_at, rowid LIMIT 1)
+		RETURNING `+proofJobColumns,
+		JobRunning, time.Now(), JobQueued)
+	j, err := scanProofJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("ClaimProofJob: %w", err)
+	}
+	return j, nil
+}
+
+// SetProofJobStage records the progress of a running job. It reports false
+// if the job is no longer running, e.g. because it was cancelled.
+func (db *DB) SetProofJobStage(id, stage string) (bool, error) {
+	res, err := db.conn.Exec(`UPDATE proof_jobs SET stage = ? WHERE id = ? AND status = ?`, stage, id, JobRunning)
+	if err != nil {
+		return false, fmt.Errorf("SetProofJobStage: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SetProofJobStage: %w", err)
+	}
+	return n > 0, nil
+}
+
+// FinishProofJob records the outcome of a running job. It reports false if
+// the job is no longer running, e.g. because it was cancelled.
+func (db *DB) FinishProofJob(id, status, result, errMsg string) (bool, error) {
+	res, err := db.conn.Exec(`
+		UPDATE proof_jobs SET status = ?, request = '', result = ?, error = ?, finished_at = ?
+		WHERE id = ? AND status = ?`,
+		status, nullIfEmpty(result), nullIfEmpty(errMsg), time.Now(), id, JobRunning,
+	)
+	if err != nil {
+		return false, fmt.Errorf("FinishProofJob: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("FinishProofJob: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CancelProofJob cancels a pending job. It reports false if the job had
+// already finished or reached JobStageSaving.
+func (db *DB) CancelProofJob(id string) (bool, error) {
+	res, err := db.conn.Exec(`
+		UPDATE proof_jobs SET status = ?, request = '', finished_at = ?
+		WHERE id = ? AND (status = ? OR (status = ? AND COALESCE(stage, '') != ?))`,
+		JobCancelled, time.Now(), id, JobQueued, JobRunning, JobStageSaving,
+	)
+	if err != nil {
+		return false, fmt.Errorf("CancelProofJob: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("CancelProofJob: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RequeueRunningProofJobs puts jobs that were running when the node stopped
+// back in the queue.
+func (db *DB) RequeueRunningProofJobs() (int64, error) {
+	res, err := db.conn.Exec(`UPDATE proof_jobs SET status = ?, stage = NULL, started_at = NULL WHERE status = ?`, JobQueued, JobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("RequeueRunningProofJobs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ─── Vault Rotation Repository ────────────────────────────────────────────
+
+// Vault rotation statuses. A running rotation stops when the vault is locked
+// or a document fails, and resumes on the next unlock.
+const (
+	RotationRunning = "running"
+	RotationDone    = "done"
+	RotationFailed  = "failed"
+)
+
+// VaultRotationRecord mirrors the vault_rotations table row: a move of every
+// document key from master key FromKeyID to ToKeyID. With Reencrypt, each
+// document also gets a new key and its content is encrypted again.
+type VaultRotationRecord struct {
+	ID         string
+	FromKeyID  string
+	ToKeyID    string
+	Reencrypt  bool
+	Status     string
+	Total      int
+	Done       int
+	Error      string
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+const vaultRotationColumns = `id, from_key_id, to_key_id, reencrypt, status, total, done, COALESCE(error,''), started_at, updated_at, finished_at`
+
+// CreateVaultRotation records a rotation that is about to start.
+func (db *DB) CreateVaultRotation(r VaultRotationRecord) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO vault_rotations (id, from_key_id, to_key_id, reencrypt, status, total, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.FromKeyID, r.ToKeyID, boolToInt(r.Reencrypt), RotationRunning, r.Total, r.StartedAt, r.StartedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("CreateVaultRotation: %w", err)
+	}
+	return nil
+}
+
+// LatestVaultRotation returns the most recent rotation, or sql.ErrNoRows if
+// the vault was never rotated.
+func (db *DB) LatestVaultRotation() (*VaultRotationRecord, error) {
+	row := db.conn.QueryRow(`SELECT ` + vaultRotationColumns + ` FROM vault_rotations ORDER BY started_at DESC, rowid DESC LIMIT 1`)
+	r, err := scanVaultRotation(row)
+	if err != nil {
+		return nil, fmt.Errorf("LatestVaultRotation: %w", err)
+	}
+	return r, nil
+}
+
+// SetVaultRotationProgress records how many documents a running rotation has
+// moved, and the last document error if any.
+func (db *DB) SetVaultRotationProgress(id string, total, done int, errMsg string) error {
+	_, err := db.conn.Exec(`
+		UPDATE vault_rotations SET total = ?, done = ?, error = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		total, done, nullIfEmpty(errMsg), time.Now(), id, RotationRunning)
+	if err != nil {
+		return fmt.Errorf("SetVaultRotationProgress: %w", err)
+	}
+	return nil
+}
+
+// FinishVaultRotation records the outcome of a running rotation.
+func (db *DB) FinishVaultRotation(id, status, errMsg string) error {
+	_, err := db.conn.Exec(`
+		UPDATE vault_rotations SET status = ?, error = ?, updated_at = ?, finished_at = ?
+		WHERE id = ? AND status = ?`,
+		status, nullIfEmpty(errMsg), time.Now(), time.Now(), id, RotationRunning)
+	if err != nil {
+		return fmt.Errorf("FinishVaultRotation: %w", err)
+	}
+	return nil
+}
+
+// ─── Recovery Share Repository ────────────────────────────────────────────
+
+// HeldShareRecord mirrors the recovery_held_shares table row: a share of
+// another holder's vault master key this node keeps as a trustee, with the
+// latest sealed manifest of that holder's documents.
+type HeldShareRecord struct {
+	ID          string
+	OwnerPeerID string
+	KeyID       string
+	SetID       string
+	Index       int
+	Threshold   int
+	Total       int
+	Label       string
+	Share       string
+	Manifest    []byte
+	// Accepted is false while the deposit waits for this node's user to
+	// agree to keep it.
+	Accepted   bool
+	ReceivedAt time.Time
+	UpdatedAt  time.Time
+}
+
+const heldShareColumns = `id, owner_peer_id, key_id, set_id, share_index, threshold, total, COALESCE(label,''), share, manifest, accepted, received_at, updated_at`
+
+// ErrHeldShareOwner is returned when a peer deposits a share under the ID of
+// a share another peer deposited.
+var ErrHeldShareOwner = errors.New("a share with this ID is held for another peer")
+
+// SaveHeldShare stores a share deposited with this node, replacing an
+// earlier copy of the same share from the same owner. A share of another
+// owner is left alone and ErrHeldShareOwner returned. Replacing a share
+// keeps whether it was accepted.
+func (db *DB) SaveHeldShare(h HeldShareRecord) error {
+	res, err := db.conn.Exec(`
+		INSERT INTO recovery_held_shares (id, owner_peer_id, key_id, set_id, share_index, threshold, total, label, share, manifest, accepted, received_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET label = excluded.label, share = excluded.share,
+			manifest = COALESCE(excluded.manifest, manifest), updated_at = excluded.updated_at
+		WHERE recovery_held_shares.owner_peer_id = excluded.owner_peer_id`,
+		h.ID, h.OwnerPeerID, h.KeyID, h.SetID, h.Index, h.Threshold, h.Total,
+		nullIfEmpty(h.Label), h.Share, h.Manifest, boolToInt(h.Accepted), h.ReceivedAt, h.ReceivedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("SaveHeldShare: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SaveHeldShare: %w", err)
+	}
+	if n == 0 {
+		return ErrHeldShareOwner
+	}
+	return nil
+}
+
+// CountPendingHeldShares returns how many deposits wait to be accepted, from
+// ownerPeerID and in all.
+func (db *DB) CountPendingHeldShares(ownerPeerID string) (fromOwner, total int, err error) {
+	err = db.conn.QueryRow(`
+		SELECT COALESCE(SUM(owner_peer_id = ?), 0), COUNT(*)
+		FROM recovery_held_shares WHERE accepted = 0`, ownerPeerID).Scan(&fromOwner, &total)
+	if err != nil {
+		return 0, 0, fmt.Errorf("CountPendingHeldShares: %w", err)
+	}
+	return fromOwner, total, nil
+}
+
+// AcceptHeldShare marks a deposited share as kept by this node's user.
+func (db *DB) AcceptHeldShare(id string) error {
+	_, err := db.conn.Exec(`UPDATE recovery_held_shares SET accepted = 1, updated_at = ? WHERE id = ?`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("AcceptHeldShare: %w", err)
+	}
+	return nil
+}
+
+// GetHeldShare retrieves a held share by ID.
+func (db *DB) GetHeldShare(id string) (*HeldShareRecord, error) {
+	row := db.conn.QueryRow(`SELECT `+heldShareColumns+` FROM recovery_held_shares WHERE id = ?`, id)
+	h, err := scanHeldShare(row)
+	if err != nil {
+		return nil, fmt.Errorf("GetHeldShare: %w", err)
+	}
+	return h, nil
+}
+
+// ListHeldShares returns the shares this node holds, newest first.
+func (db *DB) ListHeldShares() ([]HeldShareRecord, error) {
+	rows, err := db.conn.Query(`SELECT ` + heldShareColumns + ` FROM recovery_held_shares ORDER BY received_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("ListHeldShares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []HeldShareRecord
+	for rows.Next() {
+		h, err := scanHeldShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListHeldShares: %w", err)
+		}
+		shares = append(shares, *h)
+	}
+	return shares, rows.Err()
+}
+
+// SetHeldShareManifest replaces the manifest kept with the shares of master
+// key keyID deposited by ownerPeerID. It returns how many shares it updated.
+func (db *DB) SetHeldShareManifest(ownerPeerID, keyID string, manifest []byte) (int64, error) {
+	res, err := db.conn.Exec(`
+		UPDATE recovery_held_shares SET manifest = ?, updated_at = ?
+		WHERE owner_peer_id = ? AND key_id = ?`,
+		manifest, time.Now(), ownerPeerID, keyID)
+	if err != nil {
+		return 0, fmt.Errorf("SetHeldShareManifest: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteHeldShare removes a held share by ID.
+func (db *DB) DeleteHeldShare(id string) error {
+	_, err := db.conn.Exec(`DELETE FROM recovery_held_shares WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteHeldShare: %w", err)
+	}
+	return nil
+}
+
+// RecoveryTrusteeRecord mirrors the recovery_trustees table row: a peer this
+// node deposited a share of its master key with.
+type RecoveryTrusteeRecord struct {
+	SetID  string
+	Index  int
+	KeyID  string
+	PeerID string
+	SentAt time.Time
+}
+
+// AddRecoveryTrustee records that share index of set setID went to a peer.
+func (db *DB) AddRecoveryTrustee(t RecoveryTrusteeRecord) error {
+	_, err := db.conn.Exec(`
+		INSERT OR REPLACE INTO recovery_trustees (set_id, share_index, key_id, peer_id, sent_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		t.SetID, t.Index, t.KeyID, t.PeerID, t.SentAt)
+	if err != nil {
+		return fmt.Errorf("AddRecoveryTrustee: %w", err)
+	}
+	return nil
+}
+
+// RecoveryTrusteePeers lists the peers holding shares of master key keyID.
+func (db *DB) RecoveryTrusteePeers(keyID string) ([]string, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT peer_id FROM recovery_trustees WHERE key_id = ?`, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("RecoveryTrusteePeers: %w", err)
+	}
+	defer rows.Close()
+
+	var peers []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("RecoveryTrusteePeers: %w", err)
+		}
+		peers = append(peers, p)
+	}
+	return peers, rows.Err()
+}
+
 // ─── Peer Repository ─────────────────────────────────────────────────────
 
 // SavePeer upserts a peer record.
@@ -277,19 +1449,84 @@ type scanner interface {
 
 func scanDocument(s scanner) (*DocumentRecord, error) {
 	doc := &DocumentRecord{}
-	var enc int
+	var (
+		enc  int
+		salt sql.NullString
+	)
 	err := s.Scan(&doc.ID, &doc.Name, &doc.Type, &doc.Size, &doc.Hash,
-		&doc.CID, &enc, &doc.Content, &doc.CreatedAt, &doc.UpdatedAt)
+		&doc.CID, &enc, &doc.Content, &doc.ContentFile, &doc.Commitment, &salt, &doc.WrappedKey, &doc.KeyID, &doc.CreatedAt, &doc.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("scanDocument: %w", err)
 	}
 	doc.Encrypted = enc != 0
+	doc.CommitmentSalt = salt.String
 	return doc, nil
 }
 
+func scanRevocation(s scanner) (*RevocationRecord, error) {
+	r := &RevocationRecord{}
+	if err := s.Scan(&r.Seq, &r.ID, &r.IssuerPublicKey, &r.ProofHash, &r.DocumentCommitment,
+		&r.Reason, &r.RevokedAt, &r.Signature, &r.ReceivedAt); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func scanProofJob(s scanner) (*ProofJobRecord, error) {
+	j := &ProofJobRecord{}
+	var started, finished sql.NullTime
+	if err := s.Scan(&j.ID, &j.DocumentID, &j.ProofType, &j.Request, &j.Status, &j.Stage,
+		&j.Result, &j.Error, &j.CreatedAt, &started, &finished); err != nil {
+		return nil, err
+	}
+	if started.Valid {
+		j.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		j.FinishedAt = &finished.Time
+	}
+	return j, nil
+}
+
+func scanVaultRotation(s scanner) (*VaultRotationRecord, error) {
+	r := &VaultRotationRecord{}
+	var (
+		reencrypt int
+		finished  sql.NullTime
+	)
+	if err := s.Scan(&r.ID, &r.FromKeyID, &r.ToKeyID, &reencrypt, &r.Status, &r.Total, &r.Done,
+		&r.Error, &r.StartedAt, &r.UpdatedAt, &finished); err != nil {
+		return nil, err
+	}
+	r.Reencrypt = reencrypt != 0
+	if finished.Valid {
+		r.FinishedAt = &finished.Time
+	}
+	return r, nil
+}
+
+func scanHeldShare(s scanner) (*HeldShareRecord, error) {
+	h := &HeldShareRecord{}
+	var accepted int
+	if err := s.Scan(&h.ID, &h.OwnerPeerID, &h.KeyID, &h.SetID, &h.Index, &h.Threshold, &h.Total,
+		&h.Label, &h.Share, &h.Manifest, &accepted, &h.ReceivedAt, &h.UpdatedAt); err != nil {
+		return nil, err
+	}
+	h.Accepted = accepted != 0
+	return h, nil
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
 	}
 	return 0
 }
+
+// nullIfEmpty stores an empty string as NULL, so it never matches.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	db, err := Open(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSaveHeldShareOwner(t *testing.T) {
+	db := openTestDB(t)
+	share := HeldShareRecord{
+		ID:          "set-1",
+		OwnerPeerID: "owner",
+		KeyID:       "key",
+		SetID:       "set",
+		Index:       1,
+		Threshold:   2,
+		Total:       3,
+		Share:       "LPS1:ORIGINAL",
+		ReceivedAt:  time.Now(),
+	}
+	if err := db.SaveHeldShare(share); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AcceptHeldShare(share.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := share
+	forged.OwnerPeerID = "intruder"
+	forged.Share = "LPS1:FORGED"
+	if err := db.SaveHeldShare(forged); !errors.Is(err, ErrHeldShareOwner) {
+		t.Fatalf("deposit over another owner's share: got %v, want ErrHeldShareOwner", err)
+	}
+
+	// The owner may replace its own share, which stays accepted.
+	share.Share = "LPS1:RESENT"
+	if err := db.SaveHeldShare(share); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetHeldShare(share.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OwnerPeerID != "owner" || got.Share != "LPS1:RESENT" || !got.Accepted {
+		t.Fatalf("held share is %+v", got)
+	}
+}
+
+func TestCountPendingHeldShares(t *testing.T) {
+	db := openTestDB(t)
+	for i, owner := range []string{"a", "a", "b"} {
+		err := db.SaveHeldShare(HeldShareRecord{
+			ID: string(rune('x' + i)), OwnerPeerID: owner, KeyID: "k", SetID: "s",
+			Index: i + 1, Threshold: 2, Total: 3, Share: "LPS1:S", ReceivedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AcceptHeldShare("x"); err != nil {
+		t.Fatal(err)
+	}
+	fromA, total, err := db.CountPendingHeldShares("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromA != 1 || total != 2 {
+		t.Fatalf("pending from a %d, in all %d; want 1 and 2", fromA, total)
+	}
+}
+
+func TestUnsignedDocumentIDs(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	for _, id := range []string{"unsigned", "old-key", "v1", "current"} {
+		if err := db.AddDocument(DocumentRecord{ID: id, Name: id, Hash: "hash-" + id, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range []DocumentSignatureRecord{
+		{DocumentID: "old-key", PublicKey: "old", Statement: `{"v":2}`},
+		{DocumentID: "v1", PublicKey: "holder", Statement: `{"v":1}`},
+		{DocumentID: "current", PublicKey: "holder", Statement: `{"v":2}`},
+	} {
+		r.Signature, r.SignedAt = []byte("sig"), now
+		if err := db.SaveDocumentSignature(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, err := db.UnsignedDocumentIDs("holder", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, id := range ids {
+		got[id] = true
+	}
+	if len(ids) != 3 || !got["unsigned"] || !got["old-key"] || !got["v1"] {
+		t.Fatalf("unsigned documents %v, want unsigned, old-key and v1", ids)
+	}
+}
+
+func TestCancelProofJob(t *testing.T) {
+	db := openTestDB(t)
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := db.CreateProofJob(ProofJobRecord{ID: id, DocumentID: "doc-1", ProofType: "identity", Request: `{"claims":{"birth_date":"1990-04-01"}}`, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A running job can be cancelled until it starts saving its proof.
+	job, err := db.ClaimProofJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running, err := db.SetProofJobStage(job.ID, "proving"); err != nil || !running {
+		t.Fatalf("proving stage: running %v, %v", running, err)
+	}
+	if cancelled, err := db.CancelProofJob(job.ID); err != nil || !cancelled {
+		t.Fatalf("cancel while proving: cancelled %v, %v", cancelled, err)
+	}
+	if running, err := db.SetProofJobStage(job.ID, JobStageSaving); err != nil || running {
+		t.Fatalf("saving stage of a cancelled job: running %v, %v", running, err)
+	}
+
+	job, err = db.ClaimProofJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running, err := db.SetProofJobStage(job.ID, JobStageSaving); err != nil || !running {
+		t.Fatalf("saving stage: running %v, %v", running, err)
+	}
+	if cancelled, err := db.CancelProofJob(job.ID); err != nil || cancelled {
+		t.Fatalf("cancel while saving: cancelled %v, %v", cancelled, err)
+	}
+	if finished, err := db.FinishProofJob(job.ID, JobDone, "{}", ""); err != nil || !finished {
+		t.Fatalf("finish: finished %v, %v", finished, err)
+	}
+
+	// Ended jobs forget their requests.
+	for _, id := range []string{"job-1", "job-2"} {
+		j, err := db.GetProofJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Request != "" {
+			t.Fatalf("%s (%s) kept its request %q", id, j.Status, j.Request)
+		}
+	}
+}
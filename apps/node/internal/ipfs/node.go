@@ -12,7 +12,10 @@ import (
 
 type Config struct {
 	RepoPath string
-	Logger   *logrus.Logger
+	// APIAddr is the host:port of the IPFS daemon's API; empty means
+	// localhost:5001.
+	APIAddr string
+	Logger  *logrus.Logger
 }
 
 type Node struct {
@@ -25,7 +28,11 @@ type Node struct {
 func NewNode(ctx context.Context, cfg Config) (*Node, error) {
 	nodeCtx, cancel := context.WithCancel(ctx)
 
-	sh := shell.NewShell("localhost:5001")
+	addr := cfg.APIAddr
+	if addr == "" {
+		addr = "localhost:5001"
+	}
+	sh := shell.NewShell(addr)
 
 	node := &Node{
 		sh:     sh,
@@ -56,11 +63,16 @@ func (n *Node) Stop() error {
 }
 
 func (n *Node) Add(data []byte) (string, error) {
+	return n.AddReader(bytes.NewReader(data))
+}
+
+// AddReader adds everything read from r, streaming it to the daemon.
+func (n *Node) AddReader(r io.Reader) (string, error) {
 	if !n.sh.IsUp() {
 		return "", fmt.Errorf("IPFS daemon not available")
 	}
 
-	cid, err := n.sh.Add(bytes.NewReader(data))
+	cid, err := n.sh.Add(r)
 	if err != nil {
 		return "", fmt.Errorf("failed to add to IPFS: %w", err)
 	}
@@ -69,6 +81,17 @@ func (n *Node) Add(data []byte) (string, error) {
 }
 
 func (n *Node) Get(cid string) ([]byte, error) {
+	reader, err := n.Cat(cid)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Cat streams the content of cid; the caller closes it.
+func (n *Node) Cat(cid string) (io.ReadCloser, error) {
 	if !n.sh.IsUp() {
 		return nil, fmt.Errorf("IPFS daemon not available")
 	}
@@ -78,7 +101,7 @@ func (n *Node) Get(cid string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to get from IPFS: %w", err)
 	}
 
-	return io.ReadAll(reader)
+	return reader, nil
 }
 
 func (n *Node) Pin(cid string) error {
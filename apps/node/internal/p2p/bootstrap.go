@@ -0,0 +1,38 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// bootstrapRetry is how often bootstrap peers out of reach are dialled
+// again. Offline-first meshes come and go, so the node keeps trying.
+const bootstrapRetry = time.Minute
+
+// bootstrap dials the configured bootstrap peers until the node stops.
+func (n *Node) bootstrap() {
+	if len(n.config.BootstrapPeers) == 0 {
+		return
+	}
+	ticker := time.NewTicker(bootstrapRetry)
+	defer ticker.Stop()
+	for {
+		for _, pi := range n.config.BootstrapPeers {
+			if !n.Connected(pi.ID) {
+				go n.connect(pi)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+// Connected reports whether the node has a connection to id.
+func (n *Node) Connected(id peer.ID) bool {
+	return n.host.Network().Connectedness(id) == network.Connected
+}
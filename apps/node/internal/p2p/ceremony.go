@@ -0,0 +1,218 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lairik-pulse/node/internal/zkp"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+)
+
+// CeremonyProtocol carries trusted-setup contributions between a ceremony
+// coordinator and remote participants. Each stream holds one request: a JSON
+// header line, optionally followed by Size bytes of ceremony state.
+const CeremonyProtocol = protocol.ID("/lairik/ceremony/1.0.0")
+
+const (
+	// maxCeremonyState bounds the payload a coordinator will accept.
+	maxCeremonyState = 256 << 20
+	// maxCeremonyHeader bounds the JSON header line, which any peer can
+	// send without ever ending it.
+	maxCeremonyHeader = 64 << 10
+	ceremonyTimeout   = 10 * time.Minute
+)
+
+type ceremonyRequest struct {
+	Op      string `json:"op"` // "fetch" or "submit"
+	Name    string `json:"name,omitempty"`
+	Phase   int    `json:"phase,omitempty"`
+	BasedOn int    `json:"based_on,omitempty"`
+	Size    int    `json:"size,omitempty"`
+}
+
+type ceremonyResponse struct {
+	Phase        int                       `json:"phase,omitempty"`
+	Index        int                       `json:"index,omitempty"`
+	Size         int                       `json:"size,omitempty"`
+	Contribution *zkp.CeremonyContribution `json:"contribution,omitempty"`
+	Error        string                    `json:"error,omitempty"`
+}
+
+// ServeCeremony makes this node the coordinator of c: peers can fetch the
+// latest state and submit contributions, which are verified before they are
+// appended to the transcript.
+func (n *Node) ServeCeremony(c *zkp.Ceremony) {
+	n.host.SetStreamHandler(CeremonyProtocol, func(s network.Stream) {
+		defer s.Close()
+		s.SetDeadline(time.Now().Add(ceremonyTimeout))
+
+		r := bufio.NewReader(s)
+		var req ceremonyRequest
+		if err := readCeremonyHeader(r, &req); err != nil {
+			writeCeremonyHeader(s, ceremonyResponse{Error: err.Error()})
+			return
+		}
+
+		switch req.Op {
+		case "fetch":
+			phase, index, state, err := c.Latest()
+			if err != nil {
+				writeCeremonyHeader(s, ceremonyResponse{Error: err.Error()})
+				return
+			}
+			if err := writeCeremonyHeader(s, ceremonyResponse{Phase: phase, Index: index, Size: len(state)}); err != nil {
+				return
+			}
+			s.Write(state)
+		case "submit":
+			if req.Size <= 0 || req.Size > maxCeremonyState {
+				writeCeremonyHeader(s, ceremonyResponse{Error: "invalid contribution size"})
+				return
+			}
+			state := make([]byte, req.Size)
+			if _, err := io.ReadFull(r, state); err != nil {
+				writeCeremonyHeader(s, ceremonyResponse{Error: "failed to read contribution: " + err.Error()})
+				return
+			}
+			name := fmt.Sprintf("%s (%s)", req.Name, s.Conn().RemotePeer())
+			entry, err := c.Submit(name, req.Phase, req.BasedOn, state)
+			if err != nil {
+				n.config.Logger.Warnf("Rejected ceremony contribution from %s: %v", s.Conn().RemotePeer(), err)
+				writeCeremonyHeader(s, ceremonyResponse{Error: err.Error()})
+				return
+			}
+			n.config.Logger.Infof("Accepted ceremony contribution %d from %s", entry.Index, name)
+			writeCeremonyHeader(s, ceremonyResponse{Phase: entry.Phase, Index: entry.Index, Contribution: entry})
+		default:
+			writeCeremonyHeader(s, ceremonyResponse{Error: fmt.Sprintf("unknown op %q", req.Op)})
+		}
+	})
+}
+
+// ContributeRemote joins the ceremony coordinated by the peer at addr (a
+// multiaddr ending in /p2p/<id>), adds local randomness to its latest state
+// and submits the result.
+func ContributeRemote(ctx context.Context, addr, name string) (*zkp.CeremonyContribution, error) {
+	info, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coordinator address: %w", err)
+	}
+
+	h, err := libp2p.New(
+		libp2p.NoListenAddrs,
+		libp2p.Security(noise.ID, noise.New),
+		libp2p.Transport(tcp.NewTCPTransport),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host: %w", err)
+	}
+	defer h.Close()
+
+	if err := h.Connect(ctx, *info); err != nil {
+		return nil, fmt.Errorf("failed to reach coordinator: %w", err)
+	}
+
+	resp, state, err := ceremonyRoundTrip(ctx, h, info.ID, ceremonyRequest{Op: "fetch"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	contribution, err := zkp.ContributeState(resp.Phase, state)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err = ceremonyRoundTrip(ctx, h, info.ID, ceremonyRequest{
+		Op:      "submit",
+		Name:    name,
+		Phase:   resp.Phase,
+		BasedOn: resp.Index,
+		Size:    len(contribution),
+	}, contribution)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Contribution, nil
+}
+
+func ceremonyRoundTrip(ctx context.Context, h host.Host, p peer.ID, req ceremonyRequest, payload []byte) (*ceremonyResponse, []byte, error) {
+	s, err := h.NewStream(ctx, p, CeremonyProtocol)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open ceremony stream: %w", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(ceremonyTimeout))
+
+	if err := writeCeremonyHeader(s, req); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.Write(payload); err != nil {
+		return nil, nil, fmt.Errorf("failed to send contribution: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		return nil, nil, fmt.Errorf("failed to send contribution: %w", err)
+	}
+
+	r := bufio.NewReader(s)
+	var resp ceremonyResponse
+	if err := readCeremonyHeader(r, &resp); err != nil {
+		return nil, nil, err
+	}
+	if resp.Error != "" {
+		return nil, nil, fmt.Errorf("coordinator: %s", resp.Error)
+	}
+	if resp.Size < 0 || resp.Size > maxCeremonyState {
+		return nil, nil, fmt.Errorf("coordinator sent invalid state size %d", resp.Size)
+	}
+
+	state := make([]byte, resp.Size)
+	if _, err := io.ReadFull(r, state); err != nil {
+		return nil, nil, fmt.Errorf("failed to read ceremony state: %w", err)
+	}
+	return &resp, state, nil
+}
+
+// readCeremonyHeader reads the header line, of at most maxCeremonyHeader
+// bytes, leaving the state that follows it in r.
+func readCeremonyHeader(r *bufio.Reader, v interface{}) error {
+	var line []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		line = append(line, frag...)
+		if len(line) > maxCeremonyHeader {
+			return fmt.Errorf("ceremony header exceeds %d bytes", maxCeremonyHeader)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("failed to read ceremony header: %w", err)
+		}
+	}
+	if err := json.Unmarshal(line, v); err != nil {
+		return fmt.Errorf("invalid ceremony header: %w", err)
+	}
+	return nil
+}
+
+func writeCeremonyHeader(w io.Writer, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write ceremony header: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadCeremonyHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(`{"op":"submit","size":5}` + "\nstate"))
+	var req ceremonyRequest
+	if err := readCeremonyHeader(r, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Op != "submit" || req.Size != 5 {
+		t.Fatalf("header %+v", req)
+	}
+	state, err := io.ReadAll(r)
+	if err != nil || string(state) != "state" {
+		t.Fatalf("state after the header: %q, %v", state, err)
+	}
+
+	// A header that never ends is cut off at the limit.
+	endless := io.MultiReader(strings.NewReader(`{"op":"`), bytes.NewReader(bytes.Repeat([]byte("a"), 2*maxCeremonyHeader)))
+	if err := readCeremonyHeader(bufio.NewReader(endless), &req); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("endless header: got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// The node's peer ID comes from a private key kept in the data directory, so
+// that peers recognise it, and the trust they keep for it, across restarts.
+
+// IdentityPath is where the peer key of the node with data directory
+// dataDir is kept, in libp2p's protobuf encoding.
+func IdentityPath(dataDir string) string {
+	return filepath.Join(dataDir, "identity", "peer.key")
+}
+
+// LoadIdentity reads the peer key of dataDir, creating an Ed25519 key on
+// first start. created reports whether it was.
+func LoadIdentity(dataDir string) (key crypto.PrivKey, created bool, err error) {
+	path := IdentityPath(dataDir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to generate peer key: %w", err)
+		}
+		if err := SaveIdentity(dataDir, key); err != nil {
+			return nil, false, err
+		}
+		return key, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read peer key: %w", err)
+	}
+	key, err = crypto.UnmarshalPrivateKey(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid peer key %s: %w", path, err)
+	}
+	return key, false, nil
+}
+
+// nodeIdentity returns the peer key a node runs with: that of its data
+// directory, or a new one kept in memory if it has none.
+func nodeIdentity(cfg Config) (crypto.PrivKey, error) {
+	if cfg.DataDir == "" {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate peer key: %w", err)
+		}
+		return key, nil
+	}
+	key, created, err := LoadIdentity(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		cfg.Logger.Infof("Created peer key %s", IdentityPath(cfg.DataDir))
+	}
+	return key, nil
+}
+
+// SaveIdentity makes key the peer key of dataDir, replacing any key there.
+func SaveIdentity(dataDir string, key crypto.PrivKey) error {
+	data, err := crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode peer key: %w", err)
+	}
+	path := IdentityPath(dataDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write peer key: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write peer key: %w", err)
+	}
+	return nil
+}
+
+// RotateIdentity replaces the peer key of dataDir with a new one, keeping
+// the old key next to it as peer.key.prev until the new one is in the key
+// ring, and returns both peer IDs.
+func RotateIdentity(dataDir string) (oldID, newID peer.ID, err error) {
+	old, _, err := LoadIdentity(dataDir)
+	if err != nil {
+		return "", "", err
+	}
+	if oldID, err = peer.IDFromPrivateKey(old); err != nil {
+		return "", "", err
+	}
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate peer key: %w", err)
+	}
+	if newID, err = peer.IDFromPrivateKey(key); err != nil {
+		return "", "", err
+	}
+
+	path := IdentityPath(dataDir)
+	prev, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read peer key: %w", err)
+	}
+	if err := os.WriteFile(path+".prev", prev, 0600); err != nil {
+		return "", "", fmt.Errorf("failed to keep the old peer key: %w", err)
+	}
+	if err := SaveIdentity(dataDir, key); err != nil {
+		return "", "", err
+	}
+	return oldID, newID, nil
+}
+
+// DiscardPreviousIdentity removes the peer.key.prev RotateIdentity left in
+// dataDir once stored, a copy of the key ring's peer key, is the peer key of
+// dataDir: the old key then no longer guards against losing the new one.
+// peer.key stays the key the node runs with; the ring only keeps a copy.
+func DiscardPreviousIdentity(dataDir string, stored crypto.PrivKey) error {
+	prev := IdentityPath(dataDir) + ".prev"
+	if _, err := os.Stat(prev); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	current, _, err := LoadIdentity(dataDir)
+	if err != nil {
+		return err
+	}
+	if !current.Equals(stored) {
+		return nil
+	}
+	if err := os.Remove(prev); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove the old peer key: %w", err)
+	}
+	return nil
+}
+
+// IdentitySeed returns the Ed25519 seed of an Ed25519 peer key, the form the
+// key ring keeps it in.
+func IdentitySeed(key crypto.PrivKey) ([]byte, error) {
+	if key.Type() != crypto.Ed25519 {
+		return nil, fmt.Errorf("peer key is %s, not Ed25519", key.Type())
+	}
+	raw, err := key.Raw()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(raw)
+	return append([]byte{}, raw[:ed25519.SeedSize]...), nil
+}
+
+// IdentityFromSeed rebuilds an Ed25519 peer key from its seed.
+func IdentityFromSeed(seed []byte) (crypto.PrivKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("peer key must be a %d-byte Ed25519 seed", ed25519.SeedSize)
+	}
+	return crypto.UnmarshalEd25519PrivateKey(ed25519.NewKeyFromSeed(seed))
+}
+
+// PrivateKey returns the key the node's peer ID comes from.
+func (n *Node) PrivateKey() crypto.PrivKey {
+	return n.host.Peerstore().PrivKey(n.host.ID())
+}
+
+// DiscardPreviousIdentity removes the node's peer.key.prev once the key the
+// node runs with is its peer key on disk; see DiscardPreviousIdentity.
+func (n *Node) DiscardPreviousIdentity() error {
+	if n.config.DataDir == "" {
+		return nil
+	}
+	return DiscardPreviousIdentity(n.config.DataDir, n.PrivateKey())
+}
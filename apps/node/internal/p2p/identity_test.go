@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRotateIdentity(t *testing.T) {
+	dataDir := t.TempDir()
+	old, created, err := LoadIdentity(dataDir)
+	if err != nil || !created {
+		t.Fatalf("first load: created %v, %v", created, err)
+	}
+	oldID, newID, err := RotateIdentity(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := peer.IDFromPrivateKey(old); oldID != want || newID == oldID {
+		t.Fatalf("rotated from %s to %s, want from %s", oldID, newID, want)
+	}
+	key, created, err := LoadIdentity(dataDir)
+	if err != nil || created {
+		t.Fatalf("load after rotation: created %v, %v", created, err)
+	}
+	if id, _ := peer.IDFromPrivateKey(key); id != newID {
+		t.Fatalf("peer.key holds %s, want %s", id, newID)
+	}
+
+	// The old key stays until the ring holds the one on disk.
+	prev := IdentityPath(dataDir) + ".prev"
+	if err := DiscardPreviousIdentity(dataDir, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(prev); err != nil {
+		t.Fatalf("old key removed while the ring holds another: %v", err)
+	}
+	if err := DiscardPreviousIdentity(dataDir, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(prev); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("old key kept after the new one was stored: %v", err)
+	}
+	if err := DiscardPreviousIdentity(dataDir, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdentitySeed(t *testing.T) {
+	key, _, err := LoadIdentity(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := IdentitySeed(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := IdentityFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equals(key) {
+		t.Fatal("key rebuilt from its seed differs")
+	}
+	if _, err := IdentityFromSeed(seed[1:]); err == nil {
+		t.Fatal("accepted a short seed")
+	}
+}
+
+func TestNodeIdentity(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	start := func(dataDir string) string {
+		t.Helper()
+		n, err := NewNode(context.Background(), Config{DataDir: dataDir, Logger: log})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer n.Stop()
+		return n.ID()
+	}
+
+	dataDir := t.TempDir()
+	if first, again := start(dataDir), start(dataDir); first != again {
+		t.Fatalf("peer ID changed across restarts: %s, then %s", first, again)
+	}
+
+	// Without a data directory the key stays in memory.
+	t.Chdir(t.TempDir())
+	if first, again := start(""), start(""); first == again {
+		t.Fatal("nodes without a data directory share a peer ID")
+	}
+	if entries, err := os.ReadDir("."); err != nil || len(entries) != 0 {
+		t.Fatalf("node without a data directory wrote %v, %v", entries, err)
+	}
+}
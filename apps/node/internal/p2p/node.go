@@ -3,54 +3,136 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/zkp"
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/sirupsen/logrus"
 )
 
 type Config struct {
-	Port    int
+	Port int
+	// DataDir keeps the peer key. Without one the node runs under a new
+	// key each time and writes nothing to disk, as a ceremony coordinator.
 	DataDir string
 	Logger  *logrus.Logger
+	// DB holds the revocation registry the node gossips and syncs.
+	DB *database.DB
+
+	// BootstrapPeers are dialled at start, and again while out of reach.
+	BootstrapPeers []peer.AddrInfo
+	// ProtectedPeers, such as emergency nodes, are never trimmed by the
+	// connection manager.
+	ProtectedPeers []peer.ID
+	// ServiceTag is the mDNS service to announce and look for; empty means
+	// "lairik-pulse".
+	ServiceTag string
+	// DisableMDNS turns off discovery on the local network.
+	DisableMDNS bool
+	// PrivateNetwork, if set, only lets in peers holding the same swarm key.
+	PrivateNetwork pnet.PSK
+	// LowConnections and HighConnections are the connection manager's
+	// watermarks; zero keeps libp2p's.
+	LowConnections  int
+	HighConnections int
+	// DialTimeout bounds each outgoing connection; zero keeps libp2p's.
+	DialTimeout time.Duration
+	// FloodSub broadcasts with FloodSub instead of GossipSub.
+	FloodSub bool
+	// GossipSub tunes GossipSub; nil keeps its defaults.
+	GossipSub *pubsub.GossipSubParams
+	// SeenMessagesTTL is how long a message is remembered so that it is
+	// not delivered twice; zero keeps the default.
+	SeenMessagesTTL time.Duration
 }
 
 type Node struct {
 	host             host.Host
 	pubsub           *pubsub.PubSub
 	topic            *pubsub.Topic
+	revocationTopic  *pubsub.Topic
 	config           Config
 	ctx              context.Context
 	cancel           context.CancelFunc
 	VerificationMsgs chan []byte
 	PeerJoined       chan string
+	// Revocations announces revocations new to this node, from the mesh or
+	// published locally.
+	Revocations chan *zkp.Revocation
+	// ReturnedShares delivers vault recovery shares trustees sent back.
+	ReturnedShares chan *ReturnedShare
+
+	returnsMu   sync.Mutex
+	returnsFrom map[string]bool
 }
 
 func NewNode(ctx context.Context, cfg Config) (*Node, error) {
+	key, err := nodeIdentity(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	nodeCtx, cancel := context.WithCancel(ctx)
 
 	// Create libp2p host
-	h, err := libp2p.New(
+	opts := []libp2p.Option{
+		libp2p.Identity(key),
 		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.Port)),
 		libp2p.Security(noise.ID, noise.New),
 		libp2p.Transport(tcp.NewTCPTransport),
 		libp2p.NATPortMap(),
 		libp2p.EnableRelay(),
-	)
+	}
+	if cfg.PrivateNetwork != nil {
+		opts = append(opts, libp2p.PrivateNetwork(cfg.PrivateNetwork))
+	}
+	if cfg.HighConnections > 0 {
+		cm, err := connmgr.NewConnManager(cfg.LowConnections, cfg.HighConnections)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create connection manager: %w", err)
+		}
+		opts = append(opts, libp2p.ConnectionManager(cm))
+	}
+	if cfg.DialTimeout > 0 {
+		opts = append(opts, libp2p.WithDialTimeout(cfg.DialTimeout))
+	}
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create host: %w", err)
 	}
+	for _, id := range cfg.ProtectedPeers {
+		h.ConnManager().Protect(id, "protected")
+	}
 
-	ps, err := pubsub.NewGossipSub(nodeCtx, h)
+	var psOpts []pubsub.Option
+	if cfg.SeenMessagesTTL > 0 {
+		psOpts = append(psOpts, pubsub.WithSeenMessagesTTL(cfg.SeenMessagesTTL))
+	}
+	var ps *pubsub.PubSub
+	if cfg.FloodSub {
+		ps, err = pubsub.NewFloodSub(nodeCtx, h, psOpts...)
+	} else {
+		if cfg.GossipSub != nil {
+			psOpts = append(psOpts, pubsub.WithGossipSubParams(*cfg.GossipSub))
+		}
+		ps, err = pubsub.NewGossipSub(nodeCtx, h, psOpts...)
+	}
 	if err != nil {
+		h.Close()
 		cancel()
-		return nil, fmt.Errorf("failed to create gossipsub: %w", err)
+		return nil, fmt.Errorf("failed to create pubsub: %w", err)
 	}
 
 	node := &Node{
@@ -61,6 +143,8 @@ func NewNode(ctx context.Context, cfg Config) (*Node, error) {
 		cancel:           cancel,
 		VerificationMsgs: make(chan []byte, 100),
 		PeerJoined:       make(chan string, 100),
+		Revocations:      make(chan *zkp.Revocation, 100),
+		ReturnedShares:   make(chan *ReturnedShare, 16),
 	}
 
 	return node, nil
@@ -100,11 +184,23 @@ func (n *Node) Start() error {
 		}
 	}()
 
+	if err := n.startRevocations(); err != nil {
+		return err
+	}
+	n.host.SetStreamHandler(RecoveryProtocol, n.serveRecovery)
+
 	// Setup mDNS discovery
-	mdnsService := mdns.NewMdnsService(n.host, "lairik-pulse", &discoveryNotifee{n: n})
-	if err := mdnsService.Start(); err != nil {
-		return fmt.Errorf("failed to start mDNS: %w", err)
+	if !n.config.DisableMDNS {
+		tag := n.config.ServiceTag
+		if tag == "" {
+			tag = "lairik-pulse"
+		}
+		mdnsService := mdns.NewMdnsService(n.host, tag, &discoveryNotifee{n: n})
+		if err := mdnsService.Start(); err != nil {
+			return fmt.Errorf("failed to start mDNS: %w", err)
+		}
 	}
+	go n.bootstrap()
 
 	// Keep the node running
 	<-n.ctx.Done()
@@ -120,6 +216,15 @@ func (n *Node) ID() string {
 	return n.host.ID().String()
 }
 
+// Addrs returns the full multiaddrs (including /p2p/<id>) peers can dial.
+func (n *Node) Addrs() []string {
+	addrs := make([]string, 0, len(n.host.Addrs()))
+	for _, a := range n.host.Addrs() {
+		addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", a, n.host.ID()))
+	}
+	return addrs
+}
+
 func (n *Node) Peers() []peer.ID {
 	return n.host.Network().Peers()
 }
@@ -137,16 +242,21 @@ type discoveryNotifee struct {
 
 func (d *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	d.n.config.Logger.Infof("Discovered peer: %s", pi.ID.String())
-	
-	// Connect to discovered peer
-	if err := d.n.host.Connect(d.n.ctx, pi); err != nil {
-		d.n.config.Logger.Warnf("Failed to connect to peer %s: %v", pi.ID.String(), err)
-	} else {
-		d.n.config.Logger.Infof("Connected to peer: %s", pi.ID.String())
-		// Notify WS via channel non-blocking
-		select {
-		case d.n.PeerJoined <- pi.ID.String():
-		default:
-		}
+	d.n.connect(pi)
+}
+
+// connect dials a peer and, once connected, syncs revocations with it.
+func (n *Node) connect(pi peer.AddrInfo) error {
+	if err := n.host.Connect(n.ctx, pi); err != nil {
+		n.config.Logger.Warnf("Failed to connect to peer %s: %v", pi.ID.String(), err)
+		return err
+	}
+	n.config.Logger.Infof("Connected to peer: %s", pi.ID.String())
+	go n.syncRevocations(pi.ID)
+	// Notify WS via channel non-blocking
+	select {
+	case n.PeerJoined <- pi.ID.String():
+	default:
 	}
+	return nil
 }
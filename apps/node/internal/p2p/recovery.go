@@ -0,0 +1,239 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lairik-pulse/node/internal/database"
+	cryptopkg "github.com/lairik-pulse/node/pkg/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// RecoveryProtocol carries vault recovery shares between a holder and the
+// trusted peers keeping them. The sender writes one JSON message and the
+// receiver answers with one JSON acknowledgement:
+//
+//   - deposit: a holder hands a share of its master key, with the sealed
+//     manifest of its documents, to a trustee;
+//   - manifest: a holder sends its trustees a newer manifest;
+//   - return: a trustee hands a share back to the holder's new device.
+//
+// Deposits stay pending until the trustee's user accepts them, and trustees
+// only ever return a share when their user asks them to. A new device takes
+// returned shares only while its user is recovering, and only from the
+// trustees they named.
+const RecoveryProtocol = protocol.ID("/lairik/recovery/1.0.0")
+
+const (
+	recoveryDeposit  = "deposit"
+	recoveryManifest = "manifest"
+	recoveryReturn   = "return"
+
+	// maxRecoveryMessage bounds a message, and so the manifest kept with a
+	// share, to some ten thousand documents.
+	maxRecoveryMessage = 4 << 20
+	recoveryTimeout    = time.Minute
+
+	// Deposits nobody has accepted yet are capped, so that a neighbour on
+	// the mesh cannot fill the disk with them.
+	maxPendingSharesPerPeer = 4
+	maxPendingShares        = 32
+)
+
+type recoveryMessage struct {
+	Type     string `json:"type"`
+	Share    string `json:"share,omitempty"`
+	KeyID    string `json:"key_id,omitempty"`
+	Label    string `json:"label,omitempty"`
+	Manifest []byte `json:"manifest,omitempty"`
+}
+
+type recoveryAck struct {
+	Error string `json:"error,omitempty"`
+}
+
+// ReturnedShare is a recovery share a trustee sent back to this node.
+type ReturnedShare struct {
+	From     string
+	Share    *cryptopkg.RecoveryShare
+	Manifest []byte
+}
+
+func (n *Node) serveRecovery(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(recoveryTimeout))
+
+	var msg recoveryMessage
+	line, err := bufio.NewReader(io.LimitReader(s, maxRecoveryMessage)).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &msg)
+	}
+	if err != nil {
+		json.NewEncoder(s).Encode(recoveryAck{Error: "invalid recovery message"})
+		return
+	}
+
+	from := s.Conn().RemotePeer().String()
+	if err := n.handleRecoveryMessage(from, &msg); err != nil {
+		n.config.Logger.Warnf("Recovery %s from %s: %v", msg.Type, from, err)
+		json.NewEncoder(s).Encode(recoveryAck{Error: err.Error()})
+		return
+	}
+	json.NewEncoder(s).Encode(recoveryAck{})
+}
+
+func (n *Node) handleRecoveryMessage(from string, msg *recoveryMessage) error {
+	switch msg.Type {
+	case recoveryDeposit:
+		share, err := cryptopkg.ParseRecoveryShare(msg.Share)
+		if err != nil {
+			return err
+		}
+		id := fmt.Sprintf("%s-%d", share.SetID, share.Index)
+		held, err := n.config.DB.GetHeldShare(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			fromPeer, total, err := n.config.DB.CountPendingHeldShares(from)
+			if err != nil {
+				return err
+			}
+			if fromPeer >= maxPendingSharesPerPeer || total >= maxPendingShares {
+				return fmt.Errorf("too many shares waiting to be accepted, try again later")
+			}
+		} else if err != nil {
+			return err
+		}
+		now := time.Now()
+		err = n.config.DB.SaveHeldShare(database.HeldShareRecord{
+			ID:          id,
+			OwnerPeerID: from,
+			KeyID:       share.KeyID,
+			SetID:       share.SetID,
+			Index:       share.Index,
+			Threshold:   share.Threshold,
+			Total:       share.Total,
+			Label:       msg.Label,
+			Share:       msg.Share,
+			Manifest:    msg.Manifest,
+			ReceivedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+		if held != nil && held.Accepted {
+			n.config.Logger.Infof("Holding recovery share %d/%d of %s for %s", share.Index, share.Total, share.KeyID, from)
+		} else {
+			n.config.Logger.Infof("Recovery share %s from %s waits to be accepted with POST /recovery/held/%s/accept", id, from, id)
+		}
+		return nil
+
+	case recoveryManifest:
+		// Only the peer that deposited the shares may replace their
+		// manifest.
+		updated, err := n.config.DB.SetHeldShareManifest(from, msg.KeyID, msg.Manifest)
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return fmt.Errorf("no shares of key %s held for %s", msg.KeyID, from)
+		}
+		return nil
+
+	case recoveryReturn:
+		if !n.expectsReturnFrom(from) {
+			return fmt.Errorf("not expecting recovery shares from %s", from)
+		}
+		share, err := cryptopkg.ParseRecoveryShare(msg.Share)
+		if err != nil {
+			return err
+		}
+		select {
+		case n.ReturnedShares <- &ReturnedShare{From: from, Share: share, Manifest: msg.Manifest}:
+			return nil
+		default:
+			return fmt.Errorf("too many shares pending, try again")
+		}
+	}
+	return fmt.Errorf("unknown recovery message %q", msg.Type)
+}
+
+// ExpectReturns makes the node take returned shares from the trustees
+// peers, and from no one else, until StopReturns.
+func (n *Node) ExpectReturns(peers []string) {
+	n.returnsMu.Lock()
+	defer n.returnsMu.Unlock()
+	n.returnsFrom = make(map[string]bool, len(peers))
+	for _, p := range peers {
+		n.returnsFrom[p] = true
+	}
+}
+
+// StopReturns refuses returned shares again.
+func (n *Node) StopReturns() {
+	n.returnsMu.Lock()
+	defer n.returnsMu.Unlock()
+	n.returnsFrom = nil
+}
+
+func (n *Node) expectsReturnFrom(from string) bool {
+	n.returnsMu.Lock()
+	defer n.returnsMu.Unlock()
+	return n.returnsFrom[from]
+}
+
+// DepositRecoveryShare hands share, in text form, to the trustee peerID along
+// with the sealed manifest of this node's documents.
+func (n *Node) DepositRecoveryShare(peerID, share, label string, manifest []byte) error {
+	return n.sendRecovery(peerID, &recoveryMessage{Type: recoveryDeposit, Share: share, Label: label, Manifest: manifest})
+}
+
+// SendRecoveryManifest sends the trustee peerID a newer manifest for the
+// shares of master key keyID it holds.
+func (n *Node) SendRecoveryManifest(peerID, keyID string, manifest []byte) error {
+	return n.sendRecovery(peerID, &recoveryMessage{Type: recoveryManifest, KeyID: keyID, Manifest: manifest})
+}
+
+// ReturnRecoveryShare hands a held share and its manifest back to the
+// holder's device peerID.
+func (n *Node) ReturnRecoveryShare(peerID, share string, manifest []byte) error {
+	return n.sendRecovery(peerID, &recoveryMessage{Type: recoveryReturn, Share: share, Manifest: manifest})
+}
+
+func (n *Node) sendRecovery(peerID string, msg *recoveryMessage) error {
+	p, err := peer.Decode(peerID)
+	if err != nil {
+		return fmt.Errorf("invalid peer ID: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, recoveryTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, p, RecoveryProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", p, err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(recoveryTimeout))
+
+	if err := json.NewEncoder(s).Encode(msg); err != nil {
+		return fmt.Errorf("failed to send recovery %s: %w", msg.Type, err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		return fmt.Errorf("failed to send recovery %s: %w", msg.Type, err)
+	}
+
+	var ack recoveryAck
+	if err := json.NewDecoder(io.LimitReader(s, maxRecoveryMessage)).Decode(&ack); err != nil {
+		return fmt.Errorf("invalid recovery response: %w", err)
+	}
+	if ack.Error != "" {
+		return fmt.Errorf("peer: %s", ack.Error)
+	}
+	return nil
+}
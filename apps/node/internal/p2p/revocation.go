@@ -0,0 +1,300 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/zkp"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// RevocationTopic gossips new revocations to the mesh, next to
+// verification-pulse.
+const RevocationTopic = "revocation-pulse"
+
+// RevocationSyncProtocol lets a node that was offline catch up on the
+// revocations it missed. The requester sends one JSON line asking for the
+// entries the responder stored after a cursor; the responder answers with
+// one page and the cursor to ask from next.
+const RevocationSyncProtocol = protocol.ID("/lairik/revocations/1.0.0")
+
+// maxUnregisteredRevocations caps the revocations kept from issuers this
+// node has not registered. Anyone can sign with a fresh key, so without it
+// one peer could fill every node's registry; those from registered issuers
+// are always kept.
+var maxUnregisteredRevocations = 1000
+
+// errUnregisteredFull drops a revocation of an unregistered issuer once
+// maxUnregisteredRevocations are kept.
+var errUnregisteredFull = errors.New("too many revocations of unregistered issuers kept")
+
+const (
+	revocationPageSize     = 256
+	maxRevocationPage      = 1 << 20
+	revocationSyncTimeout  = time.Minute
+	revocationSyncInterval = 10 * time.Minute
+)
+
+type revocationSyncRequest struct {
+	After int64 `json:"after"`
+}
+
+type revocationSyncResponse struct {
+	Revocations []zkp.Revocation `json:"revocations"`
+	Next        int64            `json:"next"`
+	More        bool             `json:"more"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// startRevocations joins the revocation topic and serves the sync protocol.
+func (n *Node) startRevocations() error {
+	if err := n.pubsub.RegisterTopicValidator(RevocationTopic, n.validateRevocationMsg); err != nil {
+		return fmt.Errorf("failed to register revocation validator: %w", err)
+	}
+	topic, err := n.pubsub.Join(RevocationTopic)
+	if err != nil {
+		return fmt.Errorf("failed to join revocation topic: %w", err)
+	}
+	n.revocationTopic = topic
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to revocations: %w", err)
+	}
+	go func() {
+		for {
+			msg, err := sub.Next(n.ctx)
+			if err != nil {
+				return
+			}
+			if msg.ReceivedFrom == n.host.ID() {
+				continue
+			}
+			var r zkp.Revocation
+			if err := json.Unmarshal(msg.Data, &r); err != nil {
+				continue
+			}
+			if _, err := n.storeRevocation(&r, true); err != nil {
+				n.config.Logger.Warnf("Dropped revocation from %s: %v", msg.ReceivedFrom, err)
+			}
+		}
+	}()
+
+	n.host.SetStreamHandler(RevocationSyncProtocol, n.serveRevocationSync)
+
+	go func() {
+		ticker := time.NewTicker(revocationSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, p := range n.Peers() {
+					n.syncRevocations(p)
+				}
+			case <-n.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// validateRevocationMsg keeps unsigned or malformed revocations from being
+// forwarded, and only forwards those of registered issuers. Others still
+// reach nodes that register their issuer through the sync protocol.
+func (n *Node) validateRevocationMsg(_ context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	var r zkp.Revocation
+	if err := json.Unmarshal(msg.Data, &r); err != nil {
+		return pubsub.ValidationReject
+	}
+	if err := r.Verify(); err != nil {
+		return pubsub.ValidationReject
+	}
+	if from == n.host.ID() {
+		// Published here; the user chose to.
+		return pubsub.ValidationAccept
+	}
+	registered, err := n.config.DB.IsIssuer(r.IssuerPublicKey)
+	if err != nil || !registered {
+		return pubsub.ValidationIgnore
+	}
+	return pubsub.ValidationAccept
+}
+
+// PublishRevocation verifies r, stores it and gossips it to the mesh. It
+// reports false if the revocation was already known.
+func (n *Node) PublishRevocation(r *zkp.Revocation) (bool, error) {
+	added, err := n.storeRevocation(r, false)
+	if err != nil || !added {
+		return added, err
+	}
+	if n.revocationTopic == nil {
+		return true, fmt.Errorf("not joined revocation topic yet")
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return true, err
+	}
+	return true, n.revocationTopic.Publish(n.ctx, data)
+}
+
+// storeRevocation verifies r and adds it to the local registry, announcing
+// it on Revocations if it is new. A revocation from the mesh by an issuer
+// this node has not registered is kept only while fewer than
+// maxUnregisteredRevocations such are.
+func (n *Node) storeRevocation(r *zkp.Revocation, fromMesh bool) (bool, error) {
+	if err := r.Verify(); err != nil {
+		return false, err
+	}
+	if fromMesh {
+		registered, err := n.config.DB.IsIssuer(r.IssuerPublicKey)
+		if err != nil {
+			return false, err
+		}
+		if !registered {
+			count, err := n.config.DB.CountUnregisteredRevocations()
+			if err != nil {
+				return false, err
+			}
+			if count >= maxUnregisteredRevocations {
+				return false, errUnregisteredFull
+			}
+		}
+	}
+	added, err := n.config.DB.SaveRevocation(database.RevocationRecord{
+		ID:                 r.ID(),
+		IssuerPublicKey:    r.IssuerPublicKey,
+		ProofHash:          r.ProofHash,
+		DocumentCommitment: r.DocumentCommitment,
+		Reason:             r.Reason,
+		RevokedAt:          r.RevokedAt,
+		Signature:          r.Signature,
+	})
+	if err != nil || !added {
+		return false, err
+	}
+	select {
+	case n.Revocations <- r:
+	default:
+	}
+	return true, nil
+}
+
+func (n *Node) serveRevocationSync(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(revocationSyncTimeout))
+
+	var req revocationSyncRequest
+	line, err := bufio.NewReader(io.LimitReader(s, maxRevocationPage)).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		json.NewEncoder(s).Encode(revocationSyncResponse{Error: "invalid sync request"})
+		return
+	}
+
+	records, err := n.config.DB.ListRevocationsAfter(req.After, revocationPageSize+1)
+	if err != nil {
+		json.NewEncoder(s).Encode(revocationSyncResponse{Error: err.Error()})
+		return
+	}
+	resp := revocationSyncResponse{Next: req.After, More: len(records) > revocationPageSize}
+	if resp.More {
+		records = records[:revocationPageSize]
+	}
+	for _, rec := range records {
+		resp.Revocations = append(resp.Revocations, zkp.Revocation{
+			IssuerPublicKey:    rec.IssuerPublicKey,
+			ProofHash:          rec.ProofHash,
+			DocumentCommitment: rec.DocumentCommitment,
+			Reason:             rec.Reason,
+			RevokedAt:          rec.RevokedAt,
+			Signature:          rec.Signature,
+		})
+		resp.Next = rec.Seq
+	}
+	json.NewEncoder(s).Encode(resp)
+}
+
+// syncRevocations pulls the revocations p stored since the last sync.
+func (n *Node) syncRevocations(p peer.ID) {
+	cursor, err := n.config.DB.RevocationSyncCursor(p.String())
+	if err != nil {
+		n.config.Logger.Warnf("Revocation sync with %s: %v", p, err)
+		return
+	}
+
+	added, dropped := 0, 0
+	for {
+		resp, err := n.fetchRevocations(p, cursor)
+		if err != nil {
+			n.config.Logger.Debugf("Revocation sync with %s: %v", p, err)
+			return
+		}
+		for i := range resp.Revocations {
+			ok, err := n.storeRevocation(&resp.Revocations[i], true)
+			if errors.Is(err, errUnregisteredFull) {
+				dropped++
+			} else if err != nil {
+				n.config.Logger.Warnf("Revocation sync with %s: dropped entry: %v", p, err)
+			}
+			if ok {
+				added++
+			}
+		}
+		if resp.Next > cursor {
+			cursor = resp.Next
+			if err := n.config.DB.SetRevocationSyncCursor(p.String(), cursor); err != nil {
+				n.config.Logger.Warnf("Revocation sync with %s: %v", p, err)
+				return
+			}
+		}
+		if !resp.More {
+			break
+		}
+	}
+	if added > 0 {
+		n.config.Logger.Infof("Synced %d revocation(s) from %s", added, p)
+	}
+	if dropped > 0 {
+		n.config.Logger.Infof("Revocation sync with %s: dropped %d revocation(s) of unregistered issuers: %v", p, dropped, errUnregisteredFull)
+	}
+}
+
+func (n *Node) fetchRevocations(p peer.ID, after int64) (*revocationSyncResponse, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, revocationSyncTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, p, RevocationSyncProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync stream: %w", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(revocationSyncTimeout))
+
+	if err := json.NewEncoder(s).Encode(revocationSyncRequest{After: after}); err != nil {
+		return nil, fmt.Errorf("failed to send sync request: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		return nil, fmt.Errorf("failed to send sync request: %w", err)
+	}
+
+	var resp revocationSyncResponse
+	if err := json.NewDecoder(io.LimitReader(s, maxRevocationPage)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid sync response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("peer: %s", resp.Error)
+	}
+	return &resp, nil
+}
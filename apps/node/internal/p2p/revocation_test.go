@@ -0,0 +1,201 @@
+package p2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lairik-pulse/node/internal/database"
+	"github.com/lairik-pulse/node/internal/zkp"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/sirupsen/logrus"
+)
+
+// testRevocationNode returns a node with its own registry, serving the
+// revocation topic and sync protocol.
+func testRevocationNode(t *testing.T) *Node {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	db, err := database.Open(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	n, err := NewNode(context.Background(), Config{Logger: log, DB: db, DisableMDNS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Stop() })
+	if err := n.startRevocations(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// signedRevocation revokes a proof named by seed under a new issuer key.
+func signedRevocation(t *testing.T, seed string) *zkp.Revocation {
+	t.Helper()
+	key, err := zkp.GenerateIssuerKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(seed))
+	r := &zkp.Revocation{ProofHash: hex.EncodeToString(hash[:]), Reason: "withdrawn"}
+	if err := zkp.SignRevocation(key, r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func registerIssuer(t *testing.T, n *Node, publicKey string) {
+	t.Helper()
+	if err := n.config.DB.SaveIssuer(database.IssuerRecord{PublicKey: publicKey, Name: "registered", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func storedRevocations(t *testing.T, n *Node) map[string]bool {
+	t.Helper()
+	records, err := n.config.DB.ListRevocationsAfter(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, r := range records {
+		ids[r.ID] = true
+	}
+	return ids
+}
+
+func TestStoreRevocation(t *testing.T) {
+	n := testRevocationNode(t)
+	r := signedRevocation(t, "proof")
+
+	forged := *r
+	forged.Reason = "forged"
+	if _, err := n.storeRevocation(&forged, false); err == nil {
+		t.Fatal("stored a revocation with a bad signature")
+	}
+
+	if added, err := n.storeRevocation(r, false); err != nil || !added {
+		t.Fatalf("store: added %v, %v", added, err)
+	}
+	if added, err := n.storeRevocation(r, false); err != nil || added {
+		t.Fatalf("store again: added %v, %v", added, err)
+	}
+	select {
+	case got := <-n.Revocations:
+		if got.ID() != r.ID() {
+			t.Fatalf("announced %s, want %s", got.ID(), r.ID())
+		}
+	default:
+		t.Fatal("new revocation not announced")
+	}
+}
+
+func TestUnregisteredRevocationsCapped(t *testing.T) {
+	defer func(max int) { maxUnregisteredRevocations = max }(maxUnregisteredRevocations)
+	maxUnregisteredRevocations = 2
+
+	n := testRevocationNode(t)
+	for i := 0; i < 2; i++ {
+		if _, err := n.storeRevocation(signedRevocation(t, fmt.Sprint(i)), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := n.storeRevocation(signedRevocation(t, "over"), true); !errors.Is(err, errUnregisteredFull) {
+		t.Fatalf("over the cap: got %v, want errUnregisteredFull", err)
+	}
+
+	// Registered issuers and the user's own revocations are always kept.
+	registered := signedRevocation(t, "registered")
+	registerIssuer(t, n, registered.IssuerPublicKey)
+	if added, err := n.storeRevocation(registered, true); err != nil || !added {
+		t.Fatalf("registered issuer: added %v, %v", added, err)
+	}
+	if added, err := n.storeRevocation(signedRevocation(t, "local"), false); err != nil || !added {
+		t.Fatalf("published locally: added %v, %v", added, err)
+	}
+}
+
+func TestValidateRevocationMsg(t *testing.T) {
+	n := testRevocationNode(t)
+	other := testRevocationNode(t)
+	message := func(v interface{}) *pubsub.Message {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &pubsub.Message{Message: &pb.Message{Data: data}}
+	}
+
+	unregistered := signedRevocation(t, "unregistered")
+	registered := signedRevocation(t, "registered")
+	registerIssuer(t, n, registered.IssuerPublicKey)
+	forged := *registered
+	forged.Reason = "forged"
+
+	for name, tc := range map[string]struct {
+		from peer.ID
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		"registered":       {other.host.ID(), message(registered), pubsub.ValidationAccept},
+		"unregistered":     {other.host.ID(), message(unregistered), pubsub.ValidationIgnore},
+		"published here":   {n.host.ID(), message(unregistered), pubsub.ValidationAccept},
+		"forged":           {other.host.ID(), message(&forged), pubsub.ValidationReject},
+		"not a revocation": {other.host.ID(), &pubsub.Message{Message: &pb.Message{Data: []byte("{")}}, pubsub.ValidationReject},
+	} {
+		if got := n.validateRevocationMsg(context.Background(), tc.from, tc.msg); got != tc.want {
+			t.Fatalf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestSyncRevocations(t *testing.T) {
+	defer func(max int) { maxUnregisteredRevocations = max }(maxUnregisteredRevocations)
+	maxUnregisteredRevocations = 1
+
+	a, b := testRevocationNode(t), testRevocationNode(t)
+	registered := signedRevocation(t, "registered")
+	registerIssuer(t, b, registered.IssuerPublicKey)
+	first, second := signedRevocation(t, "first"), signedRevocation(t, "second")
+	for _, r := range []*zkp.Revocation{first, registered, second} {
+		if _, err := a.storeRevocation(r, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.host.Connect(context.Background(), peer.AddrInfo{ID: a.host.ID(), Addrs: a.host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	b.syncRevocations(a.host.ID())
+	got := storedRevocations(t, b)
+	if len(got) != 2 || !got[first.ID()] || !got[registered.ID()] {
+		t.Fatalf("synced %v, want the first unregistered and the registered revocation", got)
+	}
+
+	// The cursor moved past every entry, so a later sync only pulls new ones.
+	cursor, err := b.config.DB.RevocationSyncCursor(a.host.ID().String())
+	if err != nil || cursor != 3 {
+		t.Fatalf("cursor %d, %v, want 3", cursor, err)
+	}
+	later := signedRevocation(t, "later")
+	registerIssuer(t, b, later.IssuerPublicKey)
+	if _, err := a.storeRevocation(later, false); err != nil {
+		t.Fatal(err)
+	}
+	b.syncRevocations(a.host.ID())
+	if got := storedRevocations(t, b); len(got) != 3 || !got[later.ID()] {
+		t.Fatalf("after the second sync: %v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package zkp
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/signature/eddsa"
+)
+
+// AgeOverCircuit proves the holder of an issuer-signed identity document was
+// at least ThresholdYears old on ReferenceDate, without revealing the date of
+// birth. It reuses the identity credential, so issuers sign it as "identity".
+//
+// Calendar years cannot be counted inside the circuit, so the latest birth
+// date meeting the threshold is a public input (AgeCutoff) and verifiers
+// recompute it from ThresholdYears and ReferenceDate.
+type AgeOverCircuit struct {
+	// Private inputs (witness)
+	DocumentHash frontend.Variable `gnark:",private"`
+	HolderID     frontend.Variable `gnark:",private"`
+	BirthDate    frontend.Variable `gnark:",private"`
+
+	// Salt blinds DocumentCommitment.
+	Salt frontend.Variable `gnark:",private"`
+
+	// IssuerSignature is the issuing office's EdDSA signature over the
+	// identity commitment (see IdentityCommitment).
+	IssuerSignature eddsa.Signature `gnark:",private"`
+
+	// Public inputs
+	DocumentCommitment frontend.Variable `gnark:",public"`
+	ThresholdYears     frontend.Variable `gnark:",public"`
+	ReferenceDate      frontend.Variable `gnark:",public"`
+	AgeCutoff          frontend.Variable `gnark:",public"`
+	ValidUntil         frontend.Variable `gnark:",public"`
+	IssuerPublicKey    eddsa.PublicKey   `gnark:",public"`
+}
+
+// Define defines the circuit constraints
+func (c *AgeOverCircuit) Define(api frontend.API) error {
+	// Verify the document and its holder exist
+	api.AssertIsDifferent(c.DocumentHash, 0)
+	api.AssertIsDifferent(c.HolderID, 0)
+
+	// Verify the proof is bound to the committed vault document
+	if err := assertCommitment(api, c.DocumentHash, c.Salt, c.DocumentCommitment); err != nil {
+		return err
+	}
+
+	// Verify the holder was born on or before the cutoff, which lies before
+	// the reference date, while the document was still valid
+	api.AssertIsLessOrEqual(c.BirthDate, c.AgeCutoff)
+	api.AssertIsLessOrEqual(c.AgeCutoff, c.ReferenceDate)
+	api.AssertIsLessOrEqual(c.ReferenceDate, c.ValidUntil)
+
+	// Verify the issuer signed exactly this identity
+	return assertIssuerSigned(api, c.IssuerSignature, c.IssuerPublicKey,
+		c.DocumentHash, c.HolderID, c.BirthDate, c.ValidUntil)
+}
+
+// ageOverProof proves the holder is at least a given age.
+var ageOverProof = registerProofType(&ProofType{
+	Name:        "age_over",
+	Description: "was at least threshold_years old on a public reference date, per a signed identity document",
+	Circuit:     func() frontend.Circuit { return &AgeOverCircuit{} },
+	Message:     identityProof.Message,
+	Assign:      assignAgeOver,
+	Public: []PublicInput{
+		{Name: PublicDocumentCommitment, Kind: PublicField},
+		{Name: ParamThresholdYears, Kind: PublicField},
+		{Name: ParamReferenceDate, Kind: PublicDate},
+		{Name: "age_cutoff", Kind: PublicDate},
+		{Name: ClaimValidUntil, Kind: PublicDate},
+		{Name: ClaimIssuerPublicKey, Kind: PublicKey},
+	},
+	CheckPublic: checkAgeCutoff,
+})
+
+func assignAgeOver(doc Document, c Claims) (frontend.Circuit, error) {
+	age, err := ParseAgeOverClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIssuerSignature(age.IssuerPublicKey, age.IssuerSignature, IdentityCommitment(doc.Digest, age.IdentityClaims)); err != nil {
+		return nil, err
+	}
+
+	commitment, err := doc.commitment()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &AgeOverCircuit{
+		DocumentHash:       digestToField(doc.Digest),
+		Salt:               doc.Salt,
+		DocumentCommitment: commitment,
+		HolderID:           age.HolderID,
+		BirthDate:          age.BirthDate,
+		ThresholdYears:     age.ThresholdYears,
+		ReferenceDate:      FieldDate(age.ReferenceDate),
+		AgeCutoff:          FieldDate(age.Cutoff),
+		ValidUntil:         age.ValidUntil,
+	}
+	assignment.IssuerPublicKey.Assign(tedwards.BN254, age.IssuerPublicKey)
+	assignment.IssuerSignature.Assign(tedwards.BN254, age.IssuerSignature)
+	return assignment, nil
+}
+
+// checkAgeCutoff checks that the public cutoff really is ThresholdYears
+// before ReferenceDate.
+func checkAgeCutoff(public fr.Vector) error {
+	// public[0] is the document commitment
+	years, reference, cutoff := public[1].BigInt(new(big.Int)), public[2].BigInt(new(big.Int)), public[3].BigInt(new(big.Int))
+	if !years.IsInt64() || years.Int64() < 1 || years.Int64() > maxThresholdYears {
+		return fmt.Errorf("zkp: age proof parameters out of range")
+	}
+	referenceDate, err := DateFromField(reference)
+	if err != nil {
+		return err
+	}
+	want := AgeCutoff(referenceDate, int(years.Int64()))
+	if want.Before(DateEpoch) || cutoff.Cmp(FieldDate(want)) != 0 {
+		return fmt.Errorf("zkp: age cutoff does not match %d years before the reference date", years.Int64())
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package zkp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+func ageClaims(birthDate, threshold, reference string) Claims {
+	return Claims{
+		ClaimHolderID:       "1234567",
+		ClaimBirthDate:      birthDate,
+		ClaimValidUntil:     "2035-01-01",
+		ParamThresholdYears: threshold,
+		ParamReferenceDate:  reference,
+	}
+}
+
+func TestAgeOverBornBefore1970(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "age_over", doc, ageClaims("1948-08-15", "18", "2026-01-01"))
+	circuit, assignment := assign(t, "age_over", doc, c)
+	assertSolved(t, circuit, assignment)
+}
+
+func TestAgeOver65(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "age_over", doc, ageClaims("1960-12-31", "65", "2026-01-01"))
+	circuit, assignment := assign(t, "age_over", doc, c)
+	assertSolved(t, circuit, assignment)
+
+	age, err := ParseAgeOverClaims(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(1961, time.January, 1, 0, 0, 0, 0, time.UTC); !age.Cutoff.Equal(want) {
+		t.Fatalf("cutoff %s, want %s", age.Cutoff, want)
+	}
+}
+
+func TestAgeOverUnderThreshold(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "age_over", doc, ageClaims("1961-01-02", "65", "2026-01-01"))
+	if _, err := ParseAgeOverClaims(c); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("got %v, want ErrInvalidClaims", err)
+	}
+
+	// A prover that skips the claim check still cannot satisfy the circuit.
+	circuit, assignment := assign(t, "age_over", doc, issue(t, "age_over", doc, ageClaims("1960-12-31", "65", "2026-01-01")))
+	assignment.(*AgeOverCircuit).BirthDate = FieldDate(time.Date(1961, time.January, 2, 0, 0, 0, 0, time.UTC))
+	assertNotSolved(t, circuit, assignment)
+}
+
+func TestAgeOverExpiredCredential(t *testing.T) {
+	doc := testDocument()
+	c := ageClaims("1948-08-15", "18", "2036-01-01")
+	if _, err := ParseAgeOverClaims(issue(t, "age_over", doc, c)); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("got %v, want ErrInvalidClaims", err)
+	}
+
+	// Moving the reference date past expiry in the witness does not solve.
+	circuit, assignment := assign(t, "age_over", doc, issue(t, "age_over", doc, ageClaims("1948-08-15", "18", "2026-01-01")))
+	assignment.(*AgeOverCircuit).ReferenceDate = FieldDate(time.Date(2036, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assertNotSolved(t, circuit, assignment)
+}
+
+func TestAgeOverBeforeEpoch(t *testing.T) {
+	if _, err := parseIdentityFields(ageClaims("1899-12-31", "18", "2026-01-01")); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("got %v, want ErrInvalidClaims", err)
+	}
+}
+
+func TestCheckAgeCutoff(t *testing.T) {
+	reference := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	public := func(years int64, cutoff time.Time) fr.Vector {
+		v := make(fr.Vector, 4)
+		v[1].SetInt64(years)
+		v[2].SetBigInt(FieldDate(reference))
+		v[3].SetBigInt(FieldDate(cutoff))
+		return v
+	}
+
+	if err := checkAgeCutoff(public(65, AgeCutoff(reference, 65))); err != nil {
+		t.Fatalf("65-year cutoff rejected: %v", err)
+	}
+	if err := checkAgeCutoff(public(65, AgeCutoff(reference, 64))); err == nil {
+		t.Fatal("cutoff for a lower threshold accepted")
+	}
+}
+
+func TestFieldDate(t *testing.T) {
+	for _, s := range []string{"1900-01-01", "1948-08-15", "1970-01-01", "2026-10-17"} {
+		want, _ := time.Parse("2006-01-02", s)
+		got, err := DateFromField(FieldDate(want))
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("%s round-tripped to %s (%v)", s, got, err)
+		}
+	}
+
+	c := Claims{"d": "-662688000"} // 1949-01-01 as unix seconds
+	v, err := c.date("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := DateFromField(v); got.Format("2006-01-02") != "1949-01-01" {
+		t.Fatalf("negative unix time parsed as %s", got)
+	}
+}
@@ -0,0 +1,160 @@
+package zkp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// Backend is the proving system a proof type is proven with.
+type Backend string
+
+const (
+	// BackendGroth16 makes the smallest proofs but needs a trusted setup for
+	// every circuit (see Ceremony).
+	BackendGroth16 Backend = "groth16"
+	// BackendPlonk derives every circuit's keys from one universal SRS, so a
+	// new proof type needs no ceremony of its own.
+	BackendPlonk Backend = "plonk"
+)
+
+// ParseBackend parses a backend name. The empty name is Groth16, which is what
+// every proof stored before backends were recorded was made with.
+func ParseBackend(name string) (Backend, error) {
+	switch Backend(name) {
+	case "", BackendGroth16:
+		return BackendGroth16, nil
+	case BackendPlonk:
+		return BackendPlonk, nil
+	}
+	return "", fmt.Errorf("zkp: unknown backend %q (want %s or %s)", name, BackendGroth16, BackendPlonk)
+}
+
+// provingKey and verifyingKey are the keys of either backend; each backend
+// asserts them back to its own types.
+type (
+	provingKey interface {
+		io.WriterTo
+		io.ReaderFrom
+	}
+	verifyingKey interface {
+		io.WriterTo
+		io.ReaderFrom
+	}
+)
+
+// provingSystems holds the implementation of every backend.
+var provingSystems = map[Backend]provingSystem{
+	BackendGroth16: groth16System{},
+	BackendPlonk:   plonkSystem{},
+}
+
+// provingSystem is what Service needs of a backend.
+type provingSystem interface {
+	compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error)
+	// setup makes the keys for ccs; only PLONK uses the SRS.
+	setup(ccs constraint.ConstraintSystem, srs *srsStore) (provingKey, verifyingKey, error)
+	prove(ccs constraint.ConstraintSystem, pk provingKey, w witness.Witness) ([]byte, error)
+	// verify reports whether proof is valid; it only fails if proof cannot
+	// be decoded.
+	verify(proof []byte, vk verifyingKey, public witness.Witness) (bool, error)
+
+	// exportSolidity writes a verifier contract for vk, and
+	// solidityCalldata encodes a proof as a call to it (see solidity.go).
+	exportSolidity(vk verifyingKey, w io.Writer) error
+	solidityCalldata(proof []byte, public fr.Vector) (*SolidityCalldata, error)
+
+	// Empty objects to decode stored key sets into.
+	newCS() constraint.ConstraintSystem
+	newProvingKey() provingKey
+	newVerifyingKey() verifyingKey
+}
+
+// ── Groth16 ─────────────────────────────────────────────────────────────────
+
+type groth16System struct{}
+
+func (groth16System) compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+}
+
+// setup runs a local, single-party trusted setup.
+func (groth16System) setup(ccs constraint.ConstraintSystem, _ *srsStore) (provingKey, verifyingKey, error) {
+	return groth16.Setup(ccs)
+}
+
+func (groth16System) prove(ccs constraint.ConstraintSystem, pk provingKey, w witness.Witness) ([]byte, error) {
+	proof, err := groth16.Prove(ccs, pk.(groth16.ProvingKey), w)
+	if err != nil {
+		return nil, err
+	}
+	return serialize(proof)
+}
+
+func (groth16System) verify(proofData []byte, vk verifyingKey, public witness.Witness) (bool, error) {
+	proof := groth16.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofData)); err != nil {
+		return false, fmt.Errorf("zkp: failed to deserialize proof: %w", err)
+	}
+	return groth16.Verify(proof, vk.(groth16.VerifyingKey), public) == nil, nil
+}
+
+func (groth16System) newCS() constraint.ConstraintSystem { return groth16.NewCS(ecc.BN254) }
+func (groth16System) newProvingKey() provingKey          { return groth16.NewProvingKey(ecc.BN254) }
+func (groth16System) newVerifyingKey() verifyingKey      { return groth16.NewVerifyingKey(ecc.BN254) }
+
+// ── PLONK ───────────────────────────────────────────────────────────────────
+
+type plonkSystem struct{}
+
+func (plonkSystem) compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+}
+
+// setup derives the keys from the SRS. It is deterministic: nodes sharing an
+// SRS file derive identical keys for the same circuit.
+func (plonkSystem) setup(ccs constraint.ConstraintSystem, store *srsStore) (provingKey, verifyingKey, error) {
+	srs, err := store.forCircuit(ccs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plonk.Setup(ccs, srs)
+}
+
+func (plonkSystem) prove(ccs constraint.ConstraintSystem, pk provingKey, w witness.Witness) ([]byte, error) {
+	proof, err := plonk.Prove(ccs, pk.(plonk.ProvingKey), w)
+	if err != nil {
+		return nil, err
+	}
+	return serialize(proof)
+}
+
+func (plonkSystem) verify(proofData []byte, vk verifyingKey, public witness.Witness) (bool, error) {
+	proof := plonk.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofData)); err != nil {
+		return false, fmt.Errorf("zkp: failed to deserialize proof: %w", err)
+	}
+	return plonk.Verify(proof, vk.(plonk.VerifyingKey), public) == nil, nil
+}
+
+func (plonkSystem) newCS() constraint.ConstraintSystem { return plonk.NewCS(ecc.BN254) }
+func (plonkSystem) newProvingKey() provingKey          { return plonk.NewProvingKey(ecc.BN254) }
+func (plonkSystem) newVerifyingKey() verifyingKey      { return plonk.NewVerifyingKey(ecc.BN254) }
+
+func serialize(obj io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := obj.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("zkp: failed to serialize proof: %w", err)
+	}
+	return buf.Bytes(), nil
+}
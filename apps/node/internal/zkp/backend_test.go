@@ -0,0 +1,136 @@
+package zkp
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/sirupsen/logrus"
+)
+
+// testSRSStore returns an SRS store over a new SRS of size points.
+func testSRSStore(t *testing.T, size uint64) *srsStore {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bn254.srs")
+	if _, err := generateSRS(path, size); err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return &srsStore{path: path, logger: log}
+}
+
+func TestPlonkProveVerify(t *testing.T) {
+	system := provingSystems[BackendPlonk]
+	ccs, err := system.compile(&powerCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := testSRSStore(t, srsSize(ccs))
+	pk, vk, err := system.setup(ccs, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := frontend.NewWitness(&powerCircuit{X: 2, Y: uint64(1) << 32}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := system.prove(ccs, pk, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := system.verify(proof, vk, public); err != nil || !ok {
+		t.Fatalf("proof does not verify: %v", err)
+	}
+
+	other, err := frontend.NewWitness(&powerCircuit{Y: uint64(1) << 31}, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := system.verify(proof, vk, other); ok {
+		t.Fatal("proof verifies for another public input")
+	}
+
+	// Keys derive from the SRS alone, so every node sharing it agrees.
+	_, again, err := system.setup(ccs, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := serialize(vk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := serialize(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatal("setup from the same SRS derived different verifying keys")
+	}
+}
+
+func TestPlonkRejectsGroth16Proof(t *testing.T) {
+	groth, plonk := provingSystems[BackendGroth16], provingSystems[BackendPlonk]
+	r1cs, err := groth.compile(&powerCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, _, err := groth.setup(r1cs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := frontend.NewWitness(&powerCircuit{X: 2, Y: uint64(1) << 32}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := groth.prove(r1cs, pk, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scs, err := plonk.compile(&powerCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, vk, err := plonk.setup(scs, testSRSStore(t, srsSize(scs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := plonk.verify(proof, vk, public); ok {
+		t.Fatal("PLONK verifying key accepted a Groth16 proof")
+	}
+}
+
+func TestPlonkSRSTooSmall(t *testing.T) {
+	ccs, err := provingSystems[BackendPlonk].compile(&powerCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := testSRSStore(t, srsSize(ccs)-1)
+	if _, err := store.forCircuit(ccs); err == nil {
+		t.Fatal("accepted an SRS too small for the circuit")
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	for name, want := range map[string]Backend{"": BackendGroth16, "groth16": BackendGroth16, "plonk": BackendPlonk} {
+		if got, err := ParseBackend(name); err != nil || got != want {
+			t.Fatalf("ParseBackend(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseBackend("stark"); err == nil {
+		t.Fatal("parsed an unknown backend")
+	}
+}
@@ -0,0 +1,59 @@
+package zkp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// base45 (RFC 9285) packs binary into the QR alphanumeric character set, so
+// bundles fit in QR codes and survive being typed or copied as text.
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+func base45Encode(src []byte) string {
+	var b strings.Builder
+	b.Grow((len(src) + 1) / 2 * 3)
+	for i := 0; i+1 < len(src); i += 2 {
+		n := int(src[i])<<8 | int(src[i+1])
+		b.WriteByte(base45Alphabet[n%45])
+		b.WriteByte(base45Alphabet[n/45%45])
+		b.WriteByte(base45Alphabet[n/(45*45)])
+	}
+	if len(src)%2 == 1 {
+		n := int(src[len(src)-1])
+		b.WriteByte(base45Alphabet[n%45])
+		b.WriteByte(base45Alphabet[n/45])
+	}
+	return b.String()
+}
+
+func base45Decode(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, fmt.Errorf("zkp: invalid base45 length %d", len(s))
+	}
+	digits := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(base45Alphabet, s[i])
+		if d < 0 {
+			return nil, fmt.Errorf("zkp: invalid base45 character %q", s[i])
+		}
+		digits[i] = d
+	}
+
+	out := make([]byte, 0, len(s)/3*2+1)
+	for i := 0; i < len(digits); i += 3 {
+		if i+2 < len(digits) {
+			n := digits[i] + digits[i+1]*45 + digits[i+2]*45*45
+			if n > 0xffff {
+				return nil, fmt.Errorf("zkp: invalid base45 triplet at %d", i)
+			}
+			out = append(out, byte(n>>8), byte(n))
+			continue
+		}
+		n := digits[i] + digits[i+1]*45
+		if n > 0xff {
+			return nil, fmt.Errorf("zkp: invalid base45 pair at %d", i)
+		}
+		out = append(out, byte(n))
+	}
+	return out, nil
+}
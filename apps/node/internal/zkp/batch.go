@@ -0,0 +1,266 @@
+package zkp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// BatchItem is one proof in a batch verification.
+type BatchItem struct {
+	ProofType     string
+	Backend       string
+	Fingerprint   string
+	Proof         []byte
+	PublicWitness []byte
+	// VerifyingKey, if set, must match the local verifying key (see
+	// VerifyBundle).
+	VerifyingKey string
+}
+
+// BatchResult is the outcome for the BatchItem at the same index. Err is set
+// when the item could not be checked at all, as VerifyProofFromBytes would
+// return it.
+type BatchResult struct {
+	Valid bool
+	Err   error
+}
+
+// batchChallengeBits is the size of the random coefficients that fold a batch
+// into one pairing check; a batch with an invalid proof passes with
+// probability 2^-128.
+const batchChallengeBits = 128
+
+// batchProof is a decoded BatchItem ready for the pairing check.
+type batchProof struct {
+	index  int
+	proof  *groth16bn254.Proof
+	vk     *groth16bn254.VerifyingKey
+	public fr.Vector
+	// kSum is Σ public[i]·K[i+1] + K[0], the public input term of the
+	// Groth16 equation.
+	kSum curve.G1Affine
+}
+
+// VerifyBatch verifies many proofs at once on a bounded pool of workers.
+// Groth16 proofs made with the same verifying key are folded with random
+// coefficients into a single multi-pairing check; only if that fails are
+// they checked one by one to find the invalid ones. PLONK proofs are always
+// checked one by one.
+func (s *Service) VerifyBatch(items []BatchItem) []BatchResult {
+	results := make([]BatchResult, len(items))
+	workers := min(runtime.NumCPU(), len(items))
+
+	// Decode every Groth16 item and compute its public input term.
+	decoded := make([]*batchProof, len(items))
+	folded := make([]bool, len(items))
+	parallel(len(items), workers, func(i int) {
+		item := items[i]
+		vk, err := s.verifierKey(item.ProofType, item.Backend, item.Fingerprint)
+		if err == nil {
+			err = checkVerifyingKey(vk, item.VerifyingKey)
+		}
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		if vk.backend != BackendGroth16 {
+			results[i].Valid, results[i].Err = s.VerifyProofFromBytes(item.ProofType, item.Backend, item.Proof, item.PublicWitness, item.Fingerprint)
+			return
+		}
+
+		bp, err := decodeBatchItem(item, vk)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		bp.index = i
+		decoded[i] = bp
+		folded[i] = true
+	})
+
+	// Group by verifying key; keys with Pedersen commitments need the full
+	// verifier.
+	groups := make(map[*groth16bn254.VerifyingKey][]*batchProof)
+	var single []*batchProof
+	for _, bp := range decoded {
+		switch {
+		case bp == nil:
+		case len(bp.vk.PublicAndCommitmentCommitted) > 0 || len(bp.proof.Commitments) > 0:
+			single = append(single, bp)
+		default:
+			groups[bp.vk] = append(groups[bp.vk], bp)
+		}
+	}
+	for _, group := range groups {
+		if len(group) > 1 && batchPairingCheck(group) {
+			for _, bp := range group {
+				results[bp.index].Valid = true
+			}
+			continue
+		}
+		single = append(single, group...)
+	}
+
+	parallel(len(single), workers, func(i int) {
+		bp := single[i]
+		results[bp.index].Valid = groth16bn254.Verify(bp.proof, bp.vk, bp.public) == nil
+	})
+
+	// Relations the circuits cannot express are checked per proof;
+	// VerifyProofFromBytes already did so for the others.
+	for i, item := range items {
+		if !results[i].Valid || !folded[i] {
+			continue
+		}
+		if pt := proofTypes[item.ProofType]; pt.CheckPublic != nil && pt.CheckPublic(decoded[i].public) != nil {
+			results[i].Valid = false
+		}
+	}
+	return results
+}
+
+// checkVerifyingKey checks that vk has the fingerprint an item expects, if
+// any.
+func checkVerifyingKey(vk verifierKey, want string) error {
+	if want == "" {
+		return nil
+	}
+	vkFingerprint, err := verifyingKeyFingerprint(vk.vk)
+	if err != nil {
+		return err
+	}
+	if vkFingerprint != want {
+		return fmt.Errorf("zkp: proof was made with verifying key %s, this node has %s (different trusted setup)",
+			shortFingerprint(want), shortFingerprint(vkFingerprint))
+	}
+	return nil
+}
+
+// decodeBatchItem deserializes a Groth16 item made for vk.
+func decodeBatchItem(item BatchItem, vk verifierKey) (*batchProof, error) {
+	bnVK, ok := vk.vk.(*groth16bn254.VerifyingKey)
+	if !ok {
+		return nil, fmt.Errorf("zkp: verifying key is not over BN254")
+	}
+
+	proof := groth16.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(item.Proof)); err != nil {
+		return nil, fmt.Errorf("zkp: failed to deserialize proof: %w", err)
+	}
+	bnProof := proof.(*groth16bn254.Proof)
+
+	w, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to create public witness target: %w", err)
+	}
+	if err := w.UnmarshalBinary(item.PublicWitness); err != nil {
+		return nil, fmt.Errorf("zkp: failed to unmarshal public witness: %w", err)
+	}
+	public, ok := w.Vector().(fr.Vector)
+	if !ok {
+		return nil, fmt.Errorf("zkp: public witness is not over BN254")
+	}
+
+	bp := &batchProof{proof: bnProof, vk: bnVK, public: public}
+	if len(public) == len(bnVK.G1.K)-1 {
+		var kSum curve.G1Jac
+		if _, err := kSum.MultiExp(bnVK.G1.K[1:], public, ecc.MultiExpConfig{}); err != nil {
+			return nil, fmt.Errorf("zkp: failed to compute public input term: %w", err)
+		}
+		kSum.AddMixed(&bnVK.G1.K[0])
+		bp.kSum.FromJacobian(&kSum)
+	}
+	return bp, nil
+}
+
+// batchPairingCheck checks the Groth16 equation e(A,B) = e(α,β)·e(K,γ)·e(C,δ)
+// for every proof in group at once, as
+//
+//	Π e(rᵢAᵢ, Bᵢ) · e(Σ rᵢCᵢ, -δ) · e(Σ rᵢKᵢ, -γ) · e((Σ rᵢ)α, -β) = 1
+//
+// with random rᵢ. All proofs must share one verifying key without
+// commitments.
+func batchPairingCheck(group []*batchProof) bool {
+	vk := group[0].vk
+	bound := new(big.Int).Lsh(big.NewInt(1), batchChallengeBits)
+
+	P := make([]curve.G1Affine, 0, len(group)+3)
+	Q := make([]curve.G2Affine, 0, len(group)+3)
+	var (
+		sumC, sumK curve.G1Jac
+		sumR       big.Int
+	)
+	for _, bp := range group {
+		if len(bp.public) != len(vk.G1.K)-1 ||
+			!bp.proof.Ar.IsInSubGroup() || !bp.proof.Krs.IsInSubGroup() || !bp.proof.Bs.IsInSubGroup() {
+			return false
+		}
+
+		r, err := rand.Int(rand.Reader, bound)
+		if err != nil {
+			return false
+		}
+		sumR.Add(&sumR, r)
+
+		var a curve.G1Affine
+		a.ScalarMultiplication(&bp.proof.Ar, r)
+		P = append(P, a)
+		Q = append(Q, bp.proof.Bs)
+
+		var t curve.G1Jac
+		t.ScalarMultiplicationAffine(&bp.proof.Krs, r)
+		sumC.AddAssign(&t)
+		t.ScalarMultiplicationAffine(&bp.kSum, r)
+		sumK.AddAssign(&t)
+	}
+
+	var c, k, alpha curve.G1Affine
+	c.FromJacobian(&sumC)
+	k.FromJacobian(&sumK)
+	alpha.ScalarMultiplication(&vk.G1.Alpha, &sumR)
+
+	var negDelta, negGamma, negBeta curve.G2Affine
+	negDelta.Neg(&vk.G2.Delta)
+	negGamma.Neg(&vk.G2.Gamma)
+	negBeta.Neg(&vk.G2.Beta)
+
+	P = append(P, c, k, alpha)
+	Q = append(Q, negDelta, negGamma, negBeta)
+
+	ok, err := curve.PairingCheck(P, Q)
+	return err == nil && ok
+}
+
+// parallel calls fn for 0..n-1 on at most workers goroutines.
+func parallel(n, workers int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
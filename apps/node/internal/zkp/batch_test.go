@@ -0,0 +1,183 @@
+package zkp
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// testBatchService returns a service knowing only the ceremony test proof
+// type, with a Groth16 and a PLONK key set for it, and those key sets to
+// prove with.
+func testBatchService(t *testing.T) (*Service, map[Backend]*keySet) {
+	t.Helper()
+	sets := make(map[Backend]*keySet)
+	vks := make(map[string]verifierKey)
+	for backend, system := range provingSystems {
+		ccs, err := system.compile(&powerCircuit{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fingerprint, err := circuitFingerprint(ccs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var srs *srsStore
+		if backend == BackendPlonk {
+			srs = testSRSStore(t, srsSize(ccs))
+		}
+		pk, vk, err := system.setup(ccs, srs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sets[backend] = &keySet{backend: backend, fingerprint: fingerprint, ccs: ccs, pk: pk, vk: vk}
+		vks[fingerprint] = verifierKey{backend: backend, vk: vk}
+	}
+	s := &Service{circuits: map[string]*circuitKeys{
+		ceremonyCircuit: {current: sets[BackendGroth16], verifyingKeys: vks},
+	}}
+	return s, sets
+}
+
+// batchItem proves knowledge of x with ks, for Y = x^32.
+func batchItem(t *testing.T, ks *keySet, x int64) BatchItem {
+	t.Helper()
+	y := new(big.Int).Exp(big.NewInt(x), big.NewInt(32), nil)
+	full, err := frontend.NewWitness(&powerCircuit{X: x, Y: y}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := provingSystems[ks.backend].prove(ks.ccs, ks.pk, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicData, err := public.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BatchItem{
+		ProofType:     ceremonyCircuit,
+		Backend:       string(ks.backend),
+		Fingerprint:   ks.fingerprint,
+		Proof:         proof,
+		PublicWitness: publicData,
+	}
+}
+
+// decodeBatch decodes Groth16 items for batchPairingCheck.
+func decodeBatch(t *testing.T, s *Service, items []BatchItem) []*batchProof {
+	t.Helper()
+	group := make([]*batchProof, len(items))
+	for i, item := range items {
+		vk, err := s.verifierKey(item.ProofType, item.Backend, item.Fingerprint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if group[i], err = decodeBatchItem(item, vk); err != nil {
+			t.Fatal(err)
+		}
+		group[i].index = i
+	}
+	return group
+}
+
+// assertBatch checks that exactly the items at the invalid indexes fail.
+func assertBatch(t *testing.T, results []BatchResult, invalid ...int) {
+	t.Helper()
+	bad := make(map[int]bool)
+	for _, i := range invalid {
+		bad[i] = true
+	}
+	for i, r := range results {
+		if r.Valid == bad[i] {
+			t.Fatalf("item %d: valid %v (%v), want %v", i, r.Valid, r.Err, !bad[i])
+		}
+	}
+}
+
+func TestVerifyBatchValid(t *testing.T) {
+	s, sets := testBatchService(t)
+	items := []BatchItem{
+		batchItem(t, sets[BackendGroth16], 2),
+		batchItem(t, sets[BackendGroth16], 3),
+		batchItem(t, sets[BackendGroth16], 5),
+	}
+	if !batchPairingCheck(decodeBatch(t, s, items)) {
+		t.Fatal("folded check rejected a batch of valid proofs")
+	}
+	assertBatch(t, s.VerifyBatch(items))
+}
+
+func TestVerifyBatchIsolatesInvalid(t *testing.T) {
+	s, sets := testBatchService(t)
+	groth := sets[BackendGroth16]
+
+	// A proof for another public input, and one made with the proving key of
+	// another trusted setup.
+	mismatched := batchItem(t, groth, 2)
+	mismatched.PublicWitness = batchItem(t, groth, 3).PublicWitness
+	pk, _, err := provingSystems[BackendGroth16].setup(groth.ccs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := batchItem(t, &keySet{backend: BackendGroth16, fingerprint: groth.fingerprint, ccs: groth.ccs, pk: pk}, 5)
+
+	for name, bad := range map[string]BatchItem{"mismatched witness": mismatched, "forged": forged} {
+		items := []BatchItem{batchItem(t, groth, 2), bad, batchItem(t, groth, 3), batchItem(t, groth, 7)}
+		if batchPairingCheck(decodeBatch(t, s, items)) {
+			t.Fatalf("%s: folded check accepted the batch", name)
+		}
+		results := s.VerifyBatch(items)
+		assertBatch(t, results, 1)
+		if results[1].Err != nil {
+			t.Fatalf("%s: invalid proof reported as an error: %v", name, results[1].Err)
+		}
+	}
+}
+
+func TestVerifyBatchVerifyingKeyMismatch(t *testing.T) {
+	s, sets := testBatchService(t)
+	vkFingerprint, err := verifyingKeyFingerprint(sets[BackendGroth16].vk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []BatchItem{batchItem(t, sets[BackendGroth16], 2), batchItem(t, sets[BackendGroth16], 3)}
+	items[0].VerifyingKey = vkFingerprint
+	items[1].VerifyingKey = strings.Repeat("0", len(vkFingerprint))
+
+	results := s.VerifyBatch(items)
+	assertBatch(t, results, 1)
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "different trusted setup") {
+		t.Fatalf("other verifying key: got %v", results[1].Err)
+	}
+}
+
+func TestVerifyBatchMixedBackends(t *testing.T) {
+	s, sets := testBatchService(t)
+	groth, plonk := sets[BackendGroth16], sets[BackendPlonk]
+	mismatched := batchItem(t, plonk, 2)
+	mismatched.PublicWitness = batchItem(t, plonk, 3).PublicWitness
+	items := []BatchItem{
+		batchItem(t, plonk, 2),
+		batchItem(t, groth, 2),
+		mismatched,
+		batchItem(t, groth, 3),
+		batchItem(t, plonk, 5),
+	}
+	assertBatch(t, s.VerifyBatch(items), 2)
+
+	// A proof checked against the other backend's keys is not verified.
+	wrong := batchItem(t, groth, 2)
+	wrong.Backend, wrong.Fingerprint = string(BackendPlonk), plonk.fingerprint
+	results := s.VerifyBatch([]BatchItem{wrong})
+	if results[0].Valid {
+		t.Fatal("PLONK key accepted a Groth16 proof")
+	}
+}
@@ -0,0 +1,155 @@
+package zkp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// BundleVersion is the current proof bundle format version.
+const BundleVersion = 1
+
+// bundleTextPrefix marks the compact text form of a bundle: zlib-compressed
+// CBOR, base45-encoded, as used for QR codes.
+const bundleTextPrefix = "LP1:"
+
+// maxBundleSize bounds a decompressed bundle; real bundles are a few hundred
+// bytes.
+const maxBundleSize = 64 << 10
+
+// Bundle is a self-contained proof that a node which has never seen the
+// document can verify, given the same circuit keys.
+type Bundle struct {
+	Version   int    `cbor:"1,keyasint" json:"version"`
+	ProofType string `cbor:"2,keyasint" json:"proof_type"`
+	// Fingerprint identifies the circuit, VerifyingKey the exact verifying
+	// key the proof was made for (see Service.VerifyingKeyFingerprint).
+	Fingerprint   string        `cbor:"3,keyasint" json:"fingerprint"`
+	VerifyingKey  string        `cbor:"4,keyasint" json:"verifying_key"`
+	Proof         []byte        `cbor:"5,keyasint" json:"proof"`
+	PublicWitness []byte        `cbor:"6,keyasint" json:"public_witness"`
+	Issuer        *BundleIssuer `cbor:"7,keyasint,omitempty" json:"issuer,omitempty"`
+	// CreatedAt is the proof's creation time in unix seconds.
+	CreatedAt int64 `cbor:"8,keyasint" json:"created_at"`
+	// Backend is the proof's backend; bundles without one are Groth16.
+	Backend string `cbor:"9,keyasint,omitempty" json:"backend,omitempty"`
+	// Holder, if set, is the holder who exported the proof, with their
+	// signature over the rest of the bundle.
+	Holder *BundleHolder `cbor:"10,keyasint,omitempty" json:"holder,omitempty"`
+}
+
+// BundleIssuer is the issuer as the exporting node knew it. Verifiers should
+// trust their own issuer registry over Name.
+type BundleIssuer struct {
+	PublicKey string `cbor:"1,keyasint" json:"public_key"`
+	Name      string `cbor:"2,keyasint,omitempty" json:"name,omitempty"`
+}
+
+// BundleHolder attributes a bundle to the holder who exported it.
+type BundleHolder struct {
+	PublicKey string `cbor:"1,keyasint" json:"public_key"`
+	Signature []byte `cbor:"2,keyasint" json:"signature"`
+}
+
+// SigningBytes returns what the holder signs: the bundle without Holder, in
+// deterministic CBOR.
+func (b *Bundle) SigningBytes() ([]byte, error) {
+	unsigned := *b
+	unsigned.Holder = nil
+	em, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to encode bundle: %w", err)
+	}
+	data, err := em.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to encode bundle: %w", err)
+	}
+	return data, nil
+}
+
+// Created returns the bundle's creation time.
+func (b *Bundle) Created() time.Time {
+	return time.Unix(b.CreatedAt, 0).UTC()
+}
+
+// CBOR encodes the bundle as CBOR with integer keys.
+func (b *Bundle) CBOR() ([]byte, error) {
+	data, err := cbor.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to encode bundle: %w", err)
+	}
+	return data, nil
+}
+
+// Text encodes the bundle in its compact text form, LP1:<base45>.
+func (b *Bundle) Text() (string, error) {
+	data, err := b.CBOR()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("zkp: failed to compress bundle: %w", err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		return "", fmt.Errorf("zkp: failed to compress bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("zkp: failed to compress bundle: %w", err)
+	}
+	return bundleTextPrefix + base45Encode(buf.Bytes()), nil
+}
+
+// ParseBundle decodes a bundle in any of its forms: JSON, CBOR or the
+// LP1: text form.
+func ParseBundle(data []byte) (*Bundle, error) {
+	data = bytes.TrimSpace(data)
+
+	b := &Bundle{}
+	switch {
+	case bytes.HasPrefix(data, []byte(bundleTextPrefix)):
+		raw, err := base45Decode(strings.TrimPrefix(string(data), bundleTextPrefix))
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("zkp: failed to decompress bundle: %w", err)
+		}
+		defer zr.Close()
+		cborData, err := io.ReadAll(io.LimitReader(zr, maxBundleSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("zkp: failed to decompress bundle: %w", err)
+		}
+		if len(cborData) > maxBundleSize {
+			return nil, fmt.Errorf("zkp: bundle exceeds %d bytes", maxBundleSize)
+		}
+		if err := cbor.Unmarshal(cborData, b); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode bundle: %w", err)
+		}
+	case bytes.HasPrefix(data, []byte("{")):
+		if err := json.Unmarshal(data, b); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode bundle: %w", err)
+		}
+	default:
+		if err := cbor.Unmarshal(data, b); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode bundle: %w", err)
+		}
+	}
+
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("zkp: unsupported bundle version %d", b.Version)
+	}
+	if b.ProofType == "" || len(b.Proof) == 0 || len(b.PublicWitness) == 0 {
+		return nil, fmt.Errorf("zkp: bundle is missing its proof")
+	}
+	return b, nil
+}
@@ -0,0 +1,131 @@
+package zkp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBase45Vectors(t *testing.T) {
+	// RFC 9285, section 4.
+	for plain, encoded := range map[string]string{
+		"AB":      "BB8",
+		"Hello!!": "%69 VD92EX0",
+		"base-45": "UJCLQE7W581",
+		"ietf!":   "QED8WEX0",
+		"":        "",
+	} {
+		if got := base45Encode([]byte(plain)); got != encoded {
+			t.Fatalf("encode %q = %q, want %q", plain, got, encoded)
+		}
+		got, err := base45Decode(encoded)
+		if err != nil {
+			t.Fatalf("decode %q: %v", encoded, err)
+		}
+		if string(got) != plain {
+			t.Fatalf("decode %q = %q, want %q", encoded, got, plain)
+		}
+	}
+}
+
+func TestBase45Invalid(t *testing.T) {
+	for _, s := range []string{
+		"B",     // a lone character encodes nothing
+		"BB8B",  // nor does one after a full triplet
+		"GGW",   // 65536 does not fit in two bytes
+		":::",   // the largest triplet
+		"::",    // 2024 does not fit in one byte
+		"bb8",   // lowercase is outside the alphabet
+		"BB8\n", // and so is a newline
+	} {
+		if _, err := base45Decode(s); err == nil {
+			t.Fatalf("decoded %q", s)
+		}
+	}
+}
+
+func testBundle() *Bundle {
+	return &Bundle{
+		Version:       BundleVersion,
+		ProofType:     "degree",
+		Fingerprint:   "circuit",
+		VerifyingKey:  "vk",
+		Proof:         []byte{1, 2, 3},
+		PublicWitness: []byte{4, 5, 6},
+		Issuer:        &BundleIssuer{PublicKey: "ab", Name: "Manipur University"},
+		CreatedAt:     1767225600,
+		Backend:       string(BackendPlonk),
+		Holder:        &BundleHolder{PublicKey: "cd", Signature: []byte{7, 8}},
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	b := testBundle()
+	text, err := b.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, bundleTextPrefix) {
+		t.Fatalf("text form %q lacks its prefix", text)
+	}
+	cborData, err := b.CBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"text": []byte(" " + text + "\n"), "cbor": cborData, "json": jsonData} {
+		got, err := ParseBundle(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, b) {
+			t.Fatalf("%s: parsed %+v, want %+v", name, got, b)
+		}
+	}
+}
+
+func TestParseBundleInvalid(t *testing.T) {
+	old := testBundle()
+	old.Version = BundleVersion + 1
+	empty := testBundle()
+	empty.Proof = nil
+	for name, b := range map[string]*Bundle{"version": old, "no proof": empty} {
+		text, err := b.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseBundle([]byte(text)); err == nil {
+			t.Fatalf("%s: parsed an invalid bundle", name)
+		}
+	}
+
+	if _, err := ParseBundle([]byte(bundleTextPrefix + "not base45")); err == nil {
+		t.Fatal("parsed invalid base45")
+	}
+	if _, err := ParseBundle([]byte(bundleTextPrefix + base45Encode([]byte("not zlib")))); err == nil {
+		t.Fatal("parsed a bundle that is not compressed")
+	}
+}
+
+func TestParseBundleTooLarge(t *testing.T) {
+	// A few hundred bytes that decompress past the limit.
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(make([]byte, maxBundleSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseBundle([]byte(bundleTextPrefix + base45Encode(buf.Bytes())))
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("oversize bundle: got %v", err)
+	}
+}
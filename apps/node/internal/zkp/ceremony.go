@@ -0,0 +1,543 @@
+package zkp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	groth16bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/groth16/bn254/mpcsetup"
+	cs "github.com/consensys/gnark/constraint/bn254"
+)
+
+// A ceremony transcript directory looks like:
+//
+//	ceremony.json        manifest listing every contribution
+//	phase1/0000.bin      initial powers of tau (no secret)
+//	phase1/0001.bin ...  one file per phase-1 contribution
+//	phase2/0000.bin      phase-2 state derived from the last phase-1 file
+//	phase2/0001.bin ...  one file per phase-2 contribution
+//
+// Every file is in gnark's mpcsetup encoding, so the transcript can be
+// re-verified with nothing but the circuit definition.
+const (
+	ceremonyManifestFile = "ceremony.json"
+
+	// CeremonyPhase1 is the circuit-independent powers-of-tau phase.
+	CeremonyPhase1 = 1
+	// CeremonyPhase2 is the circuit-specific phase that produces δ.
+	CeremonyPhase2 = 2
+)
+
+// CeremonyContribution records one accepted contribution.
+type CeremonyContribution struct {
+	Phase     int       `json:"phase"`
+	Index     int       `json:"index"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CeremonyManifest is the human-readable index of a ceremony transcript.
+type CeremonyManifest struct {
+	Circuit       string                 `json:"circuit"`
+	Fingerprint   string                 `json:"fingerprint"`
+	Power         int                    `json:"power"`
+	Phase         int                    `json:"phase"`
+	Finalized     bool                   `json:"finalized"`
+	Contributions []CeremonyContribution `json:"contributions"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+// Ceremony is a multi-party Groth16 setup for one circuit, backed by a
+// transcript directory. It is safe for concurrent use.
+type Ceremony struct {
+	dir      string
+	r1cs     *cs.R1CS
+	manifest CeremonyManifest
+	mu       sync.Mutex
+}
+
+// InitCeremony starts a new ceremony for circuitName in dir.
+func InitCeremony(dir, circuitName string) (*Ceremony, error) {
+	if _, err := os.Stat(filepath.Join(dir, ceremonyManifestFile)); err == nil {
+		return nil, fmt.Errorf("zkp: ceremony already initialised in %s", dir)
+	}
+
+	r1cs, fingerprint, err := compileCeremonyCircuit(circuitName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Ceremony{
+		dir:  dir,
+		r1cs: r1cs,
+		manifest: CeremonyManifest{
+			Circuit:     circuitName,
+			Fingerprint: fingerprint,
+			Power:       ceremonyPower(r1cs.GetNbConstraints()),
+			Phase:       CeremonyPhase1,
+			CreatedAt:   time.Now().UTC(),
+		},
+	}
+
+	for _, phase := range []int{CeremonyPhase1, CeremonyPhase2} {
+		if err := os.MkdirAll(filepath.Join(dir, phaseDir(phase)), 0755); err != nil {
+			return nil, fmt.Errorf("zkp: failed to create ceremony dir: %w", err)
+		}
+	}
+
+	srs1 := mpcsetup.InitPhase1(c.manifest.Power)
+	if err := c.writeState(CeremonyPhase1, 0, &srs1); err != nil {
+		return nil, err
+	}
+	if err := c.saveManifest(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OpenCeremony loads an existing ceremony from dir. It fails if the circuit
+// definition has changed since the ceremony was initialised.
+func OpenCeremony(dir string) (*Ceremony, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, ceremonyManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to read ceremony manifest: %w", err)
+	}
+	var manifest CeremonyManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("zkp: invalid ceremony manifest: %w", err)
+	}
+
+	r1cs, fingerprint, err := compileCeremonyCircuit(manifest.Circuit)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != manifest.Fingerprint {
+		return nil, fmt.Errorf("zkp: circuit %s changed since the ceremony started (have %s, ceremony is for %s)",
+			manifest.Circuit, fingerprint[:16], manifest.Fingerprint[:16])
+	}
+
+	return &Ceremony{dir: dir, r1cs: r1cs, manifest: manifest}, nil
+}
+
+// Manifest returns a copy of the ceremony manifest.
+func (c *Ceremony) Manifest() CeremonyManifest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.manifest
+	m.Contributions = append([]CeremonyContribution(nil), c.manifest.Contributions...)
+	return m
+}
+
+// Latest returns the phase, index and encoded state that the next
+// contribution must build on.
+func (c *Ceremony) Latest() (phase, index int, state []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.manifest.Finalized {
+		return 0, 0, nil, errors.New("zkp: ceremony is finalized")
+	}
+	phase = c.manifest.Phase
+	index = c.latestIndex(phase)
+	state, err = os.ReadFile(c.statePath(phase, index))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("zkp: failed to read ceremony state: %w", err)
+	}
+	return phase, index, state, nil
+}
+
+// Contribute adds fresh local randomness to the current phase. The secret
+// never leaves this function.
+func (c *Ceremony) Contribute(name string) (*CeremonyContribution, error) {
+	phase, index, state, err := c.Latest()
+	if err != nil {
+		return nil, err
+	}
+	next, err := ContributeState(phase, state)
+	if err != nil {
+		return nil, err
+	}
+	return c.Submit(name, phase, index, next)
+}
+
+// Submit verifies a contribution made on top of state (phase, index) and
+// appends it to the transcript. Contributions based on a stale state are
+// rejected so that two participants cannot fork the chain.
+func (c *Ceremony) Submit(name string, phase, basedOn int, contribution []byte) (*CeremonyContribution, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.manifest.Finalized {
+		return nil, errors.New("zkp: ceremony is finalized")
+	}
+	if phase != c.manifest.Phase {
+		return nil, fmt.Errorf("zkp: contribution is for phase %d, ceremony is in phase %d", phase, c.manifest.Phase)
+	}
+	latest := c.latestIndex(phase)
+	if basedOn != latest {
+		return nil, fmt.Errorf("zkp: contribution is based on state %d, latest is %d", basedOn, latest)
+	}
+
+	var hash []byte
+	switch phase {
+	case CeremonyPhase1:
+		prev, next := new(mpcsetup.Phase1), new(mpcsetup.Phase1)
+		if err := c.readState(phase, latest, prev); err != nil {
+			return nil, err
+		}
+		if _, err := next.ReadFrom(bytes.NewReader(contribution)); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode contribution: %w", err)
+		}
+		if err := mpcsetup.VerifyPhase1(prev, next); err != nil {
+			return nil, fmt.Errorf("zkp: contribution rejected: %w", err)
+		}
+		if err := c.writeState(phase, latest+1, next); err != nil {
+			return nil, err
+		}
+		hash = next.Hash
+	case CeremonyPhase2:
+		prev, next := new(mpcsetup.Phase2), new(mpcsetup.Phase2)
+		if err := c.readState(phase, latest, prev); err != nil {
+			return nil, err
+		}
+		if _, err := next.ReadFrom(bytes.NewReader(contribution)); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode contribution: %w", err)
+		}
+		if err := mpcsetup.VerifyPhase2(prev, next); err != nil {
+			return nil, fmt.Errorf("zkp: contribution rejected: %w", err)
+		}
+		if err := c.writeState(phase, latest+1, next); err != nil {
+			return nil, err
+		}
+		hash = next.Hash
+	}
+
+	entry := CeremonyContribution{
+		Phase:     phase,
+		Index:     latest + 1,
+		Name:      name,
+		Hash:      hex.EncodeToString(hash),
+		CreatedAt: time.Now().UTC(),
+	}
+	c.manifest.Contributions = append(c.manifest.Contributions, entry)
+	if err := c.saveManifest(); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ContributeState decodes a ceremony state, mixes in fresh randomness and
+// returns the encoded contribution. It is what a remote participant runs.
+func ContributeState(phase int, state []byte) ([]byte, error) {
+	var obj interface {
+		io.ReaderFrom
+		io.WriterTo
+	}
+	switch phase {
+	case CeremonyPhase1:
+		srs1 := new(mpcsetup.Phase1)
+		if _, err := srs1.ReadFrom(bytes.NewReader(state)); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode phase 1 state: %w", err)
+		}
+		srs1.Contribute()
+		obj = srs1
+	case CeremonyPhase2:
+		srs2 := new(mpcsetup.Phase2)
+		if _, err := srs2.ReadFrom(bytes.NewReader(state)); err != nil {
+			return nil, fmt.Errorf("zkp: failed to decode phase 2 state: %w", err)
+		}
+		srs2.Contribute()
+		obj = srs2
+	default:
+		return nil, fmt.Errorf("zkp: unknown ceremony phase %d", phase)
+	}
+
+	var buf bytes.Buffer
+	if _, err := obj.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("zkp: failed to encode contribution: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Verify re-checks the whole transcript from scratch: the initial states, every
+// contribution against its predecessor, the recorded hashes and, when dataDir
+// is set and the ceremony is finalized, the keys installed there.
+func (c *Ceremony) Verify(dataDir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recorded := c.recordedHashes()
+	srs1, err := c.verifyPhase1(recorded)
+	if err != nil {
+		return err
+	}
+	if c.manifest.Phase < CeremonyPhase2 {
+		return nil
+	}
+
+	srs2, evals, err := c.verifyPhase2(srs1, recorded)
+	if err != nil {
+		return err
+	}
+	if !c.manifest.Finalized || dataDir == "" {
+		return nil
+	}
+
+	installed, err := loadKeySet(keysDir(dataDir, c.manifest.Circuit, BackendGroth16), c.manifest.Fingerprint, BackendGroth16)
+	if err != nil {
+		return err
+	}
+	_, vk := mpcsetup.ExtractKeys(srs1, srs2, evals, c.r1cs.GetNbConstraints())
+	if installed.vk.(*groth16bn254.VerifyingKey).IsDifferent(&vk) {
+		return errors.New("zkp: installed verifying key does not match the ceremony transcript")
+	}
+	return nil
+}
+
+// Finalize closes the current phase. Closing phase 1 derives the initial
+// phase-2 state from the last powers of tau; closing phase 2 extracts the
+// proving and verifying keys and installs them under dataDir, where
+// Service picks them up on the next start. Either way it first re-verifies
+// the transcript, as Verify does, and builds on the states it checked, so a
+// state file altered on disk is never finalized.
+func (c *Ceremony) Finalize(dataDir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.manifest.Finalized {
+		return errors.New("zkp: ceremony is already finalized")
+	}
+	if c.latestIndex(c.manifest.Phase) == 0 {
+		return fmt.Errorf("zkp: phase %d has no contributions yet", c.manifest.Phase)
+	}
+
+	recorded := c.recordedHashes()
+	srs1, err := c.verifyPhase1(recorded)
+	if err != nil {
+		return err
+	}
+
+	if c.manifest.Phase == CeremonyPhase1 {
+		srs2, _ := mpcsetup.InitPhase2(c.r1cs, srs1)
+		if err := c.writeState(CeremonyPhase2, 0, &srs2); err != nil {
+			return err
+		}
+		c.manifest.Phase = CeremonyPhase2
+		return c.saveManifest()
+	}
+
+	srs2, evals, err := c.verifyPhase2(srs1, recorded)
+	if err != nil {
+		return err
+	}
+	pk, vk := mpcsetup.ExtractKeys(srs1, srs2, evals, c.r1cs.GetNbConstraints())
+
+	dir := keysDir(dataDir, c.manifest.Circuit, BackendGroth16)
+	ks := &keySet{backend: BackendGroth16, fingerprint: c.manifest.Fingerprint, ccs: c.r1cs, pk: &pk, vk: &vk}
+	if err := saveKeySet(dir, ks); err != nil {
+		return err
+	}
+
+	c.manifest.Finalized = true
+	if err := c.saveManifest(); err != nil {
+		return err
+	}
+
+	// Keep the manifest next to the keys so their provenance is on record.
+	return writeFileAtomic(filepath.Join(dir, c.manifest.Fingerprint, ceremonyManifestFile), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(c.manifest)
+	})
+}
+
+// recordedHashes maps each (phase, index) in the manifest to the hash it
+// recorded. The caller must hold c.mu.
+func (c *Ceremony) recordedHashes() map[[2]int]string {
+	recorded := make(map[[2]int]string)
+	for _, entry := range c.manifest.Contributions {
+		recorded[[2]int{entry.Phase, entry.Index}] = entry.Hash
+	}
+	return recorded
+}
+
+func (c *Ceremony) verifyPhase1(recorded map[[2]int]string) (*mpcsetup.Phase1, error) {
+	prev := new(mpcsetup.Phase1)
+	if err := c.readState(CeremonyPhase1, 0, prev); err != nil {
+		return nil, err
+	}
+	if !isInitialPhase1(prev, c.manifest.Power) {
+		return nil, errors.New("zkp: phase 1 initial state is not the standard powers of tau")
+	}
+
+	for i := 1; i <= c.latestIndex(CeremonyPhase1); i++ {
+		next := new(mpcsetup.Phase1)
+		if err := c.readState(CeremonyPhase1, i, next); err != nil {
+			return nil, err
+		}
+		if err := mpcsetup.VerifyPhase1(prev, next); err != nil {
+			return nil, fmt.Errorf("zkp: phase 1 contribution %d: %w", i, err)
+		}
+		if recorded[[2]int{CeremonyPhase1, i}] != hex.EncodeToString(next.Hash) {
+			return nil, fmt.Errorf("zkp: phase 1 contribution %d does not match the manifest", i)
+		}
+		prev = next
+	}
+	return prev, nil
+}
+
+func (c *Ceremony) verifyPhase2(srs1 *mpcsetup.Phase1, recorded map[[2]int]string) (*mpcsetup.Phase2, *mpcsetup.Phase2Evaluations, error) {
+	prev := new(mpcsetup.Phase2)
+	if err := c.readState(CeremonyPhase2, 0, prev); err != nil {
+		return nil, nil, err
+	}
+	initial, evals := mpcsetup.InitPhase2(c.r1cs, srs1)
+	if !samePhase2Parameters(prev, &initial) {
+		return nil, nil, errors.New("zkp: phase 2 initial state was not derived from the final phase 1 state")
+	}
+
+	for i := 1; i <= c.latestIndex(CeremonyPhase2); i++ {
+		next := new(mpcsetup.Phase2)
+		if err := c.readState(CeremonyPhase2, i, next); err != nil {
+			return nil, nil, err
+		}
+		if err := mpcsetup.VerifyPhase2(prev, next); err != nil {
+			return nil, nil, fmt.Errorf("zkp: phase 2 contribution %d: %w", i, err)
+		}
+		if recorded[[2]int{CeremonyPhase2, i}] != hex.EncodeToString(next.Hash) {
+			return nil, nil, fmt.Errorf("zkp: phase 2 contribution %d does not match the manifest", i)
+		}
+		prev = next
+	}
+	return prev, &evals, nil
+}
+
+// latestIndex is the index of the newest state file of phase. The caller
+// must hold c.mu.
+func (c *Ceremony) latestIndex(phase int) int {
+	latest := 0
+	for _, entry := range c.manifest.Contributions {
+		if entry.Phase == phase && entry.Index > latest {
+			latest = entry.Index
+		}
+	}
+	return latest
+}
+
+func (c *Ceremony) statePath(phase, index int) string {
+	return filepath.Join(c.dir, phaseDir(phase), fmt.Sprintf("%04d.bin", index))
+}
+
+func (c *Ceremony) readState(phase, index int, obj io.ReaderFrom) error {
+	raw, err := os.ReadFile(c.statePath(phase, index))
+	if err != nil {
+		return fmt.Errorf("zkp: failed to read ceremony state: %w", err)
+	}
+	if _, err := obj.ReadFrom(bytes.NewReader(raw)); err != nil {
+		return fmt.Errorf("zkp: failed to decode %s: %w", c.statePath(phase, index), err)
+	}
+	return nil
+}
+
+func (c *Ceremony) writeState(phase, index int, obj io.WriterTo) error {
+	return writeFileAtomic(c.statePath(phase, index), func(w io.Writer) error {
+		_, err := obj.WriteTo(w)
+		return err
+	})
+}
+
+func (c *Ceremony) saveManifest() error {
+	return writeFileAtomic(filepath.Join(c.dir, ceremonyManifestFile), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c.manifest)
+	})
+}
+
+// isInitialPhase1 reports whether srs1 is the secret-free starting point of
+// InitPhase1: every parameter is a group generator (τ = α = β = 1). Its public
+// keys are randomised, so the hash alone cannot be compared.
+func isInitialPhase1(srs1 *mpcsetup.Phase1, power int) bool {
+	n := 1 << power
+	p := &srs1.Parameters
+	if len(p.G1.Tau) != 2*n-1 || len(p.G2.Tau) != n || len(p.G1.AlphaTau) != n || len(p.G1.BetaTau) != n {
+		return false
+	}
+
+	_, _, g1, g2 := curve.Generators()
+	for _, points := range [][]curve.G1Affine{p.G1.Tau, p.G1.AlphaTau, p.G1.BetaTau} {
+		for i := range points {
+			if !points[i].Equal(&g1) {
+				return false
+			}
+		}
+	}
+	for i := range p.G2.Tau {
+		if !p.G2.Tau[i].Equal(&g2) {
+			return false
+		}
+	}
+	return p.G2.Beta.Equal(&g2)
+}
+
+// samePhase2Parameters compares the δ-dependent parameters of two phase-2
+// states, ignoring their (randomised) public keys.
+func samePhase2Parameters(a, b *mpcsetup.Phase2) bool {
+	pa, pb := &a.Parameters, &b.Parameters
+	if len(pa.G1.L) != len(pb.G1.L) || len(pa.G1.Z) != len(pb.G1.Z) {
+		return false
+	}
+	if !pa.G1.Delta.Equal(&pb.G1.Delta) || !pa.G2.Delta.Equal(&pb.G2.Delta) {
+		return false
+	}
+	for i := range pa.G1.L {
+		if !pa.G1.L[i].Equal(&pb.G1.L[i]) {
+			return false
+		}
+	}
+	for i := range pa.G1.Z {
+		if !pa.G1.Z[i].Equal(&pb.G1.Z[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func phaseDir(phase int) string {
+	return fmt.Sprintf("phase%d", phase)
+}
+
+// ceremonyPower is the smallest power of two covering nbConstraints, which is
+// the FFT domain the keys are extracted over.
+func ceremonyPower(nbConstraints int) int {
+	if nbConstraints <= 1 {
+		return 1
+	}
+	return bits.Len(uint(nbConstraints - 1))
+}
+
+func compileCeremonyCircuit(name string) (*cs.R1CS, string, error) {
+	ccs, err := compileCircuit(name, groth16System{})
+	if err != nil {
+		return nil, "", err
+	}
+	fingerprint, err := circuitFingerprint(ccs)
+	if err != nil {
+		return nil, "", err
+	}
+	r1cs, ok := ccs.(*cs.R1CS)
+	if !ok {
+		return nil, "", fmt.Errorf("zkp: circuit %s is not a BN254 R1CS", name)
+	}
+	return r1cs, fingerprint, nil
+}
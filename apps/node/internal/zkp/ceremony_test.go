@@ -0,0 +1,126 @@
+package zkp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ceremonyCircuit is a proof type registered for the ceremony tests only,
+// small enough that a ceremony over it runs in moments.
+const ceremonyCircuit = "ceremony_test"
+
+var _ = registerProofType(&ProofType{
+	Name:    ceremonyCircuit,
+	Circuit: func() frontend.Circuit { return &powerCircuit{} },
+	Public:  []PublicInput{{Name: "y", Kind: PublicField}},
+})
+
+func TestCeremony(t *testing.T) {
+	dir, dataDir := t.TempDir(), t.TempDir()
+	c, err := InitCeremony(dir, ceremonyCircuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Finalize(dataDir); err == nil {
+		t.Fatal("finalized phase 1 without contributions")
+	}
+
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := c.Contribute(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Finalize(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Contribute("carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Finalize(dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopened from the transcript alone, the ceremony checks out against
+	// the keys it installed.
+	c, err = OpenCeremony(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.Manifest()
+	if !m.Finalized || len(m.Contributions) != 3 {
+		t.Fatalf("manifest: finalized %v with %d contributions", m.Finalized, len(m.Contributions))
+	}
+	if err := c.Verify(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Contribute("dave"); err == nil {
+		t.Fatal("contributed to a finalized ceremony")
+	}
+
+	// The installed keys prove and verify.
+	ks, err := loadKeySet(keysDir(dataDir, ceremonyCircuit, BackendGroth16), m.Fingerprint, BackendGroth16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := frontend.NewWitness(&powerCircuit{X: 2, Y: uint64(1) << 32}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := w.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := groth16System{}.prove(ks.ccs, ks.pk, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := (groth16System{}).verify(proof, ks.vk, public); err != nil || !ok {
+		t.Fatalf("proof under the ceremony keys does not verify: %v", err)
+	}
+}
+
+func TestCeremonyFinalizeRejectsAlteredTranscript(t *testing.T) {
+	dir := t.TempDir()
+	c, err := InitCeremony(dir, ceremonyCircuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Contribute("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace alice's contribution on disk with another valid one that the
+	// manifest never recorded.
+	_, _, state, err := c.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial, err := os.ReadFile(filepath.Join(dir, phaseDir(CeremonyPhase1), "0000.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := ContributeState(CeremonyPhase1, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(forged) == string(state) {
+		t.Fatal("forged contribution equals the recorded one")
+	}
+	if err := os.WriteFile(filepath.Join(dir, phaseDir(CeremonyPhase1), "0001.bin"), forged, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Verify(""); err == nil {
+		t.Fatal("verified an altered transcript")
+	}
+	if err := c.Finalize(t.TempDir()); err == nil {
+		t.Fatal("finalized an altered transcript")
+	}
+	if m := c.Manifest(); m.Phase != CeremonyPhase1 {
+		t.Fatalf("ceremony moved to phase %d", m.Phase)
+	}
+}
@@ -0,0 +1,477 @@
+package zkp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+// ErrInvalidClaims is wrapped by every error caused by missing or malformed
+// claims, so callers can tell bad input from proving failures.
+var ErrInvalidClaims = errors.New("zkp: invalid claims")
+
+// Claims are the credential fields a proof is made over, keyed by name as
+// they appear in API requests and in document metadata.
+type Claims map[string]string
+
+// UnmarshalJSON accepts claim values as JSON strings or numbers, so numeric
+// IDs and unix times need not be quoted.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	claims := make(Claims, len(raw))
+	for name, v := range raw {
+		var str string
+		if err := json.Unmarshal(v, &str); err == nil {
+			claims[name] = str
+			continue
+		}
+		var num json.Number
+		if err := json.Unmarshal(v, &num); err != nil {
+			return fmt.Errorf("claim %s must be a string or number", name)
+		}
+		claims[name] = num.String()
+	}
+	*c = claims
+	return nil
+}
+
+// Claim names used by the proof circuits.
+const (
+	ClaimStudentID     = "student_id"
+	ClaimIssueDate     = "issue_date"
+	ClaimValidUntil    = "valid_until"
+	ClaimInstitutionID = "institution_id"
+	ClaimHolderID      = "holder_id"
+	ClaimBirthDate     = "birth_date"
+	ClaimRegion        = "region"
+	// ClaimIssuerPublicKey and ClaimIssuerSignature carry the issuing
+	// institution's key and its signature over the credential commitment.
+	ClaimIssuerPublicKey = "issuer_public_key"
+	ClaimIssuerSignature = "issuer_signature"
+	// ClaimClaimsSignature is the issuer's signature over the Merkle root of
+	// all credential claims, kept apart from ClaimIssuerSignature so one
+	// document can back both kinds of proof.
+	ClaimClaimsSignature = "claims_signature"
+)
+
+// Proof parameters are chosen by the prover rather than signed by an issuer,
+// and travel alongside the claims.
+const (
+	ParamThresholdYears = "threshold_years"
+	ParamReferenceDate  = "reference_date"
+	// ParamDisclose lists the claims a disclosure proof reveals, separated
+	// by commas.
+	ParamDisclose = "disclose"
+)
+
+// maxThresholdYears bounds age thresholds to plausible human ages.
+const maxThresholdYears = 150
+
+// DateEpoch is the origin of dates in the field. Circuits compare dates as
+// whole seconds since DateEpoch rather than unix time, so that birth dates
+// and age cutoffs before 1970 stay positive.
+var DateEpoch = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// FieldDate maps t into the field as seconds since DateEpoch.
+func FieldDate(t time.Time) *big.Int {
+	return big.NewInt(t.Unix() - DateEpoch.Unix())
+}
+
+// DateFromField is the inverse of FieldDate.
+func DateFromField(v *big.Int) (time.Time, error) {
+	if v.Sign() < 0 || !v.IsInt64() {
+		return time.Time{}, fmt.Errorf("zkp: date %s is out of range", v)
+	}
+	return time.Unix(DateEpoch.Unix()+v.Int64(), 0).UTC(), nil
+}
+
+// DegreeClaims holds degree claims mapped into BN254 field elements, plus the
+// issuer's compressed public key and signature.
+type DegreeClaims struct {
+	StudentID       *big.Int
+	IssueDate       *big.Int
+	ValidUntil      *big.Int
+	InstitutionHash *big.Int
+	IssuerPublicKey []byte
+	IssuerSignature []byte
+}
+
+// ParseDegreeClaims validates c and maps it into field elements. Dates are
+// accepted as YYYY-MM-DD, RFC 3339 or unix seconds and become seconds since
+// DateEpoch.
+func ParseDegreeClaims(c Claims) (*DegreeClaims, error) {
+	if err := c.require(ClaimStudentID, ClaimIssueDate, ClaimValidUntil, ClaimInstitutionID,
+		ClaimIssuerPublicKey, ClaimIssuerSignature); err != nil {
+		return nil, err
+	}
+
+	claims, err := parseDegreeFields(c)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuerPublicKey, claims.IssuerSignature, err = c.issuer(); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseDegreeFields parses the credential claims an issuer signs.
+func parseDegreeFields(c Claims) (*DegreeClaims, error) {
+	if err := c.require(ClaimStudentID, ClaimIssueDate, ClaimValidUntil, ClaimInstitutionID); err != nil {
+		return nil, err
+	}
+
+	issueDate, err := c.date(ClaimIssueDate)
+	if err != nil {
+		return nil, err
+	}
+	validUntil, err := c.date(ClaimValidUntil)
+	if err != nil {
+		return nil, err
+	}
+	if validUntil.Cmp(issueDate) < 0 {
+		return nil, fmt.Errorf("%w: %s is before %s", ErrInvalidClaims, ClaimValidUntil, ClaimIssueDate)
+	}
+
+	studentID, err := c.nonZeroIdentifier(ClaimStudentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DegreeClaims{
+		StudentID:       studentID,
+		IssueDate:       issueDate,
+		ValidUntil:      validUntil,
+		InstitutionHash: hashToField([]byte(strings.TrimSpace(c[ClaimInstitutionID]))),
+	}, nil
+}
+
+// IdentityClaims holds identity document claims mapped into field elements,
+// plus the issuer's compressed public key and signature.
+type IdentityClaims struct {
+	HolderID        *big.Int
+	BirthDate       *big.Int
+	ValidUntil      *big.Int
+	IssuerPublicKey []byte
+	IssuerSignature []byte
+}
+
+// ParseIdentityClaims validates c as the claims of an identity document.
+func ParseIdentityClaims(c Claims) (*IdentityClaims, error) {
+	if err := c.require(ClaimHolderID, ClaimBirthDate, ClaimValidUntil,
+		ClaimIssuerPublicKey, ClaimIssuerSignature); err != nil {
+		return nil, err
+	}
+
+	claims, err := parseIdentityFields(c)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuerPublicKey, claims.IssuerSignature, err = c.issuer(); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseIdentityFields parses the identity claims an issuer signs.
+func parseIdentityFields(c Claims) (*IdentityClaims, error) {
+	if err := c.require(ClaimHolderID, ClaimBirthDate, ClaimValidUntil); err != nil {
+		return nil, err
+	}
+
+	birthDate, err := c.date(ClaimBirthDate)
+	if err != nil {
+		return nil, err
+	}
+	validUntil, err := c.date(ClaimValidUntil)
+	if err != nil {
+		return nil, err
+	}
+	holderID, err := c.nonZeroIdentifier(ClaimHolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdentityClaims{
+		HolderID:   holderID,
+		BirthDate:  birthDate,
+		ValidUntil: validUntil,
+	}, nil
+}
+
+// AgeOverClaims holds identity claims together with the age threshold and the
+// reference date the age is proven on.
+type AgeOverClaims struct {
+	*IdentityClaims
+	ThresholdYears int
+	ReferenceDate  time.Time
+	// Cutoff is the latest birth date that meets the threshold on
+	// ReferenceDate (see AgeCutoff).
+	Cutoff time.Time
+}
+
+// ParseAgeOverClaims validates c as identity claims plus the threshold_years
+// and optional reference_date parameters. The reference date defaults to
+// today (UTC).
+func ParseAgeOverClaims(c Claims) (*AgeOverClaims, error) {
+	identity, err := ParseIdentityClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.require(ParamThresholdYears); err != nil {
+		return nil, err
+	}
+
+	years, err := strconv.Atoi(strings.TrimSpace(c[ParamThresholdYears]))
+	if err != nil || years < 1 || years > maxThresholdYears {
+		return nil, fmt.Errorf("%w: %s must be a whole number of years between 1 and %d", ErrInvalidClaims, ParamThresholdYears, maxThresholdYears)
+	}
+
+	reference := time.Now().UTC().Truncate(24 * time.Hour)
+	if strings.TrimSpace(c[ParamReferenceDate]) != "" {
+		secs, err := c.date(ParamReferenceDate)
+		if err != nil {
+			return nil, err
+		}
+		if reference, err = DateFromField(secs); err != nil {
+			return nil, err
+		}
+	}
+	if FieldDate(reference).Cmp(identity.ValidUntil) > 0 {
+		return nil, fmt.Errorf("%w: the identity document expires before %s", ErrInvalidClaims, ParamReferenceDate)
+	}
+
+	cutoff := AgeCutoff(reference, years)
+	if cutoff.Before(DateEpoch) {
+		return nil, fmt.Errorf("%w: %d years before %s is before %d", ErrInvalidClaims, years, reference.Format("2006-01-02"), DateEpoch.Year())
+	}
+	if identity.BirthDate.Cmp(FieldDate(cutoff)) > 0 {
+		return nil, fmt.Errorf("%w: holder is under %d on %s", ErrInvalidClaims, years, reference.Format("2006-01-02"))
+	}
+
+	return &AgeOverClaims{
+		IdentityClaims: identity,
+		ThresholdYears: years,
+		ReferenceDate:  reference,
+		Cutoff:         cutoff,
+	}, nil
+}
+
+// AgeCutoff returns the latest birth date of someone who is at least years
+// old on reference. Someone born on 29 February turns a year older on 1 March
+// in non-leap years.
+func AgeCutoff(reference time.Time, years int) time.Time {
+	return reference.AddDate(-years, 0, 0)
+}
+
+// ResidencyClaims holds residence certificate claims mapped into field
+// elements, plus the issuer's compressed public key and signature.
+type ResidencyClaims struct {
+	HolderID        *big.Int
+	RegionHash      *big.Int
+	ValidUntil      *big.Int
+	IssuerPublicKey []byte
+	IssuerSignature []byte
+}
+
+// ParseResidencyClaims validates c as the claims of a residence certificate.
+// The region is named as issued, e.g. "Imphal West".
+func ParseResidencyClaims(c Claims) (*ResidencyClaims, error) {
+	if err := c.require(ClaimHolderID, ClaimRegion, ClaimValidUntil,
+		ClaimIssuerPublicKey, ClaimIssuerSignature); err != nil {
+		return nil, err
+	}
+
+	claims, err := parseResidencyFields(c)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuerPublicKey, claims.IssuerSignature, err = c.issuer(); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseResidencyFields parses the residency claims an issuer signs.
+func parseResidencyFields(c Claims) (*ResidencyClaims, error) {
+	if err := c.require(ClaimHolderID, ClaimRegion, ClaimValidUntil); err != nil {
+		return nil, err
+	}
+
+	validUntil, err := c.date(ClaimValidUntil)
+	if err != nil {
+		return nil, err
+	}
+	holderID, err := c.nonZeroIdentifier(ClaimHolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResidencyClaims{
+		HolderID:   holderID,
+		RegionHash: RegionHash(c[ClaimRegion]),
+		ValidUntil: validUntil,
+	}, nil
+}
+
+// RegionHash maps a region name into the field the way residency proofs
+// publish it, so verifiers can compare it with the region they expect.
+func RegionHash(region string) *big.Int {
+	return hashToField([]byte(strings.ToLower(strings.TrimSpace(region))))
+}
+
+// DisclosureClaims holds the claims tree of a document and the claims a
+// disclosure proof reveals from it.
+type DisclosureClaims struct {
+	Tree            *ClaimsTree
+	Disclose        []string
+	IssuerPublicKey []byte
+	IssuerSignature []byte
+}
+
+// ParseDisclosureClaims builds the claims tree of c and validates the claims
+// chosen for disclosure.
+func ParseDisclosureClaims(c Claims) (*DisclosureClaims, error) {
+	if err := c.require(ParamDisclose, ClaimIssuerPublicKey, ClaimClaimsSignature); err != nil {
+		return nil, err
+	}
+
+	tree, err := NewClaimsTree(c)
+	if err != nil {
+		return nil, err
+	}
+	claims := &DisclosureClaims{Tree: tree}
+
+	seen := map[string]bool{}
+	for _, name := range strings.Split(c[ParamDisclose], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := tree.index[name]; !ok {
+			return nil, fmt.Errorf("%w: %s is not a credential claim", ErrInvalidClaims, name)
+		}
+		if len(name) > maxTextBytes || len(c[name]) > maxTextBytes {
+			return nil, fmt.Errorf("%w: %s is longer than %d bytes and cannot be disclosed", ErrInvalidClaims, name, maxTextBytes)
+		}
+		claims.Disclose = append(claims.Disclose, name)
+	}
+	if len(claims.Disclose) == 0 || len(claims.Disclose) > maxDisclosedFields {
+		return nil, fmt.Errorf("%w: %s must name 1 to %d claims", ErrInvalidClaims, ParamDisclose, maxDisclosedFields)
+	}
+
+	if claims.IssuerPublicKey, err = c.hexBytes(ClaimIssuerPublicKey, issuerPublicKeySize); err != nil {
+		return nil, err
+	}
+	if claims.IssuerSignature, err = c.hexBytes(ClaimClaimsSignature, issuerSignatureSize); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// require reports every missing or blank claim in one error.
+func (c Claims) require(names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if strings.TrimSpace(c[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: missing %s", ErrInvalidClaims, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// date parses a claim as a point in time and maps it into the field (see
+// FieldDate). Unix seconds may be negative for dates before 1970.
+func (c Claims) date(name string) (*big.Int, error) {
+	v := strings.TrimSpace(c[name])
+
+	t, err := parseDate(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %q is not a date (use YYYY-MM-DD, RFC 3339 or unix seconds)", ErrInvalidClaims, name, v)
+	}
+	if t.Before(DateEpoch) {
+		return nil, fmt.Errorf("%w: %s must not be before %s", ErrInvalidClaims, name, DateEpoch.Format("2006-01-02"))
+	}
+	return FieldDate(t), nil
+}
+
+func parseDate(v string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("not a date")
+}
+
+// identifier maps an ID claim into the field: decimal IDs that fit are used
+// as-is, anything else is hashed.
+func (c Claims) identifier(name string) *big.Int {
+	v := strings.TrimSpace(c[name])
+	if n, ok := new(big.Int).SetString(v, 10); ok && n.Sign() >= 0 && n.Cmp(ecc.BN254.ScalarField()) < 0 {
+		return n
+	}
+	return hashToField([]byte(v))
+}
+
+// nonZeroIdentifier is identifier for IDs the circuits require to be set.
+func (c Claims) nonZeroIdentifier(name string) (*big.Int, error) {
+	id := c.identifier(name)
+	if id.Sign() == 0 {
+		return nil, fmt.Errorf("%w: %s must not be zero", ErrInvalidClaims, name)
+	}
+	return id, nil
+}
+
+// issuer decodes the issuer public key and signature claims.
+func (c Claims) issuer() (publicKey, signature []byte, err error) {
+	if publicKey, err = c.hexBytes(ClaimIssuerPublicKey, issuerPublicKeySize); err != nil {
+		return nil, nil, err
+	}
+	if signature, err = c.hexBytes(ClaimIssuerSignature, issuerSignatureSize); err != nil {
+		return nil, nil, err
+	}
+	return publicKey, signature, nil
+}
+
+// hexBytes decodes a hex claim of exactly size bytes.
+func (c Claims) hexBytes(name string, size int) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(c[name]))
+	if err != nil || len(raw) != size {
+		return nil, fmt.Errorf("%w: %s must be %d hex-encoded bytes", ErrInvalidClaims, name, size)
+	}
+	return raw, nil
+}
+
+// hashToField reduces the SHA-256 of b into the BN254 scalar field.
+func hashToField(b []byte) *big.Int {
+	h := sha256.Sum256(b)
+	return digestToField(h[:])
+}
+
+// digestToField reduces a SHA-256 digest into the BN254 scalar field.
+func digestToField(digest []byte) *big.Int {
+	n := new(big.Int).SetBytes(digest)
+	return n.Mod(n, ecc.BN254.ScalarField())
+}
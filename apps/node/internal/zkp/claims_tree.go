@@ -0,0 +1,124 @@
+package zkp
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// A document's credential claims form a fixed-depth Merkle tree so that a
+// disclosure proof can reveal some of them and hide the rest. Each leaf is
+// the MiMC hash of a claim name and value, leaves are sorted by name, and
+// unused leaves are zero.
+const (
+	claimsTreeDepth = 4
+	maxTreeClaims   = 1 << claimsTreeDepth
+
+	// maxTextBytes is the longest string that fits in one field element.
+	maxTextBytes = 31
+)
+
+// nonCredentialClaims are carried in claims but are not part of the
+// credential an issuer vouches for.
+var nonCredentialClaims = map[string]bool{
+	ClaimIssuerPublicKey: true,
+	ClaimIssuerSignature: true,
+	ClaimClaimsSignature: true,
+	ParamThresholdYears:  true,
+	ParamReferenceDate:   true,
+	ParamDisclose:        true,
+}
+
+// ClaimsTree is the Merkle tree over a document's credential claims.
+type ClaimsTree struct {
+	// levels[0] holds the leaves, the last level the root.
+	levels [][]*big.Int
+	index  map[string]int
+}
+
+// NewClaimsTree builds the tree over the credential claims in c.
+func NewClaimsTree(c Claims) (*ClaimsTree, error) {
+	var names []string
+	for name := range c {
+		if !nonCredentialClaims[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no credential claims", ErrInvalidClaims)
+	}
+	if len(names) > maxTreeClaims {
+		return nil, fmt.Errorf("%w: %d credential claims, at most %d fit in a claims tree", ErrInvalidClaims, len(names), maxTreeClaims)
+	}
+
+	t := &ClaimsTree{index: make(map[string]int, len(names))}
+	leaves := make([]*big.Int, maxTreeClaims)
+	for i := range leaves {
+		leaves[i] = new(big.Int)
+	}
+	for i, name := range names {
+		t.index[name] = i
+		leaves[i] = claimLeaf(name, strings.TrimSpace(c[name]))
+	}
+
+	t.levels = [][]*big.Int{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([]*big.Int, len(level)/2)
+		for i := range next {
+			next[i] = mimcField(level[2*i], level[2*i+1])
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t, nil
+}
+
+// Root returns the tree's root, which the issuer signs.
+func (t *ClaimsTree) Root() *big.Int {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// path returns the siblings of the leaf of claim name from the bottom up, and
+// for each level whether the path node is the right child.
+func (t *ClaimsTree) path(name string) (siblings []*big.Int, right []bool) {
+	i := t.index[name]
+	for _, level := range t.levels[:claimsTreeDepth] {
+		siblings = append(siblings, level[i^1])
+		right = append(right, i&1 == 1)
+		i /= 2
+	}
+	return siblings, right
+}
+
+// ClaimsCommitment is the message an issuer signs for a claims tree: the MiMC
+// hash of the document hash and the tree root, exactly as DisclosureCircuit
+// computes it.
+func ClaimsCommitment(documentDigest []byte, tree *ClaimsTree) []byte {
+	return credentialCommitment(digestToField(documentDigest), tree.Root())
+}
+
+// claimLeaf hashes a claim into its leaf. Values too long for one field
+// element are hashed first; those can be proven but not disclosed.
+func claimLeaf(name, value string) *big.Int {
+	v := hashToField([]byte(value))
+	if len(value) <= maxTextBytes {
+		v = textField(value)
+	}
+	return mimcField(textField(name), v)
+}
+
+// textField packs a string of at most maxTextBytes bytes into a field
+// element, big-endian, as PublicText inputs are decoded. Longer names are
+// hashed.
+func textField(s string) *big.Int {
+	if len(s) > maxTextBytes {
+		return hashToField([]byte(s))
+	}
+	return new(big.Int).SetBytes([]byte(s))
+}
+
+func mimcField(values ...*big.Int) *big.Int {
+	return new(big.Int).SetBytes(credentialCommitment(values...))
+}
@@ -0,0 +1,61 @@
+package zkp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+)
+
+// Every proof publishes a commitment to the document it was made over: the
+// MiMC hash of the document hash and a secret salt. The vault stores the
+// commitment next to the document, so a verifier who was handed it can check
+// that a proof refers to that document, while the salt keeps anyone holding a
+// copy of the document from linking proofs to it.
+
+// Document is the vault document a proof is made over. Proofs only see its
+// SHA-256 digest, so the vault can hash a document as it streams it from disk
+// instead of holding the plaintext in memory.
+type Document struct {
+	Digest []byte
+	// Salt blinds the document commitment (see CommitDocumentDigest).
+	Salt *big.Int
+}
+
+// NewCommitmentSalt returns a random salt for a document commitment.
+func NewCommitmentSalt() (*big.Int, error) {
+	salt, err := rand.Int(rand.Reader, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to generate commitment salt: %w", err)
+	}
+	return salt, nil
+}
+
+// CommitDocumentDigest returns the commitment to the document with SHA-256
+// digest under salt, exactly as the proof circuits compute it.
+func CommitDocumentDigest(digest []byte, salt *big.Int) *big.Int {
+	return new(big.Int).SetBytes(credentialCommitment(digestToField(digest), salt))
+}
+
+// commitment returns doc's commitment, failing if it has no salt.
+func (doc Document) commitment() (*big.Int, error) {
+	if doc.Salt == nil {
+		return nil, fmt.Errorf("zkp: document has no commitment salt")
+	}
+	return CommitDocumentDigest(doc.Digest, doc.Salt), nil
+}
+
+// assertCommitment constrains commitment to be the MiMC hash of documentHash
+// and salt, mirroring CommitDocumentDigest.
+func assertCommitment(api frontend.API, documentHash, salt, commitment frontend.Variable) error {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	h.Write(documentHash, salt)
+	api.AssertIsEqual(h.Sum(), commitment)
+	return nil
+}
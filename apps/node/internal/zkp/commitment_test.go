@@ -0,0 +1,89 @@
+package zkp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// commitmentClaims returns credential claims for every proof type.
+func commitmentClaims() map[string]Claims {
+	return map[string]Claims{
+		"identity": {ClaimHolderID: "1234567", ClaimBirthDate: "1990-04-01", ClaimValidUntil: "2035-01-01"},
+		"age_over": ageClaims("1990-04-01", "18", "2026-01-01"),
+		"degree":   degreeClaims(),
+		"residency": {
+			ClaimHolderID:   "1234567",
+			ClaimRegion:     "Manipur",
+			ClaimValidUntil: "2035-01-01",
+		},
+		"disclosure": {
+			ClaimHolderID:   "1234567",
+			ClaimBirthDate:  "1990-04-01",
+			ClaimValidUntil: "2035-01-01",
+			ParamDisclose:   ClaimHolderID,
+		},
+	}
+}
+
+// setField sets the named witness field of assignment.
+func setField(assignment frontend.Circuit, name string, v frontend.Variable) {
+	reflect.ValueOf(assignment).Elem().FieldByName(name).Set(reflect.ValueOf(&v).Elem())
+}
+
+func TestCommitmentMatchesDocument(t *testing.T) {
+	doc := testDocument()
+	want := CommitDocumentDigest(doc.Digest, doc.Salt)
+	for proofType, c := range commitmentClaims() {
+		circuit, assignment := assign(t, proofType, doc, issue(t, proofType, doc, c))
+		got := reflect.ValueOf(assignment).Elem().FieldByName("DocumentCommitment").Interface()
+		if got.(*big.Int).Cmp(want) != 0 {
+			t.Fatalf("%s: commitment %v, want %v", proofType, got, want)
+		}
+		assertSolved(t, circuit, assignment)
+	}
+}
+
+func TestCommitmentMismatch(t *testing.T) {
+	doc := testDocument()
+	for proofType, c := range commitmentClaims() {
+		c = issue(t, proofType, doc, c)
+
+		// The commitment to the same document under another salt...
+		circuit, assignment := assign(t, proofType, doc, c)
+		setField(assignment, "DocumentCommitment", CommitDocumentDigest(doc.Digest, big.NewInt(7)))
+		assertNotSolved(t, circuit, assignment)
+
+		// ...and the right commitment opened with another salt.
+		circuit, assignment = assign(t, proofType, doc, c)
+		setField(assignment, "Salt", big.NewInt(7))
+		assertNotSolved(t, circuit, assignment)
+	}
+}
+
+func TestCommitmentOtherDocument(t *testing.T) {
+	doc := testDocument()
+	other := sha256.Sum256([]byte("another credential"))
+	for proofType, c := range commitmentClaims() {
+		c = issue(t, proofType, doc, c)
+		pt, err := LookupProofType(proofType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The issuer signed the credential for doc, so it cannot be proven
+		// over another document.
+		_, err = pt.Assign(Document{Digest: other[:], Salt: doc.Salt}, c)
+		if !errors.Is(err, ErrInvalidClaims) {
+			t.Fatalf("%s: assign over another document: got %v, want ErrInvalidClaims", proofType, err)
+		}
+
+		if _, err := pt.Assign(Document{Digest: doc.Digest}, c); err == nil {
+			t.Fatalf("%s: assigned a document without a commitment salt", proofType)
+		}
+	}
+}
@@ -2,20 +2,31 @@
 package zkp
 
 import (
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/signature/eddsa"
 )
 
 // DegreeCircuit defines the circuit for proving degree ownership
 // without revealing the actual degree details
 type DegreeCircuit struct {
 	// Private inputs (witness)
-	DegreeHash   frontend.Variable `gnark:",private"`
-	StudentID    frontend.Variable `gnark:",private"`
-	IssueDate    frontend.Variable `gnark:",private"`
+	DegreeHash frontend.Variable `gnark:",private"`
+	StudentID  frontend.Variable `gnark:",private"`
+	IssueDate  frontend.Variable `gnark:",private"`
+
+	// Salt blinds DocumentCommitment.
+	Salt frontend.Variable `gnark:",private"`
+
+	// IssuerSignature is the institution's EdDSA signature over the degree
+	// commitment (see DegreeCommitment).
+	IssuerSignature eddsa.Signature `gnark:",private"`
 
 	// Public inputs
-	InstitutionHash frontend.Variable `gnark:",public"`
-	ValidUntil      frontend.Variable `gnark:",public"`
+	DocumentCommitment frontend.Variable `gnark:",public"`
+	InstitutionHash    frontend.Variable `gnark:",public"`
+	ValidUntil         frontend.Variable `gnark:",public"`
+	IssuerPublicKey    eddsa.PublicKey   `gnark:",public"`
 }
 
 // Define defines the circuit constraints
@@ -23,6 +34,11 @@ func (c *DegreeCircuit) Define(api frontend.API) error {
 	// Verify degree hash is non-zero (degree exists)
 	api.AssertIsDifferent(c.DegreeHash, 0)
 
+	// Verify the proof is bound to the committed vault document
+	if err := assertCommitment(api, c.DegreeHash, c.Salt, c.DocumentCommitment); err != nil {
+		return err
+	}
+
 	// Verify student ID is valid (non-zero)
 	api.AssertIsDifferent(c.StudentID, 0)
 
@@ -30,10 +46,65 @@ func (c *DegreeCircuit) Define(api frontend.API) error {
 	// This ensures the degree is still valid
 	api.AssertIsLessOrEqual(c.IssueDate, c.ValidUntil)
 
+	// Verify the institution signed exactly these degree details
+	if err := assertIssuerSigned(api, c.IssuerSignature, c.IssuerPublicKey,
+		c.DegreeHash, c.StudentID, c.IssueDate, c.ValidUntil, c.InstitutionHash); err != nil {
+		return err
+	}
+
 	// Additional constraints can be added here:
-	// - Institution signature verification
 	// - Degree type validation
 	// - Accreditation check
 
 	return nil
 }
+
+// degreeProof proves a university degree without revealing the student or
+// the degree details.
+var degreeProof = registerProofType(&ProofType{
+	Name:        "degree",
+	Description: "holds a degree signed by an institution, valid until a public date",
+	Circuit:     func() frontend.Circuit { return &DegreeCircuit{} },
+	Message: func(documentDigest []byte, c Claims) ([]byte, error) {
+		claims, err := parseDegreeFields(c)
+		if err != nil {
+			return nil, err
+		}
+		return DegreeCommitment(documentDigest, claims), nil
+	},
+	Assign: assignDegree,
+	Public: []PublicInput{
+		{Name: PublicDocumentCommitment, Kind: PublicField},
+		{Name: "institution_hash", Kind: PublicField},
+		{Name: ClaimValidUntil, Kind: PublicDate},
+		{Name: ClaimIssuerPublicKey, Kind: PublicKey},
+	},
+})
+
+func assignDegree(doc Document, c Claims) (frontend.Circuit, error) {
+	degree, err := ParseDegreeClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIssuerSignature(degree.IssuerPublicKey, degree.IssuerSignature, DegreeCommitment(doc.Digest, degree)); err != nil {
+		return nil, err
+	}
+
+	commitment, err := doc.commitment()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &DegreeCircuit{
+		DegreeHash:         digestToField(doc.Digest),
+		Salt:               doc.Salt,
+		DocumentCommitment: commitment,
+		StudentID:          degree.StudentID,
+		IssueDate:          degree.IssueDate,
+		InstitutionHash:    degree.InstitutionHash,
+		ValidUntil:         degree.ValidUntil,
+	}
+	assignment.IssuerPublicKey.Assign(tedwards.BN254, degree.IssuerPublicKey)
+	assignment.IssuerSignature.Assign(tedwards.BN254, degree.IssuerSignature)
+	return assignment, nil
+}
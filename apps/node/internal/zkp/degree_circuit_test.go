@@ -0,0 +1,70 @@
+package zkp
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+)
+
+func degreeClaims() Claims {
+	return Claims{
+		ClaimStudentID:     "42",
+		ClaimIssueDate:     "2019-06-30",
+		ClaimValidUntil:    "2029-06-30",
+		ClaimInstitutionID: "manipur-university",
+	}
+}
+
+func TestDegreeSolved(t *testing.T) {
+	doc := testDocument()
+	circuit, assignment := assign(t, "degree", doc, issue(t, "degree", doc, degreeClaims()))
+	assertSolved(t, circuit, assignment)
+}
+
+func TestDegreeBadIssuerSignature(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "degree", doc, degreeClaims())
+
+	// A signature by another key is refused before proving...
+	other, err := GenerateIssuerKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := Claims{}
+	for k, v := range c {
+		forged[k] = v
+	}
+	forged[ClaimIssuerPublicKey] = hex.EncodeToString(other.PublicKey.Bytes())
+	pt, _ := LookupProofType("degree")
+	if _, err := pt.Assign(doc, forged); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("forged issuer key: got %v, want ErrInvalidClaims", err)
+	}
+
+	// ...and cannot satisfy the circuit either.
+	circuit, assignment := assign(t, "degree", doc, c)
+	assignment.(*DegreeCircuit).IssuerPublicKey.Assign(tedwards.BN254, other.PublicKey.Bytes())
+	assertNotSolved(t, circuit, assignment)
+}
+
+func TestDegreeAlteredClaim(t *testing.T) {
+	doc := testDocument()
+	circuit, assignment := assign(t, "degree", doc, issue(t, "degree", doc, degreeClaims()))
+	assignment.(*DegreeCircuit).StudentID = 43
+	assertNotSolved(t, circuit, assignment)
+}
+
+func TestDegreeExpiredBeforeIssue(t *testing.T) {
+	doc := testDocument()
+	circuit, assignment := assign(t, "degree", doc, issue(t, "degree", doc, degreeClaims()))
+	assignment.(*DegreeCircuit).ValidUntil = FieldDate(time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assertNotSolved(t, circuit, assignment)
+
+	c := degreeClaims()
+	c[ClaimValidUntil] = "2018-01-01"
+	if _, err := parseDegreeFields(c); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("credential expiring before issue: got %v, want ErrInvalidClaims", err)
+	}
+}
@@ -0,0 +1,176 @@
+package zkp
+
+import (
+	"fmt"
+	"strings"
+
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/signature/eddsa"
+)
+
+// maxDisclosedFields is how many claims one disclosure proof can reveal.
+const maxDisclosedFields = 4
+
+// DisclosedField is a revealed claim: its name and value packed as text
+// (see textField). Unused slots are zero.
+type DisclosedField struct {
+	Name  frontend.Variable
+	Value frontend.Variable
+}
+
+// ClaimsPath is the Merkle path of one leaf of a claims tree.
+type ClaimsPath struct {
+	Siblings [claimsTreeDepth]frontend.Variable
+	// Right is 1 at the levels where the path node is the right child.
+	Right [claimsTreeDepth]frontend.Variable
+}
+
+// DisclosureCircuit proves that some claims of an issuer-signed claims tree
+// have the published values, without revealing the other claims.
+type DisclosureCircuit struct {
+	// Private inputs (witness)
+	DocumentHash frontend.Variable              `gnark:",private"`
+	ClaimsRoot   frontend.Variable              `gnark:",private"`
+	Paths        [maxDisclosedFields]ClaimsPath `gnark:",private"`
+
+	// Salt blinds DocumentCommitment.
+	Salt frontend.Variable `gnark:",private"`
+
+	// IssuerSignature is the issuer's EdDSA signature over the claims
+	// commitment (see ClaimsCommitment).
+	IssuerSignature eddsa.Signature `gnark:",private"`
+
+	// Public inputs
+	DocumentCommitment frontend.Variable                  `gnark:",public"`
+	Fields             [maxDisclosedFields]DisclosedField `gnark:",public"`
+	IssuerPublicKey    eddsa.PublicKey                    `gnark:",public"`
+}
+
+// Define defines the circuit constraints
+func (c *DisclosureCircuit) Define(api frontend.API) error {
+	api.AssertIsDifferent(c.DocumentHash, 0)
+
+	// Verify the proof is bound to the committed vault document
+	if err := assertCommitment(api, c.DocumentHash, c.Salt, c.DocumentCommitment); err != nil {
+		return err
+	}
+
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(values ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(values...)
+		return h.Sum()
+	}
+
+	// Verify every used slot is a leaf of the claims tree
+	for i, field := range c.Fields {
+		used := api.Sub(1, api.IsZero(field.Name))
+		api.AssertIsEqual(api.Mul(api.Sub(1, used), field.Value), 0)
+
+		node := hash(field.Name, field.Value)
+		path := c.Paths[i]
+		for level := 0; level < claimsTreeDepth; level++ {
+			api.AssertIsBoolean(path.Right[level])
+			left := api.Select(path.Right[level], path.Siblings[level], node)
+			right := api.Select(path.Right[level], node, path.Siblings[level])
+			node = hash(left, right)
+		}
+		api.AssertIsEqual(api.Mul(used, api.Sub(node, c.ClaimsRoot)), 0)
+	}
+
+	// Verify the issuer signed this claims tree for this document
+	return assertIssuerSigned(api, c.IssuerSignature, c.IssuerPublicKey, c.DocumentHash, c.ClaimsRoot)
+}
+
+// disclosureProof reveals chosen claims of a document's metadata.
+var disclosureProof = registerProofType(&ProofType{
+	Name:        "disclosure",
+	Description: "reveals chosen credential claims, signed by an issuer, and hides the rest",
+	Circuit:     func() frontend.Circuit { return &DisclosureCircuit{} },
+	Message: func(documentDigest []byte, c Claims) ([]byte, error) {
+		tree, err := NewClaimsTree(c)
+		if err != nil {
+			return nil, err
+		}
+		return ClaimsCommitment(documentDigest, tree), nil
+	},
+	Assign:         assignDisclosure,
+	Public:         disclosurePublicInputs(),
+	SignatureClaim: ClaimClaimsSignature,
+})
+
+func disclosurePublicInputs() []PublicInput {
+	inputs := []PublicInput{{Name: PublicDocumentCommitment, Kind: PublicField}}
+	for i := 1; i <= maxDisclosedFields; i++ {
+		inputs = append(inputs,
+			PublicInput{Name: fmt.Sprintf("field_%d_name", i), Kind: PublicText},
+			PublicInput{Name: fmt.Sprintf("field_%d_value", i), Kind: PublicText})
+	}
+	return append(inputs, PublicInput{Name: ClaimIssuerPublicKey, Kind: PublicKey})
+}
+
+// DisclosedClaims collects the claims revealed by a disclosure proof from its
+// decoded public inputs. It returns nil for other proof types.
+func DisclosedClaims(proofType string, inputs map[string]string) map[string]string {
+	if proofType != disclosureProof.Name {
+		return nil
+	}
+	disclosed := map[string]string{}
+	for i := 1; i <= maxDisclosedFields; i++ {
+		if name := inputs[fmt.Sprintf("field_%d_name", i)]; name != "" {
+			disclosed[name] = inputs[fmt.Sprintf("field_%d_value", i)]
+		}
+	}
+	return disclosed
+}
+
+func assignDisclosure(doc Document, c Claims) (frontend.Circuit, error) {
+	disclosure, err := ParseDisclosureClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIssuerSignature(disclosure.IssuerPublicKey, disclosure.IssuerSignature, ClaimsCommitment(doc.Digest, disclosure.Tree)); err != nil {
+		return nil, fmt.Errorf("%w: %s does not match the claims", ErrInvalidClaims, ClaimClaimsSignature)
+	}
+
+	commitment, err := doc.commitment()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &DisclosureCircuit{
+		DocumentHash:       digestToField(doc.Digest),
+		ClaimsRoot:         disclosure.Tree.Root(),
+		Salt:               doc.Salt,
+		DocumentCommitment: commitment,
+	}
+	for i := range assignment.Fields {
+		field, path := &assignment.Fields[i], &assignment.Paths[i]
+		field.Name, field.Value = 0, 0
+		for level := range path.Siblings {
+			path.Siblings[level], path.Right[level] = 0, 0
+		}
+		if i >= len(disclosure.Disclose) {
+			continue
+		}
+
+		name := disclosure.Disclose[i]
+		field.Name = textField(name)
+		field.Value = textField(strings.TrimSpace(c[name]))
+		siblings, right := disclosure.Tree.path(name)
+		for level := range siblings {
+			path.Siblings[level] = siblings[level]
+			if right[level] {
+				path.Right[level] = 1
+			}
+		}
+	}
+	assignment.IssuerPublicKey.Assign(tedwards.BN254, disclosure.IssuerPublicKey)
+	assignment.IssuerSignature.Assign(tedwards.BN254, disclosure.IssuerSignature)
+	return assignment, nil
+}
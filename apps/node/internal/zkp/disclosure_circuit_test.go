@@ -0,0 +1,167 @@
+package zkp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+func disclosureClaims(disclose string) Claims {
+	return Claims{
+		ClaimHolderID:   "1234567",
+		ClaimBirthDate:  "1990-04-01",
+		ClaimRegion:     "Manipur",
+		ClaimValidUntil: "2035-01-01",
+		ParamDisclose:   disclose,
+	}
+}
+
+func TestDisclosureSolved(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "disclosure", doc, disclosureClaims("region, holder_id"))
+	circuit, assignment := assign(t, "disclosure", doc, c)
+	assertSolved(t, circuit, assignment)
+
+	// A verifier reads the disclosed claims, and only those, from the public
+	// witness.
+	w, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := w.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := DecodePublicInputs("disclosure", pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disclosed := DisclosedClaims("disclosure", inputs)
+	want := map[string]string{ClaimRegion: "Manipur", ClaimHolderID: "1234567"}
+	if fmt.Sprint(disclosed) != fmt.Sprint(want) {
+		t.Fatalf("disclosed %v, want %v", disclosed, want)
+	}
+}
+
+func TestDisclosureAlteredValue(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "disclosure", doc, disclosureClaims(ClaimRegion))
+	circuit, assignment := assign(t, "disclosure", doc, c)
+	assignment.(*DisclosureCircuit).Fields[0].Value = textField("Assam")
+	assertNotSolved(t, circuit, assignment)
+
+	// Claims changed after signing no longer match the issuer signature.
+	c[ClaimRegion] = "Assam"
+	pt, err := LookupProofType("disclosure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.Assign(doc, c); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("altered claim: got %v, want ErrInvalidClaims", err)
+	}
+}
+
+func TestDisclosureHiddenClaimAsDisclosed(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "disclosure", doc, disclosureClaims(ClaimRegion))
+	circuit, assignment := assign(t, "disclosure", doc, c)
+
+	// Claiming the region leaf's path for another name does not reach the
+	// signed root.
+	assignment.(*DisclosureCircuit).Fields[0].Name = textField(ClaimHolderID)
+	assertNotSolved(t, circuit, assignment)
+
+	// An unused slot cannot carry a value.
+	circuit, assignment = assign(t, "disclosure", doc, c)
+	assignment.(*DisclosureCircuit).Fields[1].Value = textField("Manipur")
+	assertNotSolved(t, circuit, assignment)
+}
+
+func TestDisclosureBadIssuerSignature(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "disclosure", doc, disclosureClaims(ClaimRegion))
+	other := issue(t, "disclosure", doc, disclosureClaims(ClaimRegion))
+
+	circuit, assignment := assign(t, "disclosure", doc, c)
+	_, forged := assign(t, "disclosure", doc, other)
+	assignment.(*DisclosureCircuit).IssuerPublicKey = forged.(*DisclosureCircuit).IssuerPublicKey
+	assertNotSolved(t, circuit, assignment)
+}
+
+func TestDisclosureInvalidClaims(t *testing.T) {
+	doc := testDocument()
+	pt, err := LookupProofType("disclosure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := disclosureClaims(ClaimRegion)
+	c[ClaimInstitutionID] = "manipur-university"
+	c = issue(t, "disclosure", doc, c)
+	for _, disclose := range []string{"", "nickname", "holder_id,birth_date,region,valid_until,institution_id"} {
+		c[ParamDisclose] = disclose
+		if _, err := pt.Assign(doc, c); !errors.Is(err, ErrInvalidClaims) {
+			t.Fatalf("disclose %q: got %v, want ErrInvalidClaims", disclose, err)
+		}
+	}
+}
+
+func TestClaimsTreePaths(t *testing.T) {
+	c := disclosureClaims(ClaimRegion)
+	tree, err := NewClaimsTree(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{ClaimBirthDate, ClaimHolderID, ClaimRegion, ClaimValidUntil} {
+		node := claimLeaf(name, c[name])
+		siblings, right := tree.path(name)
+		for level := range siblings {
+			if right[level] {
+				node = mimcField(siblings[level], node)
+			} else {
+				node = mimcField(node, siblings[level])
+			}
+		}
+		if node.Cmp(tree.Root()) != 0 {
+			t.Fatalf("path of %s does not reach the root", name)
+		}
+	}
+
+	// Parameters are not part of the tree; any claim value is.
+	c[ParamDisclose] = ClaimHolderID
+	same, err := NewClaimsTree(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Root().Cmp(tree.Root()) != 0 {
+		t.Fatal("a parameter changed the claims root")
+	}
+	c[ClaimRegion] = "Assam"
+	changed, err := NewClaimsTree(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Root().Cmp(tree.Root()) == 0 {
+		t.Fatal("a claim value did not change the claims root")
+	}
+}
+
+func TestClaimsTreeLimits(t *testing.T) {
+	if _, err := NewClaimsTree(Claims{ParamDisclose: ClaimRegion}); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("no claims: got %v, want ErrInvalidClaims", err)
+	}
+	c := Claims{}
+	for i := 0; i <= maxTreeClaims; i++ {
+		c[fmt.Sprintf("claim_%d", i)] = "x"
+	}
+	if _, err := NewClaimsTree(c); !errors.Is(err, ErrInvalidClaims) {
+		t.Fatalf("%d claims: got %v, want ErrInvalidClaims", len(c), err)
+	}
+	if textField("abc").Cmp(new(big.Int).SetBytes([]byte("abc"))) != 0 {
+		t.Fatal("short text is not packed big-endian")
+	}
+}
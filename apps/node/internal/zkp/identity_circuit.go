@@ -0,0 +1,100 @@
+package zkp
+
+import (
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/signature/eddsa"
+)
+
+// IdentityCircuit proves possession of an issuer-signed identity document
+// without revealing the holder's ID number or date of birth.
+type IdentityCircuit struct {
+	// Private inputs (witness)
+	DocumentHash frontend.Variable `gnark:",private"`
+	HolderID     frontend.Variable `gnark:",private"`
+	BirthDate    frontend.Variable `gnark:",private"`
+
+	// Salt blinds DocumentCommitment.
+	Salt frontend.Variable `gnark:",private"`
+
+	// IssuerSignature is the issuing office's EdDSA signature over the
+	// identity commitment (see IdentityCommitment).
+	IssuerSignature eddsa.Signature `gnark:",private"`
+
+	// Public inputs
+	DocumentCommitment frontend.Variable `gnark:",public"`
+	ValidUntil         frontend.Variable `gnark:",public"`
+	IssuerPublicKey    eddsa.PublicKey   `gnark:",public"`
+}
+
+// Define defines the circuit constraints
+func (c *IdentityCircuit) Define(api frontend.API) error {
+	// Verify the document and its holder exist
+	api.AssertIsDifferent(c.DocumentHash, 0)
+	api.AssertIsDifferent(c.HolderID, 0)
+
+	// Verify the proof is bound to the committed vault document
+	if err := assertCommitment(api, c.DocumentHash, c.Salt, c.DocumentCommitment); err != nil {
+		return err
+	}
+
+	// Verify the holder was born before the document expires
+	api.AssertIsLessOrEqual(c.BirthDate, c.ValidUntil)
+
+	// Verify the issuer signed exactly these identity details
+	return assertIssuerSigned(api, c.IssuerSignature, c.IssuerPublicKey,
+		c.DocumentHash, c.HolderID, c.BirthDate, c.ValidUntil)
+}
+
+// IdentityCommitment is the message an issuer signs for an identity
+// document, exactly as IdentityCircuit computes it.
+func IdentityCommitment(documentDigest []byte, claims *IdentityClaims) []byte {
+	return credentialCommitment(digestToField(documentDigest), claims.HolderID, claims.BirthDate, claims.ValidUntil)
+}
+
+// identityProof proves the holder has a valid identity document.
+var identityProof = registerProofType(&ProofType{
+	Name:        "identity",
+	Description: "holds an identity document signed by an issuer, valid until a public date",
+	Circuit:     func() frontend.Circuit { return &IdentityCircuit{} },
+	Message: func(documentDigest []byte, c Claims) ([]byte, error) {
+		claims, err := parseIdentityFields(c)
+		if err != nil {
+			return nil, err
+		}
+		return IdentityCommitment(documentDigest, claims), nil
+	},
+	Assign: assignIdentity,
+	Public: []PublicInput{
+		{Name: PublicDocumentCommitment, Kind: PublicField},
+		{Name: ClaimValidUntil, Kind: PublicDate},
+		{Name: ClaimIssuerPublicKey, Kind: PublicKey},
+	},
+})
+
+func assignIdentity(doc Document, c Claims) (frontend.Circuit, error) {
+	identity, err := ParseIdentityClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIssuerSignature(identity.IssuerPublicKey, identity.IssuerSignature, IdentityCommitment(doc.Digest, identity)); err != nil {
+		return nil, err
+	}
+
+	commitment, err := doc.commitment()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &IdentityCircuit{
+		DocumentHash:       digestToField(doc.Digest),
+		Salt:               doc.Salt,
+		DocumentCommitment: commitment,
+		HolderID:           identity.HolderID,
+		BirthDate:          identity.BirthDate,
+		ValidUntil:         identity.ValidUntil,
+	}
+	assignment.IssuerPublicKey.Assign(tedwards.BN254, identity.IssuerPublicKey)
+	assignment.IssuerSignature.Assign(tedwards.BN254, identity.IssuerSignature)
+	return assignment, nil
+}
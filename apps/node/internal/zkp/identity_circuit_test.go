@@ -0,0 +1,29 @@
+package zkp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdentityIssuedBefore1970(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "identity", doc, Claims{
+		ClaimHolderID:   "9876543",
+		ClaimBirthDate:  "1938-02-11",
+		ClaimValidUntil: "1968-02-11",
+	})
+	circuit, assignment := assign(t, "identity", doc, c)
+	assertSolved(t, circuit, assignment)
+}
+
+func TestIdentityBirthAfterExpiry(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "identity", doc, Claims{
+		ClaimHolderID:   "9876543",
+		ClaimBirthDate:  "1938-02-11",
+		ClaimValidUntil: "1968-02-11",
+	})
+	circuit, assignment := assign(t, "identity", doc, c)
+	assignment.(*IdentityCircuit).ValidUntil = FieldDate(time.Date(1930, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assertNotSolved(t, circuit, assignment)
+}
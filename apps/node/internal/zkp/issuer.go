@@ -0,0 +1,133 @@
+package zkp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	circuitmimc "github.com/consensys/gnark/std/hash/mimc"
+	circuiteddsa "github.com/consensys/gnark/std/signature/eddsa"
+)
+
+// Issuers (universities, government offices) sign credentials with EdDSA on
+// BabyJubJub, the twisted Edwards curve embedded in BN254, so the signature
+// can be checked inside the proof circuits. Keys and signatures travel as hex of their compressed form.
+const (
+	issuerPublicKeySize = 32
+	issuerSignatureSize = 64
+)
+
+// GenerateIssuerKey creates a new issuer signing key.
+func GenerateIssuerKey() (*eddsa.PrivateKey, error) {
+	key, err := eddsa.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to generate issuer key: %w", err)
+	}
+	return key, nil
+}
+
+// ParseIssuerPrivateKey decodes a hex-encoded issuer signing key.
+func ParseIssuerPrivateKey(s string) (*eddsa.PrivateKey, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("zkp: invalid issuer key: %w", err)
+	}
+	key := new(eddsa.PrivateKey)
+	if _, err := key.SetBytes(raw); err != nil {
+		return nil, fmt.Errorf("zkp: invalid issuer key: %w", err)
+	}
+	return key, nil
+}
+
+// ParseIssuerPublicKey decodes a hex-encoded issuer public key and checks that
+// it is a point on the curve.
+func ParseIssuerPublicKey(s string) (*eddsa.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != issuerPublicKeySize {
+		return nil, fmt.Errorf("zkp: issuer public key must be %d hex-encoded bytes", issuerPublicKeySize)
+	}
+	pub := new(eddsa.PublicKey)
+	if _, err := pub.SetBytes(raw); err != nil {
+		return nil, fmt.Errorf("zkp: invalid issuer public key: %w", err)
+	}
+	return pub, nil
+}
+
+// credentialCommitment is the message an issuer signs: the MiMC hash of the
+// credential values, in the order the proof type's circuit hashes them.
+func credentialCommitment(values ...*big.Int) []byte {
+	h := mimc.NewMiMC()
+	for _, v := range values {
+		var e fr.Element
+		e.SetBigInt(v)
+		b := e.Bytes()
+		h.Write(b[:])
+	}
+	return h.Sum(nil)
+}
+
+// DegreeCommitment is the message an issuer signs for a degree: the MiMC hash
+// of the document hash and the degree claims, exactly as DegreeCircuit
+// computes it.
+func DegreeCommitment(documentDigest []byte, claims *DegreeClaims) []byte {
+	return credentialCommitment(digestToField(documentDigest), claims.StudentID, claims.IssueDate, claims.ValidUntil, claims.InstitutionHash)
+}
+
+// SignCredential signs the credential commitment of proofType for the
+// document with SHA-256 digest documentDigest and claims. Only the credential
+// claims are read; issuer claims in c are ignored.
+func SignCredential(key *eddsa.PrivateKey, proofType string, documentDigest []byte, c Claims) (string, error) {
+	pt, err := LookupProofType(proofType)
+	if err != nil {
+		return "", err
+	}
+	msg, err := pt.Message(documentDigest, c)
+	if err != nil {
+		return "", err
+	}
+	sig, err := key.Sign(msg, mimc.NewMiMC())
+	if err != nil {
+		return "", fmt.Errorf("zkp: failed to sign credential: %w", err)
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// verifyIssuerSignature checks the issuer signature outside the circuit, so a
+// bad signature is reported as a claim error instead of a failed proof.
+func verifyIssuerSignature(publicKey, signature, msg []byte) error {
+	pub := new(eddsa.PublicKey)
+	if _, err := pub.SetBytes(publicKey); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidClaims, ClaimIssuerPublicKey, err)
+	}
+	ok, err := pub.Verify(signature, msg, mimc.NewMiMC())
+	if err != nil || !ok {
+		return fmt.Errorf("%w: %s does not match the credential", ErrInvalidClaims, ClaimIssuerSignature)
+	}
+	return nil
+}
+
+// assertIssuerSigned constrains signature to be the issuer's signature over
+// the MiMC commitment of values, mirroring credentialCommitment.
+func assertIssuerSigned(api frontend.API, signature circuiteddsa.Signature, publicKey circuiteddsa.PublicKey, values ...frontend.Variable) error {
+	curve, err := twistededwards.NewEdCurve(api, tedwards.BN254)
+	if err != nil {
+		return err
+	}
+	h, err := circuitmimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	h.Write(values...)
+	commitment := h.Sum()
+
+	h.Reset()
+	return circuiteddsa.Verify(curve, signature, commitment, publicKey, &h)
+}
@@ -0,0 +1,42 @@
+package zkp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBadIssuerSignature(t *testing.T) {
+	doc := testDocument()
+	for proofType, c := range commitmentClaims() {
+		pt, err := LookupProofType(proofType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		genuine := issue(t, proofType, doc, cloneClaims(c))
+		other := issue(t, proofType, doc, cloneClaims(c))
+
+		// A signature paired with another issuer's key is refused before
+		// proving...
+		forged := cloneClaims(genuine)
+		forged[ClaimIssuerPublicKey] = other[ClaimIssuerPublicKey]
+		if _, err := pt.Assign(doc, forged); !errors.Is(err, ErrInvalidClaims) {
+			t.Fatalf("%s: got %v, want ErrInvalidClaims", proofType, err)
+		}
+
+		// ...and cannot be proven either.
+		circuit, assignment := assign(t, proofType, doc, genuine)
+		_, otherAssignment := assign(t, proofType, doc, other)
+		key := reflect.ValueOf(otherAssignment).Elem().FieldByName("IssuerPublicKey")
+		reflect.ValueOf(assignment).Elem().FieldByName("IssuerPublicKey").Set(key)
+		assertNotSolved(t, circuit, assignment)
+	}
+}
+
+func cloneClaims(c Claims) Claims {
+	clone := Claims{}
+	for k, v := range c {
+		clone[k] = v
+	}
+	return clone
+}
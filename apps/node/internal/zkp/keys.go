@@ -0,0 +1,229 @@
+package zkp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// On-disk key files start with a fixed header so a stale or foreign file is
+// never mistaken for the keys of the current circuit:
+//
+//	magic (4 bytes) | format version (uint16, big endian) | circuit fingerprint (32 bytes)
+const (
+	keyFileMagic   = "LPZK"
+	keyFileVersion = uint16(1)
+
+	circuitFile      = "circuit.ccs"
+	provingKeyFile   = "proving.key"
+	verifyingKeyFile = "verifying.key"
+)
+
+// keySet is a compiled circuit together with the keys produced for it by
+// one backend.
+type keySet struct {
+	backend     Backend
+	fingerprint string
+	ccs         constraint.ConstraintSystem
+	pk          provingKey
+	vk          verifyingKey
+}
+
+// verifierKey is a verifying key and the backend it belongs to.
+type verifierKey struct {
+	backend Backend
+	vk      verifyingKey
+}
+
+// circuitFingerprint identifies a compiled circuit by the SHA-256 of its
+// serialized constraint system. Any change to the circuit definition changes it.
+func circuitFingerprint(ccs constraint.ConstraintSystem) (string, error) {
+	h := sha256.New()
+	if _, err := ccs.WriteTo(h); err != nil {
+		return "", fmt.Errorf("zkp: failed to hash constraint system: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyingKeyFingerprint identifies a verifying key by the SHA-256 of its
+// serialization. Nodes with the same circuit but separate trusted setups share
+// a circuit fingerprint but not this one.
+func verifyingKeyFingerprint(vk verifyingKey) (string, error) {
+	h := sha256.New()
+	if _, err := vk.WriteTo(h); err != nil {
+		return "", fmt.Errorf("zkp: failed to hash verifying key: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// keysDir is the directory holding every key set generated for a circuit by
+// backend. Groth16 keys predate the other backends and keep their location.
+func keysDir(dataDir, circuitName string, backend Backend) string {
+	dir := filepath.Join(dataDir, "zkp", circuitName)
+	if backend != BackendGroth16 {
+		dir = filepath.Join(dir, string(backend))
+	}
+	return dir
+}
+
+// loadKeySet reads the backend key set for fingerprint from dir. It returns
+// an error wrapping os.ErrNotExist when no keys have been written for that
+// circuit yet.
+func loadKeySet(dir, fingerprint string, backend Backend) (*keySet, error) {
+	setDir := filepath.Join(dir, fingerprint)
+	system := provingSystems[backend]
+
+	ccs := system.newCS()
+	if err := readKeyFile(filepath.Join(setDir, circuitFile), fingerprint, ccs); err != nil {
+		return nil, err
+	}
+	pk := system.newProvingKey()
+	if err := readKeyFile(filepath.Join(setDir, provingKeyFile), fingerprint, pk); err != nil {
+		return nil, err
+	}
+	vk := system.newVerifyingKey()
+	if err := readKeyFile(filepath.Join(setDir, verifyingKeyFile), fingerprint, vk); err != nil {
+		return nil, err
+	}
+
+	return &keySet{backend: backend, fingerprint: fingerprint, ccs: ccs, pk: pk, vk: vk}, nil
+}
+
+// saveKeySet writes ks under dir/<fingerprint>. The verifying key is written
+// last so a partially written set is never picked up as a verifier.
+func saveKeySet(dir string, ks *keySet) error {
+	setDir := filepath.Join(dir, ks.fingerprint)
+	if err := os.MkdirAll(setDir, 0700); err != nil {
+		return fmt.Errorf("zkp: failed to create key dir: %w", err)
+	}
+
+	if err := writeKeyFile(filepath.Join(setDir, circuitFile), ks.fingerprint, ks.ccs); err != nil {
+		return err
+	}
+	if err := writeKeyFile(filepath.Join(setDir, provingKeyFile), ks.fingerprint, ks.pk); err != nil {
+		return err
+	}
+	return writeKeyFile(filepath.Join(setDir, verifyingKeyFile), ks.fingerprint, ks.vk)
+}
+
+// loadVerifyingKeys adds the verifying key of every backend key set stored
+// in dir to vks, keyed by circuit fingerprint, so proofs made with older
+// circuits still verify.
+func loadVerifyingKeys(dir string, backend Backend, vks map[string]verifierKey) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("zkp: failed to list key sets: %w", err)
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || len(e.Name()) != 2*sha256.Size {
+			continue
+		}
+		vk := provingSystems[backend].newVerifyingKey()
+		if err := readKeyFile(filepath.Join(dir, e.Name(), verifyingKeyFile), e.Name(), vk); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		vks[e.Name()] = verifierKey{backend: backend, vk: vk}
+	}
+	return nil
+}
+
+// writeKeyFile atomically writes the header followed by obj to path.
+func writeKeyFile(path, fingerprint string, obj io.WriterTo) error {
+	header, err := keyFileHeader(fingerprint)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		_, err := obj.WriteTo(w)
+		return err
+	})
+}
+
+// writeFileAtomic writes path through a synced temporary file in the same
+// directory so readers never observe a half-written file.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("zkp: failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return fmt.Errorf("zkp: failed to write %s: %w", path, err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("zkp: failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("zkp: failed to sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("zkp: failed to close %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("zkp: failed to move %s into place: %w", path, err)
+	}
+	return nil
+}
+
+// readKeyFile checks the header of path against fingerprint and decodes the
+// remainder into obj.
+func readKeyFile(path, fingerprint string, obj io.ReaderFrom) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("zkp: failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	want, err := keyFileHeader(fingerprint)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(r, got); err != nil {
+		return fmt.Errorf("zkp: failed to read header of %s: %w", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("zkp: %s does not belong to circuit %s", path, fingerprint)
+	}
+
+	if _, err := obj.ReadFrom(r); err != nil {
+		return fmt.Errorf("zkp: failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func keyFileHeader(fingerprint string) ([]byte, error) {
+	fp, err := hex.DecodeString(fingerprint)
+	if err != nil || len(fp) != sha256.Size {
+		return nil, fmt.Errorf("zkp: invalid circuit fingerprint %q", fingerprint)
+	}
+	header := make([]byte, 0, len(keyFileMagic)+2+sha256.Size)
+	header = append(header, keyFileMagic...)
+	header = binary.BigEndian.AppendUint16(header, keyFileVersion)
+	return append(header, fp...), nil
+}
@@ -0,0 +1,176 @@
+package zkp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// A bundle's text form is carried by QR codes. When it is too long for one
+// scannable code it is split into segments, each its own QR code:
+//
+//	LPQ:<part>/<total>:<set>:<chunk>
+//
+// where <set> ties the segments of one bundle together. Every character is
+// in the QR alphanumeric set, so the codes stay dense. A bundle that fits is
+// encoded as its plain LP1: text.
+const qrSegmentPrefix = "LPQ:"
+
+// DefaultQRSegmentSize is the longest text put in one QR code by default; it
+// keeps codes around version 15, which cheap phone cameras still read.
+const DefaultQRSegmentSize = 500
+
+// minQRSegmentSize leaves room for the segment header.
+const minQRSegmentSize = 100
+
+// maxQRSegments bounds a reassembled bundle.
+const maxQRSegments = 64
+
+// QRSegments splits a bundle's text form into QR code payloads of at most
+// size characters.
+func QRSegments(text string, size int) ([]string, error) {
+	if size < minQRSegmentSize {
+		return nil, fmt.Errorf("zkp: QR segment size must be at least %d", minQRSegmentSize)
+	}
+	if len(text) <= size {
+		return []string{text}, nil
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	set := strings.ToUpper(hex.EncodeToString(sum[:4]))
+
+	// Size the chunks for the longest header, so every segment fits.
+	header := len(fmt.Sprintf("%s%d/%d:%s:", qrSegmentPrefix, maxQRSegments, maxQRSegments, set))
+	chunk := size - header
+	total := (len(text) + chunk - 1) / chunk
+	if total > maxQRSegments {
+		return nil, fmt.Errorf("zkp: bundle needs %d QR codes, more than %d", total, maxQRSegments)
+	}
+
+	segments := make([]string, total)
+	for i := range segments {
+		end := min((i+1)*chunk, len(text))
+		segments[i] = fmt.Sprintf("%s%d/%d:%s:%s", qrSegmentPrefix, i+1, total, set, text[i*chunk:end])
+	}
+	return segments, nil
+}
+
+// JoinQRSegments reassembles a bundle's text form from scanned QR payloads,
+// in any order. A single plain LP1: payload is returned as is.
+func JoinQRSegments(segments []string) (string, error) {
+	if len(segments) == 1 && !strings.HasPrefix(strings.TrimSpace(segments[0]), qrSegmentPrefix) {
+		return strings.TrimSpace(segments[0]), nil
+	}
+	if len(segments) == 0 || len(segments) > maxQRSegments {
+		return "", fmt.Errorf("zkp: expected between 1 and %d QR segments, got %d", maxQRSegments, len(segments))
+	}
+
+	var (
+		set    string
+		chunks []string
+	)
+	for _, seg := range segments {
+		seg = strings.TrimSpace(seg)
+		if !strings.HasPrefix(seg, qrSegmentPrefix) {
+			return "", fmt.Errorf("zkp: not a proof bundle QR segment")
+		}
+		fields := strings.SplitN(strings.TrimPrefix(seg, qrSegmentPrefix), ":", 3)
+		if len(fields) != 3 {
+			return "", fmt.Errorf("zkp: not a proof bundle QR segment")
+		}
+		part, total, ok := parseQRPart(fields[0])
+		if !ok {
+			return "", fmt.Errorf("zkp: invalid QR segment number %q", fields[0])
+		}
+
+		if chunks == nil {
+			set, chunks = fields[1], make([]string, total)
+		}
+		if fields[1] != set || total != len(chunks) {
+			return "", fmt.Errorf("zkp: QR segments belong to different bundles")
+		}
+		if chunks[part-1] != "" && chunks[part-1] != fields[2] {
+			return "", fmt.Errorf("zkp: conflicting copies of QR segment %d", part)
+		}
+		chunks[part-1] = fields[2]
+	}
+
+	var missing []string
+	for i, chunk := range chunks {
+		if chunk == "" {
+			missing = append(missing, strconv.Itoa(i+1))
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("zkp: missing QR segment(s) %s of %d", strings.Join(missing, ", "), len(chunks))
+	}
+
+	text := strings.Join(chunks, "")
+	sum := sha256.Sum256([]byte(text))
+	if strings.ToUpper(hex.EncodeToString(sum[:4])) != set {
+		return "", fmt.Errorf("zkp: reassembled bundle does not match its QR segments")
+	}
+	return text, nil
+}
+
+// parseQRPart parses "<part>/<total>".
+func parseQRPart(s string) (part, total int, ok bool) {
+	p, t, found := strings.Cut(s, "/")
+	if !found {
+		return 0, 0, false
+	}
+	part, err1 := strconv.Atoi(p)
+	total, err2 := strconv.Atoi(t)
+	if err1 != nil || err2 != nil || total < 1 || total > maxQRSegments || part < 1 || part > total {
+		return 0, 0, false
+	}
+	return part, total, true
+}
+
+// QRPNG renders payload as a PNG QR code of size×size pixels.
+func QRPNG(payload string, size int) ([]byte, error) {
+	code, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to encode QR code: %w", err)
+	}
+	png, err := code.PNG(size)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to render QR code: %w", err)
+	}
+	return png, nil
+}
+
+// QRSVG renders payload as an SVG QR code, one unit per module, that scales
+// to size×size pixels.
+func QRSVG(payload string, size int) ([]byte, error) {
+	code, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to encode QR code: %w", err)
+	}
+	bitmap := code.Bitmap()
+	n := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x := 0; x < n; x++ {
+			if !row[x] {
+				continue
+			}
+			// Merge each horizontal run of dark modules into one rectangle.
+			start := x
+			for x < n && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+package zkp
+
+import (
+	"strings"
+	"testing"
+)
+
+// longBundleText is the text form of a bundle needing several QR codes.
+func longBundleText(t *testing.T) string {
+	t.Helper()
+	b := testBundle()
+	b.Proof = make([]byte, 900)
+	for i := range b.Proof {
+		b.Proof[i] = byte(i * 7919)
+	}
+	text, err := b.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text
+}
+
+func TestQRSegmentsRoundTrip(t *testing.T) {
+	text := longBundleText(t)
+	segments, err := QRSegments(text, minQRSegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 3 {
+		t.Fatalf("%d-character bundle split into %d segment(s)", len(text), len(segments))
+	}
+	for i, seg := range segments {
+		if len(seg) > minQRSegmentSize {
+			t.Fatalf("segment %d is %d characters, over %d", i+1, len(seg), minQRSegmentSize)
+		}
+	}
+	// Chunks are equal but for the last, which carries the remainder.
+	chunk := func(seg string) string { return strings.SplitN(seg, ":", 4)[3] }
+	full, last := len(chunk(segments[0])), len(chunk(segments[len(segments)-1]))
+	if last == 0 || last >= full || (len(segments)-1)*full+last != len(text) {
+		t.Fatalf("%d characters split into chunks of %d with a last one of %d", len(text), full, last)
+	}
+
+	// Scanned in any order, with repeats and stray whitespace.
+	scanned := []string{" " + segments[len(segments)-1] + "\n"}
+	for i := len(segments) - 2; i >= 0; i-- {
+		scanned = append(scanned, segments[i])
+	}
+	scanned = append(scanned, segments[1])
+	got, err := JoinQRSegments(scanned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != text {
+		t.Fatal("reassembled bundle differs")
+	}
+	if _, err := ParseBundle([]byte(got)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQRSegmentsShortBundle(t *testing.T) {
+	text, err := testBundle().Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err := QRSegments(text, DefaultQRSegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || segments[0] != text {
+		t.Fatalf("short bundle split into %q", segments)
+	}
+	if got, err := JoinQRSegments(segments); err != nil || got != text {
+		t.Fatalf("join: %v", err)
+	}
+	if _, err := QRSegments(text, minQRSegmentSize-1); err == nil {
+		t.Fatal("accepted a segment size with no room for the header")
+	}
+}
+
+func TestJoinQRSegmentsInvalid(t *testing.T) {
+	segments, err := QRSegments(longBundleText(t), minQRSegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := QRSegments(longBundleText(t)+"0", minQRSegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]string{}, segments...)
+	tampered[0] = tampered[0][:len(tampered[0])-1] + "0"
+	if tampered[0] == segments[0] {
+		tampered[0] = tampered[0][:len(tampered[0])-1] + "1"
+	}
+
+	for name, tc := range map[string]struct {
+		segments []string
+		want     string
+	}{
+		"missing":     {segments[1:], "missing QR segment(s) 1 of"},
+		"mixed":       {append([]string{other[0]}, segments[1:]...), "different bundles"},
+		"conflicting": {append(append([]string{}, segments...), tampered[0]), "conflicting copies"},
+		"tampered":    {tampered, "does not match"},
+		"bad number":  {[]string{strings.Replace(segments[0], "1/", "0/", 1)}, "invalid QR segment number"},
+		"foreign":     {[]string{segments[0], "https://example.org"}, "not a proof bundle"},
+		"none":        {nil, "expected between"},
+	} {
+		_, err := JoinQRSegments(tc.segments)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: got %v, want %q", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package zkp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	edwards "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ErrUnknownProofType is returned for a proof type that is not registered.
+var ErrUnknownProofType = errors.New("zkp: unknown proof type")
+
+// ProofType describes one kind of proof: its circuit, how to build a witness
+// from a document and its claims, and the layout of its public inputs. Each
+// proof type has its own keys under DataDir/zkp/<Name>.
+type ProofType struct {
+	Name        string
+	Description string
+	// Circuit returns an empty circuit for compilation.
+	Circuit func() frontend.Circuit
+	// Message returns the credential commitment an issuer signs for
+	// the document with SHA-256 digest documentDigest and claims (see
+	// SignCredential).
+	Message func(documentDigest []byte, claims Claims) ([]byte, error)
+	// Assign builds the full witness for doc and claims. Claim errors wrap
+	// ErrInvalidClaims.
+	Assign func(doc Document, claims Claims) (frontend.Circuit, error)
+	// Public lists the public inputs in circuit field order.
+	Public []PublicInput
+	// CheckPublic, if set, checks relations between the public inputs that
+	// the circuit cannot express. It gets the public witness in field order.
+	CheckPublic func(public fr.Vector) error
+	// SignatureClaim names the claim that holds the issuer's signature over
+	// Message; empty means ClaimIssuerSignature.
+	SignatureClaim string
+}
+
+// SignatureClaimName returns the claim that holds the issuer's signature for
+// this proof type.
+func (pt *ProofType) SignatureClaimName() string {
+	if pt.SignatureClaim != "" {
+		return pt.SignatureClaim
+	}
+	return ClaimIssuerSignature
+}
+
+// PublicInputKind says how a public input is laid out in the witness and how
+// it is presented to verifiers.
+type PublicInputKind string
+
+const (
+	// PublicField is a single field element, shown in decimal.
+	PublicField PublicInputKind = "field"
+	// PublicDate is a single field element holding seconds since DateEpoch.
+	PublicDate PublicInputKind = "date"
+	// PublicKey is an issuer public key: two field elements (X, Y), shown as
+	// the hex of the compressed point.
+	PublicKey PublicInputKind = "public_key"
+	// PublicText is a short string of at most 31 bytes packed big-endian into
+	// one field element (see textField); zero is the empty string.
+	PublicText PublicInputKind = "text"
+)
+
+// PublicDocumentCommitment names the document commitment every proof type
+// publishes as its first public input (see CommitDocumentDigest).
+const PublicDocumentCommitment = "document_commitment"
+
+// PublicInput names one public input of a proof type.
+type PublicInput struct {
+	Name string          `json:"name"`
+	Kind PublicInputKind `json:"kind"`
+}
+
+// width is the number of field elements the input occupies in the witness.
+func (in PublicInput) width() int {
+	if in.Kind == PublicKey {
+		return 2
+	}
+	return 1
+}
+
+// proofTypes holds every proof type the service supports, by name.
+var proofTypes = map[string]*ProofType{}
+
+func registerProofType(pt *ProofType) *ProofType {
+	if _, dup := proofTypes[pt.Name]; dup {
+		panic("zkp: proof type registered twice: " + pt.Name)
+	}
+	proofTypes[pt.Name] = pt
+	return pt
+}
+
+// LookupProofType returns the registered proof type called name.
+func LookupProofType(name string) (*ProofType, error) {
+	pt, ok := proofTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProofType, name)
+	}
+	return pt, nil
+}
+
+// ProofTypes returns every registered proof type, sorted by name.
+func ProofTypes() []*ProofType {
+	types := make([]*ProofType, 0, len(proofTypes))
+	for _, pt := range proofTypes {
+		types = append(types, pt)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// decodePublicWitness reads a serialized public witness of proofType and
+// checks it against the proof type's schema.
+func decodePublicWitness(proofType string, pubWitnessData []byte) (*ProofType, fr.Vector, error) {
+	pt, err := LookupProofType(proofType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, nil, fmt.Errorf("zkp: failed to create public witness target: %w", err)
+	}
+	if err := w.UnmarshalBinary(pubWitnessData); err != nil {
+		return nil, nil, fmt.Errorf("zkp: failed to unmarshal public witness: %w", err)
+	}
+	values, ok := w.Vector().(fr.Vector)
+	if !ok {
+		return nil, nil, fmt.Errorf("zkp: public witness is not over BN254")
+	}
+
+	width := 0
+	for _, in := range pt.Public {
+		width += in.width()
+	}
+	if len(values) != width {
+		return nil, nil, fmt.Errorf("zkp: public witness has %d values, a %s proof has %d", len(values), pt.Name, width)
+	}
+	return pt, values, nil
+}
+
+// DecodePublicInputs reads the public inputs of a serialized public witness
+// according to the schema of proofType, keyed by input name.
+func DecodePublicInputs(proofType string, pubWitnessData []byte) (map[string]string, error) {
+	pt, values, err := decodePublicWitness(proofType, pubWitnessData)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make(map[string]string, len(pt.Public))
+	for _, in := range pt.Public {
+		switch in.Kind {
+		case PublicKey:
+			var p edwards.PointAffine
+			p.X.Set(&values[0])
+			p.Y.Set(&values[1])
+			pub := eddsa.PublicKey{A: p}
+			inputs[in.Name] = hex.EncodeToString(pub.Bytes())
+		case PublicText:
+			b := values[0].Bytes()
+			inputs[in.Name] = string(bytes.TrimLeft(b[:], "\x00"))
+		case PublicDate:
+			secs := values[0].BigInt(new(big.Int))
+			if t, err := DateFromField(secs); err == nil {
+				inputs[in.Name] = t.Format(time.RFC3339)
+			} else {
+				inputs[in.Name] = secs.String()
+			}
+		default:
+			inputs[in.Name] = values[0].BigInt(new(big.Int)).String()
+		}
+		values = values[in.width():]
+	}
+	return inputs, nil
+}
+
+// DecodePublicDate returns the date public input called name of a serialized
+// public witness, such as ClaimValidUntil.
+func DecodePublicDate(proofType string, pubWitnessData []byte, name string) (time.Time, error) {
+	pt, values, err := decodePublicWitness(proofType, pubWitnessData)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, in := range pt.Public {
+		if in.Name == name && in.Kind == PublicDate {
+			t, err := DateFromField(values[0].BigInt(new(big.Int)))
+			if err != nil {
+				return time.Time{}, fmt.Errorf("zkp: %s is out of range", name)
+			}
+			return t, nil
+		}
+		values = values[in.width():]
+	}
+	return time.Time{}, fmt.Errorf("zkp: a %s proof has no %s date", pt.Name, name)
+}
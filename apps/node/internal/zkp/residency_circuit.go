@@ -0,0 +1,98 @@
+package zkp
+
+import (
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/signature/eddsa"
+)
+
+// ResidencyCircuit proves the holder of an issuer-signed residence
+// certificate lives in a public region, without revealing who they are.
+type ResidencyCircuit struct {
+	// Private inputs (witness)
+	DocumentHash frontend.Variable `gnark:",private"`
+	HolderID     frontend.Variable `gnark:",private"`
+
+	// Salt blinds DocumentCommitment.
+	Salt frontend.Variable `gnark:",private"`
+
+	// IssuerSignature is the issuing office's EdDSA signature over the
+	// residency commitment (see ResidencyCommitment).
+	IssuerSignature eddsa.Signature `gnark:",private"`
+
+	// Public inputs
+	DocumentCommitment frontend.Variable `gnark:",public"`
+	RegionHash         frontend.Variable `gnark:",public"`
+	ValidUntil         frontend.Variable `gnark:",public"`
+	IssuerPublicKey    eddsa.PublicKey   `gnark:",public"`
+}
+
+// Define defines the circuit constraints
+func (c *ResidencyCircuit) Define(api frontend.API) error {
+	// Verify the certificate and its holder exist
+	api.AssertIsDifferent(c.DocumentHash, 0)
+	api.AssertIsDifferent(c.HolderID, 0)
+
+	// Verify the proof is bound to the committed vault document
+	if err := assertCommitment(api, c.DocumentHash, c.Salt, c.DocumentCommitment); err != nil {
+		return err
+	}
+
+	// Verify the issuer certified this holder in this region
+	return assertIssuerSigned(api, c.IssuerSignature, c.IssuerPublicKey,
+		c.DocumentHash, c.HolderID, c.RegionHash, c.ValidUntil)
+}
+
+// ResidencyCommitment is the message an issuer signs for a residence
+// certificate, exactly as ResidencyCircuit computes it.
+func ResidencyCommitment(documentDigest []byte, claims *ResidencyClaims) []byte {
+	return credentialCommitment(digestToField(documentDigest), claims.HolderID, claims.RegionHash, claims.ValidUntil)
+}
+
+// residencyProof proves the holder is resident in a region.
+var residencyProof = registerProofType(&ProofType{
+	Name:        "residency",
+	Description: "resides in a public region, certified by an issuer until a public date",
+	Circuit:     func() frontend.Circuit { return &ResidencyCircuit{} },
+	Message: func(documentDigest []byte, c Claims) ([]byte, error) {
+		claims, err := parseResidencyFields(c)
+		if err != nil {
+			return nil, err
+		}
+		return ResidencyCommitment(documentDigest, claims), nil
+	},
+	Assign: assignResidency,
+	Public: []PublicInput{
+		{Name: PublicDocumentCommitment, Kind: PublicField},
+		{Name: "region_hash", Kind: PublicField},
+		{Name: ClaimValidUntil, Kind: PublicDate},
+		{Name: ClaimIssuerPublicKey, Kind: PublicKey},
+	},
+})
+
+func assignResidency(doc Document, c Claims) (frontend.Circuit, error) {
+	residency, err := ParseResidencyClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIssuerSignature(residency.IssuerPublicKey, residency.IssuerSignature, ResidencyCommitment(doc.Digest, residency)); err != nil {
+		return nil, err
+	}
+
+	commitment, err := doc.commitment()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &ResidencyCircuit{
+		DocumentHash:       digestToField(doc.Digest),
+		Salt:               doc.Salt,
+		DocumentCommitment: commitment,
+		HolderID:           residency.HolderID,
+		RegionHash:         residency.RegionHash,
+		ValidUntil:         residency.ValidUntil,
+	}
+	assignment.IssuerPublicKey.Assign(tedwards.BN254, residency.IssuerPublicKey)
+	assignment.IssuerSignature.Assign(tedwards.BN254, residency.IssuerSignature)
+	return assignment, nil
+}
@@ -0,0 +1,26 @@
+package zkp
+
+import "testing"
+
+func TestResidencyExpiringBefore1970(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "residency", doc, Claims{
+		ClaimHolderID:   "9876543",
+		ClaimRegion:     "Imphal West",
+		ClaimValidUntil: "1965-03-31",
+	})
+	circuit, assignment := assign(t, "residency", doc, c)
+	assertSolved(t, circuit, assignment)
+}
+
+func TestResidencyOtherRegion(t *testing.T) {
+	doc := testDocument()
+	c := issue(t, "residency", doc, Claims{
+		ClaimHolderID:   "9876543",
+		ClaimRegion:     "Imphal West",
+		ClaimValidUntil: "1965-03-31",
+	})
+	circuit, assignment := assign(t, "residency", doc, c)
+	assignment.(*ResidencyCircuit).RegionHash = RegionHash("Imphal East")
+	assertNotSolved(t, circuit, assignment)
+}
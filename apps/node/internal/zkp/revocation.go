@@ -0,0 +1,129 @@
+package zkp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+)
+
+// A Revocation withdraws proofs an issuer vouched for: one proof, by its hash,
+// or every proof over a document, by the document commitment the holder
+// showed. It is signed with the issuer key the revoked proofs publish, so an
+// issuer can only revoke its own credentials.
+type Revocation struct {
+	IssuerPublicKey string `json:"issuer_public_key"`
+	// Exactly one of ProofHash and DocumentCommitment is set.
+	ProofHash          string `json:"proof_hash,omitempty"`
+	DocumentCommitment string `json:"document_commitment,omitempty"`
+	Reason             string `json:"reason"`
+	// RevokedAt is in unix seconds.
+	RevokedAt int64  `json:"revoked_at"`
+	Signature string `json:"signature"`
+}
+
+// maxRevocationReason bounds the reason carried over the mesh.
+const maxRevocationReason = 512
+
+// revocationDomain separates revocation signatures from credential
+// signatures made with the same key.
+const revocationDomain = "lairik-revocation/1"
+
+// ID identifies the revocation by its signed content.
+func (r *Revocation) ID() string {
+	sum := sha256.Sum256(r.canonical())
+	return hex.EncodeToString(sum[:])
+}
+
+// canonical is the unambiguous encoding of every field but the signature.
+func (r *Revocation) canonical() []byte {
+	return []byte(strings.Join([]string{
+		revocationDomain,
+		r.IssuerPublicKey,
+		r.ProofHash,
+		r.DocumentCommitment,
+		strconv.Quote(r.Reason),
+		strconv.FormatInt(r.RevokedAt, 10),
+	}, "\n"))
+}
+
+// message is what the issuer signs: the canonical encoding as one field
+// element, so the signature is made the same way as credential signatures.
+func (r *Revocation) message() []byte {
+	return credentialCommitment(hashToField(r.canonical()))
+}
+
+// SignRevocation fills in the issuer key and signs r with key.
+func SignRevocation(key *eddsa.PrivateKey, r *Revocation) error {
+	r.IssuerPublicKey = hex.EncodeToString(key.Public().Bytes())
+	if r.RevokedAt == 0 {
+		r.RevokedAt = time.Now().Unix()
+	}
+	if err := r.validate(); err != nil {
+		return err
+	}
+	sig, err := key.Sign(r.message(), mimc.NewMiMC())
+	if err != nil {
+		return fmt.Errorf("zkp: failed to sign revocation: %w", err)
+	}
+	r.Signature = hex.EncodeToString(sig)
+	return nil
+}
+
+// Verify checks that r is well formed and signed by its issuer.
+func (r *Revocation) Verify() error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	pub, err := ParseIssuerPublicKey(r.IssuerPublicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(r.Signature)
+	if err != nil || len(sig) != issuerSignatureSize {
+		return fmt.Errorf("zkp: revocation signature must be %d hex-encoded bytes", issuerSignatureSize)
+	}
+	ok, err := pub.Verify(sig, r.message(), mimc.NewMiMC())
+	if err != nil || !ok {
+		return fmt.Errorf("zkp: revocation is not signed by %s", shortFingerprint(r.IssuerPublicKey))
+	}
+	return nil
+}
+
+func (r *Revocation) validate() error {
+	switch {
+	case (r.ProofHash == "") == (r.DocumentCommitment == ""):
+		return fmt.Errorf("zkp: a revocation names either a proof hash or a document commitment")
+	case r.ProofHash != "" && !isSHA256Hex(r.ProofHash):
+		return fmt.Errorf("zkp: revoked proof hash must be 64 lowercase hex characters")
+	case r.DocumentCommitment != "" && !isFieldElement(r.DocumentCommitment):
+		return fmt.Errorf("zkp: revoked document commitment must be a decimal field element")
+	case r.Reason == "" || len(r.Reason) > maxRevocationReason:
+		return fmt.Errorf("zkp: revocation reason must be 1 to %d bytes", maxRevocationReason)
+	case r.RevokedAt <= 0:
+		return fmt.Errorf("zkp: revocation time is missing")
+	}
+	return nil
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// isFieldElement reports whether s is the canonical decimal form of a BN254
+// scalar, as DecodePublicInputs renders public fields.
+func isFieldElement(s string) bool {
+	n, ok := new(big.Int).SetString(s, 10)
+	return ok && n.Sign() >= 0 && n.Cmp(ecc.BN254.ScalarField()) < 0 && n.String() == s
+}
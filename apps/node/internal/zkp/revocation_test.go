@@ -0,0 +1,68 @@
+package zkp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func testRevocation(t *testing.T) *Revocation {
+	t.Helper()
+	key, err := GenerateIssuerKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := sha256.Sum256([]byte("revoked proof"))
+	r := &Revocation{ProofHash: hex.EncodeToString(proof[:]), Reason: "credential withdrawn"}
+	if err := SignRevocation(key, r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRevocationSignVerify(t *testing.T) {
+	r := testRevocation(t)
+	if err := r.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if r.RevokedAt == 0 {
+		t.Fatal("revocation time not filled in")
+	}
+
+	// Every signed field is covered by the signature, and by the ID.
+	for name, alter := range map[string]func(*Revocation){
+		"reason":     func(r *Revocation) { r.Reason = "typo" },
+		"revoked_at": func(r *Revocation) { r.RevokedAt++ },
+		"proof_hash": func(r *Revocation) { r.ProofHash = r.ProofHash[:63] + "0" },
+		"issuer":     func(r *Revocation) { r.IssuerPublicKey = testRevocation(t).IssuerPublicKey },
+	} {
+		altered := *r
+		alter(&altered)
+		if altered.ID() == r.ID() {
+			t.Fatalf("%s: altered revocation keeps its ID", name)
+		}
+		if err := altered.Verify(); err == nil {
+			t.Fatalf("%s: altered revocation verifies", name)
+		}
+	}
+}
+
+func TestRevocationInvalid(t *testing.T) {
+	key, err := GenerateIssuerKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := testRevocation(t).ProofHash
+	for name, r := range map[string]Revocation{
+		"neither":         {Reason: "withdrawn"},
+		"both":            {ProofHash: hash, DocumentCommitment: "42", Reason: "withdrawn"},
+		"uppercase hash":  {ProofHash: "AB" + hash[2:], Reason: "withdrawn"},
+		"bad commitment":  {DocumentCommitment: "042", Reason: "withdrawn"},
+		"no reason":       {ProofHash: hash},
+		"overlong reason": {ProofHash: hash, Reason: string(make([]byte, maxRevocationReason+1))},
+	} {
+		if err := SignRevocation(key, &r); err == nil {
+			t.Fatalf("%s: signed an invalid revocation", name)
+		}
+	}
+}
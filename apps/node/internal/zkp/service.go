@@ -1,18 +1,18 @@
 package zkp
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"math/big"
+	"os"
 	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,14 +20,28 @@ import (
 type Config struct {
 	DataDir string
 	Logger  *logrus.Logger
+	// Backends selects the backend of proof types by name; the others are
+	// proven with Groth16.
+	Backends map[string]Backend
+	// PlonkSRS is the SRS file PLONK keys are derived from, by default
+	// zkp/srs/bn254.srs under DataDir.
+	PlonkSRS string
 }
 
-// Service holds the pre-compiled circuit and keys so they are not rebuilt per-request.
+// Service holds the pre-compiled circuits and keys of every proof type so
+// they are not rebuilt per-request.
 type Service struct {
-	config Config
-	ccs    constraint.ConstraintSystem
-	pk     groth16.ProvingKey
-	vk     groth16.VerifyingKey
+	config   Config
+	circuits map[string]*circuitKeys
+}
+
+// circuitKeys are the keys of one proof type.
+type circuitKeys struct {
+	current *keySet
+	// verifyingKeys holds every known key set of either backend by circuit
+	// fingerprint, including the current one, so older proofs remain
+	// verifiable after a circuit or backend change.
+	verifyingKeys map[string]verifierKey
 }
 
 // DegreeCircuit is now defined in degree_circuit.go
@@ -35,58 +49,206 @@ type Service struct {
 // ProofResult holds the output of a successful proof generation.
 type ProofResult struct {
 	Hash               string
+	Backend            Backend
+	Fingerprint        string
 	ProofBytes         []byte
 	PublicWitnessBytes []byte
 	VerificationTime   int64
 	SizeBytes          int
 }
 
-// NewService compiles the circuit and runs the trusted setup once at startup.
+// NewService compiles the circuit of every proof type and loads its keys from
+// DataDir. The trusted setup only runs when no keys exist yet for the current
+// circuit definition.
 func NewService(cfg Config) (*Service, error) {
-	cfg.Logger.Info("ZKP: compiling circuit (one-time setup)...")
+	for name := range cfg.Backends {
+		if _, err := LookupProofType(name); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.PlonkSRS == "" {
+		cfg.PlonkSRS = srsFile(cfg.DataDir)
+	}
+
+	s := &Service{
+		config:   cfg,
+		circuits: make(map[string]*circuitKeys, len(proofTypes)),
+	}
+	srs := &srsStore{path: cfg.PlonkSRS, logger: cfg.Logger}
+	for _, pt := range ProofTypes() {
+		backend := cfg.Backends[pt.Name]
+		if backend == "" {
+			backend = BackendGroth16
+		}
+		keys, err := loadCircuitKeys(cfg, pt.Name, backend, srs)
+		if err != nil {
+			return nil, err
+		}
+		s.circuits[pt.Name] = keys
+	}
+	return s, nil
+}
+
+// loadCircuitKeys compiles the named circuit for backend and loads or creates
+// its keys.
+func loadCircuitKeys(cfg Config, name string, backend Backend, srs *srsStore) (*circuitKeys, error) {
+	system, ok := provingSystems[backend]
+	if !ok {
+		return nil, fmt.Errorf("zkp: unknown backend %q for %s", backend, name)
+	}
+
+	cfg.Logger.Infof("ZKP: compiling %s circuit for %s...", name, backend)
 	start := time.Now()
 
-	var circuit DegreeCircuit
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, err := compileCircuit(name, system)
 	if err != nil {
-		return nil, fmt.Errorf("zkp: failed to compile circuit: %w", err)
+		return nil, err
 	}
 
-	pk, vk, err := groth16.Setup(ccs)
+	fingerprint, err := circuitFingerprint(ccs)
 	if err != nil {
-		return nil, fmt.Errorf("zkp: failed to setup keys: %w", err)
+		return nil, err
 	}
 
-	cfg.Logger.Infof("ZKP: circuit ready in %s", time.Since(start))
+	dir := keysDir(cfg.DataDir, name, backend)
+	current, err := loadKeySet(dir, fingerprint, backend)
+	switch {
+	case err == nil:
+		cfg.Logger.Infof("ZKP: loaded %s keys for %s circuit %s", backend, name, fingerprint[:16])
+	case errors.Is(err, os.ErrNotExist):
+		if backend == BackendGroth16 {
+			cfg.Logger.Infof("ZKP: no keys for %s circuit %s, running trusted setup (one-time)...", name, fingerprint[:16])
+		} else {
+			cfg.Logger.Infof("ZKP: no %s keys for %s circuit %s, deriving them from the SRS (one-time)...", backend, name, fingerprint[:16])
+		}
+		pk, vk, err := system.setup(ccs, srs)
+		if err != nil {
+			return nil, fmt.Errorf("zkp: failed to setup keys: %w", err)
+		}
+		current = &keySet{backend: backend, fingerprint: fingerprint, ccs: ccs, pk: pk, vk: vk}
+		if err := saveKeySet(dir, current); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
 
-	return &Service{
-		config: cfg,
-		ccs:    ccs,
-		pk:     pk,
-		vk:     vk,
-	}, nil
+	vks := make(map[string]verifierKey)
+	for b := range provingSystems {
+		if err := loadVerifyingKeys(keysDir(cfg.DataDir, name, b), b, vks); err != nil {
+			return nil, err
+		}
+	}
+	vks[fingerprint] = verifierKey{backend: backend, vk: current.vk}
+
+	cfg.Logger.Infof("ZKP: %s circuit ready in %s (%d key set(s) available)", name, time.Since(start), len(vks))
+
+	return &circuitKeys{current: current, verifyingKeys: vks}, nil
 }
 
-// GenerateProof creates a Groth16 proof for the given document bytes.
-func (s *Service) GenerateProof(documentData []byte, proofType string) (*ProofResult, error) {
-	start := time.Now()
+// compileCircuit compiles the circuit of the named proof type over the BN254
+// scalar field into the constraint system of system.
+func compileCircuit(name string, system provingSystem) (constraint.ConstraintSystem, error) {
+	pt, err := LookupProofType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ccs, err := system.compile(pt.Circuit())
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to compile %s circuit: %w", name, err)
+	}
+	return ccs, nil
+}
+
+// keys returns the keys of the named proof type.
+func (s *Service) keys(proofType string) (*circuitKeys, error) {
+	keys, ok := s.circuits[proofType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProofType, proofType)
+	}
+	return keys, nil
+}
+
+// Fingerprint returns the fingerprint of the circuit new proofs of proofType
+// are made with.
+func (s *Service) Fingerprint(proofType string) (string, error) {
+	keys, err := s.keys(proofType)
+	if err != nil {
+		return "", err
+	}
+	return keys.current.fingerprint, nil
+}
+
+// Backend returns the backend new proofs of proofType are made with.
+func (s *Service) Backend(proofType string) (Backend, error) {
+	keys, err := s.keys(proofType)
+	if err != nil {
+		return "", err
+	}
+	return keys.current.backend, nil
+}
+
+// verifierKey returns the verifying key of the proofType circuit identified
+// by fingerprint, or of the current circuit if empty, and checks that it
+// belongs to backend. An empty backend is Groth16.
+func (s *Service) verifierKey(proofType, backend, fingerprint string) (verifierKey, error) {
+	keys, err := s.keys(proofType)
+	if err != nil {
+		return verifierKey{}, err
+	}
+	b, err := ParseBackend(backend)
+	if err != nil {
+		return verifierKey{}, err
+	}
+	if fingerprint == "" {
+		fingerprint = keys.current.fingerprint
+	}
+	vk, ok := keys.verifyingKeys[fingerprint]
+	if !ok {
+		return verifierKey{}, fmt.Errorf("zkp: no verifying key for %s circuit %s", proofType, fingerprint)
+	}
+	if vk.backend != b {
+		return verifierKey{}, fmt.Errorf("zkp: %s circuit %s has %s keys, proof was made with %s", proofType, shortFingerprint(fingerprint), vk.backend, b)
+	}
+	return vk, nil
+}
 
-	hash := sha256.Sum256(documentData)
-	hashInt := new(big.Int).SetBytes(hash[:])
+// VerifyingKeyFingerprint returns the fingerprint of the verifying key for the
+// proofType circuit identified by fingerprint, or the current one if empty.
+func (s *Service) VerifyingKeyFingerprint(proofType, fingerprint string) (string, error) {
+	keys, err := s.keys(proofType)
+	if err != nil {
+		return "", err
+	}
+	if fingerprint == "" {
+		fingerprint = keys.current.fingerprint
+	}
+	vk, ok := keys.verifyingKeys[fingerprint]
+	if !ok {
+		return "", fmt.Errorf("zkp: no verifying key for %s circuit %s", proofType, fingerprint)
+	}
+	return verifyingKeyFingerprint(vk.vk)
+}
 
-	// For MVP, we use deterministic/mock variables for degree details
-	// taking studentID, issueDate, etc as constants
-	studentIDInt := big.NewInt(123456)
-	issueDateInt := big.NewInt(1600000000)
-	validUntilInt := big.NewInt(1800000000)
-	institutionHashInt := big.NewInt(987654)
+// GenerateProof creates a proof of proofType, with the backend configured for
+// it, for the given document and credential claims. Claim errors wrap ErrInvalidClaims and unknown
+// proof types wrap ErrUnknownProofType.
+func (s *Service) GenerateProof(doc Document, proofType string, claims Claims) (*ProofResult, error) {
+	start := time.Now()
 
-	assignment := &DegreeCircuit{
-		DegreeHash:      hashInt,
-		StudentID:       studentIDInt,
-		IssueDate:       issueDateInt,
-		InstitutionHash: institutionHashInt,
-		ValidUntil:      validUntilInt,
+	pt, err := LookupProofType(proofType)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.keys(proofType)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment, err := pt.Assign(doc, claims)
+	if err != nil {
+		return nil, err
 	}
 
 	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
@@ -99,27 +261,22 @@ func (s *Service) GenerateProof(documentData []byte, proofType string) (*ProofRe
 		return nil, fmt.Errorf("zkp: failed to create public witness: %w", err)
 	}
 
-	proof, err := groth16.Prove(s.ccs, s.pk, witness)
+	current := keys.current
+	proofBytes, err := provingSystems[current.backend].prove(current.ccs, current.pk, witness)
 	if err != nil {
 		return nil, fmt.Errorf("zkp: failed to generate proof: %w", err)
 	}
 
-	// Serialize proof to bytes for storage
-	var proofBuf bytes.Buffer
-	if _, err := proof.WriteTo(&proofBuf); err != nil {
-		return nil, fmt.Errorf("zkp: failed to serialize proof: %w", err)
-	}
-
 	// Serialize public witness instead of verifying key
 	pubWitnessBytes, err := pubWitness.MarshalBinary()
 	if err != nil {
 		return nil, fmt.Errorf("zkp: failed to serialize public witness: %w", err)
 	}
 
-	proofBytes := proofBuf.Bytes()
-
 	return &ProofResult{
-		Hash:               hex.EncodeToString(hash[:]),
+		Hash:               ProofHash(proofBytes, pubWitnessBytes),
+		Backend:            current.backend,
+		Fingerprint:        current.fingerprint,
 		ProofBytes:         proofBytes,
 		PublicWitnessBytes: pubWitnessBytes,
 		VerificationTime:   time.Since(start).Milliseconds(),
@@ -127,18 +284,25 @@ func (s *Service) GenerateProof(documentData []byte, proofType string) (*ProofRe
 	}, nil
 }
 
-// VerifyProofFromBytes deserializes and verifies a stored proof.
-func (s *Service) VerifyProofFromBytes(proofData []byte, pubWitnessData []byte) (bool, error) {
-	proof := groth16.NewProof(ecc.BN254)
-	if _, err := proof.ReadFrom(bytes.NewReader(proofData)); err != nil {
-		return false, fmt.Errorf("zkp: failed to deserialize proof: %w", err)
-	}
+// ProofHash identifies a proof by its own contents: a document can back
+// several proofs, of different types or parameters.
+func ProofHash(proofBytes, pubWitnessBytes []byte) string {
+	hash := sha256.Sum256(append(append([]byte{}, proofBytes...), pubWitnessBytes...))
+	return hex.EncodeToString(hash[:])
+}
 
-	// Use the static loaded VK from service startup
-	vk := s.vk
+// VerifyProofFromBytes deserializes and verifies a stored proof of proofType
+// made with backend against the key set identified by fingerprint. An empty
+// fingerprint selects the current keys and an empty backend Groth16, which
+// covers proofs stored before either was recorded.
+func (s *Service) VerifyProofFromBytes(proofType, backend string, proofData []byte, pubWitnessData []byte, fingerprint string) (bool, error) {
+	vk, err := s.verifierKey(proofType, backend, fingerprint)
+	if err != nil {
+		return false, err
+	}
 
 	// Build public witness from data
-	pubWitness, err := frontend.NewWitness(&DegreeCircuit{}, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	pubWitness, err := witness.New(ecc.BN254.ScalarField())
 	if err != nil {
 		return false, fmt.Errorf("zkp: failed to create public witness target: %w", err)
 	}
@@ -146,9 +310,38 @@ func (s *Service) VerifyProofFromBytes(proofData []byte, pubWitnessData []byte)
 		return false, fmt.Errorf("zkp: failed to unmarshal public witness: %w", err)
 	}
 
-	if err := groth16.Verify(proof, vk, pubWitness); err != nil {
-		return false, nil // invalid proof, not an error
+	valid, err := provingSystems[vk.backend].verify(proofData, vk.vk, pubWitness)
+	if err != nil || !valid {
+		return false, err // an invalid proof is not an error
+	}
+	if pt := proofTypes[proofType]; pt.CheckPublic != nil {
+		values, ok := pubWitness.Vector().(fr.Vector)
+		if !ok || pt.CheckPublic(values) != nil {
+			return false, nil
+		}
 	}
 	return true, nil
 }
 
+// VerifyBundle verifies a proof bundle from another node. It fails if the
+// bundle was made with a verifying key this node does not have, which happens
+// when the prover's node ran its own trusted setup.
+func (s *Service) VerifyBundle(b *Bundle) (bool, error) {
+	vkFingerprint, err := s.VerifyingKeyFingerprint(b.ProofType, b.Fingerprint)
+	if err != nil {
+		return false, err
+	}
+	if b.VerifyingKey != "" && b.VerifyingKey != vkFingerprint {
+		return false, fmt.Errorf("zkp: bundle was made with verifying key %s, this node has %s (different trusted setup)",
+			shortFingerprint(b.VerifyingKey), shortFingerprint(vkFingerprint))
+	}
+	return s.VerifyProofFromBytes(b.ProofType, b.Backend, b.Proof, b.PublicWitness, b.Fingerprint)
+}
+
+// shortFingerprint abbreviates a fingerprint for messages.
+func shortFingerprint(fp string) string {
+	if len(fp) > 16 {
+		return fp[:16]
+	}
+	return fp
+}
@@ -0,0 +1,234 @@
+package zkp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/plonk"
+	plonkbn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"golang.org/x/crypto/sha3"
+)
+
+// SolidityCalldata is a proof encoded for the verifier contract that
+// ExportSolidity writes for its key set.
+type SolidityCalldata struct {
+	Backend Backend `json:"backend"`
+	// Function is the contract function Data calls.
+	Function string `json:"function"`
+	// Proof is the proof argument, hex-encoded: the eight uint256 words of
+	// a Groth16 proof, or the bytes of a PLONK proof.
+	Proof string `json:"proof"`
+	// Inputs are the public inputs as decimal uint256.
+	Inputs []string `json:"inputs"`
+	// Data is the complete ABI-encoded call, selector included, ready for
+	// eth_call or a transaction.
+	Data string `json:"data"`
+}
+
+// ExportSolidity writes a Solidity contract that verifies proofs of proofType
+// made with the key set identified by fingerprint, or the current one if
+// empty. It returns the verifying key fingerprint the contract embeds.
+func (s *Service) ExportSolidity(proofType, fingerprint string, w io.Writer) (string, error) {
+	keys, err := s.keys(proofType)
+	if err != nil {
+		return "", err
+	}
+	if fingerprint == "" {
+		fingerprint = keys.current.fingerprint
+	}
+	vk, ok := keys.verifyingKeys[fingerprint]
+	if !ok {
+		return "", fmt.Errorf("zkp: no verifying key for %s circuit %s", proofType, fingerprint)
+	}
+	return exportSolidity(vk, w)
+}
+
+// ExportSolidityFromDisk is ExportSolidity for a node that is not running: it
+// compiles the proofType circuit for backend and reads the verifying key of
+// the current circuit from dataDir.
+func ExportSolidityFromDisk(dataDir, proofType string, backend Backend, w io.Writer) (string, error) {
+	system, ok := provingSystems[backend]
+	if !ok {
+		return "", fmt.Errorf("zkp: unknown backend %q", backend)
+	}
+	ccs, err := compileCircuit(proofType, system)
+	if err != nil {
+		return "", err
+	}
+	fingerprint, err := circuitFingerprint(ccs)
+	if err != nil {
+		return "", err
+	}
+
+	dir := keysDir(dataDir, proofType, backend)
+	vks := make(map[string]verifierKey)
+	if err := loadVerifyingKeys(dir, backend, vks); err != nil {
+		return "", err
+	}
+	vk, ok := vks[fingerprint]
+	if !ok {
+		return "", fmt.Errorf("zkp: no %s keys for %s circuit %s in %s; start the node once to create them", backend, proofType, shortFingerprint(fingerprint), dir)
+	}
+	return exportSolidity(vk, w)
+}
+
+func exportSolidity(vk verifierKey, w io.Writer) (string, error) {
+	vkFingerprint, err := verifyingKeyFingerprint(vk.vk)
+	if err != nil {
+		return "", err
+	}
+	// Export from a copy: gnark's Groth16 exporter modifies the key while
+	// it runs, and the original may be verifying proofs concurrently.
+	system := provingSystems[vk.backend]
+	data, err := serialize(vk.vk)
+	if err != nil {
+		return "", err
+	}
+	clone := system.newVerifyingKey()
+	if _, err := clone.ReadFrom(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("zkp: failed to copy verifying key: %w", err)
+	}
+	if err := system.exportSolidity(clone, w); err != nil {
+		return "", fmt.Errorf("zkp: failed to export Solidity verifier: %w", err)
+	}
+	return vkFingerprint, nil
+}
+
+// EncodeSolidityCalldata encodes a stored proof made with backend and its
+// public witness as a call to the verifier contract. An empty backend is
+// Groth16.
+func EncodeSolidityCalldata(backend string, proof, publicWitness []byte) (*SolidityCalldata, error) {
+	b, err := ParseBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to create public witness target: %w", err)
+	}
+	if err := w.UnmarshalBinary(publicWitness); err != nil {
+		return nil, fmt.Errorf("zkp: failed to unmarshal public witness: %w", err)
+	}
+	public, ok := w.Vector().(fr.Vector)
+	if !ok {
+		return nil, fmt.Errorf("zkp: public witness is not over BN254")
+	}
+
+	call, err := provingSystems[b].solidityCalldata(proof, public)
+	if err != nil {
+		return nil, err
+	}
+	call.Backend = b
+	for i := range public {
+		call.Inputs = append(call.Inputs, public[i].String())
+	}
+	return call, nil
+}
+
+// ── Groth16 ─────────────────────────────────────────────────────────────────
+
+func (groth16System) exportSolidity(vk verifyingKey, w io.Writer) error {
+	bnVK := vk.(*groth16bn254.VerifyingKey)
+	if len(bnVK.PublicAndCommitmentCommitted) > 0 {
+		return fmt.Errorf("circuits with commitments are not supported")
+	}
+	return bnVK.ExportSolidity(w)
+}
+
+// solidityCalldata encodes verifyProof(uint256[8] proof, uint256[n] input).
+// The proof words are A, B and C uncompressed, B in the big-endian coordinate
+// order of the EVM pairing precompile, which is how gnark writes raw points.
+func (groth16System) solidityCalldata(proofData []byte, public fr.Vector) (*SolidityCalldata, error) {
+	proof := groth16.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofData)); err != nil {
+		return nil, fmt.Errorf("zkp: failed to deserialize proof: %w", err)
+	}
+	bnProof := proof.(*groth16bn254.Proof)
+	if len(bnProof.Commitments) > 0 {
+		return nil, fmt.Errorf("zkp: proofs with commitments are not supported by the Solidity verifier")
+	}
+	var raw bytes.Buffer
+	if _, err := bnProof.WriteRawTo(&raw); err != nil {
+		return nil, fmt.Errorf("zkp: failed to serialize proof: %w", err)
+	}
+	words := raw.Bytes()[:8*32]
+
+	function := fmt.Sprintf("verifyProof(uint256[8],uint256[%d])", len(public))
+	data := abiSelector(function)
+	data = append(data, words...)
+	for i := range public {
+		data = append(data, abiWord(public[i])...)
+	}
+	return &SolidityCalldata{
+		Function: function,
+		Proof:    "0x" + hex.EncodeToString(words),
+		Data:     "0x" + hex.EncodeToString(data),
+	}, nil
+}
+
+// ── PLONK ───────────────────────────────────────────────────────────────────
+
+func (plonkSystem) exportSolidity(vk verifyingKey, w io.Writer) error {
+	return vk.(*plonkbn254.VerifyingKey).ExportSolidity(w)
+}
+
+// solidityCalldata encodes Verify(bytes proof, uint256[] public_inputs).
+func (plonkSystem) solidityCalldata(proofData []byte, public fr.Vector) (*SolidityCalldata, error) {
+	proof := plonk.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofData)); err != nil {
+		return nil, fmt.Errorf("zkp: failed to deserialize proof: %w", err)
+	}
+	encoded := proof.(*plonkbn254.Proof).MarshalSolidity()
+
+	// Head: the offsets of both dynamic arguments; tail: each argument's
+	// length followed by its contents, padded to whole words.
+	const function = "Verify(bytes,uint256[])"
+	padded := (len(encoded) + 31) / 32 * 32
+	data := abiSelector(function)
+	data = append(data, abiUint(2*32)...)
+	data = append(data, abiUint(uint64(2*32+32+padded))...)
+	data = append(data, abiUint(uint64(len(encoded)))...)
+	data = append(data, encoded...)
+	data = append(data, make([]byte, padded-len(encoded))...)
+	data = append(data, abiUint(uint64(len(public)))...)
+	for i := range public {
+		data = append(data, abiWord(public[i])...)
+	}
+	return &SolidityCalldata{
+		Function: function,
+		Proof:    "0x" + hex.EncodeToString(encoded),
+		Data:     "0x" + hex.EncodeToString(data),
+	}, nil
+}
+
+// ── ABI encoding ────────────────────────────────────────────────────────────
+
+// abiSelector is the first four bytes of the Keccak-256 hash of a function
+// signature.
+func abiSelector(signature string) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+	return h.Sum(nil)[:4]
+}
+
+func abiUint(v uint64) []byte {
+	word := make([]byte, 32)
+	binary.BigEndian.PutUint64(word[24:], v)
+	return word
+}
+
+func abiWord(e fr.Element) []byte {
+	word := make([]byte, 32)
+	e.BigInt(new(big.Int)).FillBytes(word)
+	return word
+}
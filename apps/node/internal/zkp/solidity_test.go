@@ -0,0 +1,229 @@
+package zkp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/plonk"
+	plonkbn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"github.com/consensys/gnark/frontend"
+)
+
+// orderCircuit has two public inputs with different values, so calldata
+// with them swapped does not verify.
+type orderCircuit struct {
+	X, Y    frontend.Variable
+	Sum     frontend.Variable `gnark:",public"`
+	Product frontend.Variable `gnark:",public"`
+}
+
+func (c *orderCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Add(c.X, c.Y), c.Sum)
+	api.AssertIsEqual(api.Mul(c.X, c.Y), c.Product)
+	return nil
+}
+
+// solidityProof proves orderCircuit for 3 and 4 with backend and returns
+// the proof, its public witness and the verifying key.
+func solidityProof(t *testing.T, backend Backend) (proof, public []byte, vk verifyingKey) {
+	t.Helper()
+	system := provingSystems[backend]
+	ccs, err := system.compile(&orderCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srs *srsStore
+	if backend == BackendPlonk {
+		srs = testSRSStore(t, srsSize(ccs))
+	}
+	pk, vk, err := system.setup(ccs, srs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := frontend.NewWitness(&orderCircuit{X: 3, Y: 4, Sum: 7, Product: 12}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof, err = system.prove(ccs, pk, full); err != nil {
+		t.Fatal(err)
+	}
+	if public, err = w.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	return proof, public, vk
+}
+
+// calldataWords splits hex calldata into its selector and 32-byte words.
+func calldataWords(t *testing.T, data string) (selector []byte, words [][]byte) {
+	t.Helper()
+	raw, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) < 4 || (len(raw)-4)%32 != 0 {
+		t.Fatalf("calldata of %d bytes is not a selector and whole words", len(raw))
+	}
+	for rest := raw[4:]; len(rest) > 0; rest = rest[32:] {
+		words = append(words, rest[:32])
+	}
+	return raw[:4], words
+}
+
+func wordUint(word []byte) uint64 {
+	return new(big.Int).SetBytes(word).Uint64()
+}
+
+// contractDeclares checks that the verifier exported for vk declares
+// declaration.
+func contractDeclares(t *testing.T, backend Backend, vk verifyingKey, declaration string) {
+	t.Helper()
+	var contract bytes.Buffer
+	if _, err := exportSolidity(verifierKey{backend: backend, vk: vk}, &contract); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(strings.Fields(contract.String()), " "), declaration) {
+		t.Fatalf("%s verifier does not declare %q", backend, declaration)
+	}
+}
+
+func TestSolidityCalldataGroth16(t *testing.T) {
+	proofData, public, vk := solidityProof(t, BackendGroth16)
+	contractDeclares(t, BackendGroth16, vk, "function verifyProof( uint256[8] calldata proof, uint256[2] calldata input )")
+
+	call, err := EncodeSolidityCalldata("", proofData, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Backend != BackendGroth16 || call.Function != "verifyProof(uint256[8],uint256[2])" {
+		t.Fatalf("encoded a call to %s for %s", call.Function, call.Backend)
+	}
+	if len(call.Inputs) != 2 || call.Inputs[0] != "7" || call.Inputs[1] != "12" {
+		t.Fatalf("inputs %v, want [7 12]", call.Inputs)
+	}
+	selector, words := calldataWords(t, call.Data)
+	if !bytes.Equal(selector, abiSelector(call.Function)) || len(words) != 8+2 {
+		t.Fatalf("calldata has selector %x and %d words", selector, len(words))
+	}
+	if call.Proof != "0x"+hex.EncodeToString(bytes.Join(words[:8], nil)) {
+		t.Fatal("proof argument differs from the proof words in the calldata")
+	}
+
+	// The words are A, B with the imaginary part of each coordinate first,
+	// as the pairing precompile reads them, then C; the inputs follow in
+	// circuit order.
+	p := groth16.NewProof(ecc.BN254)
+	if _, err := p.ReadFrom(bytes.NewReader(proofData)); err != nil {
+		t.Fatal(err)
+	}
+	want := p.(*groth16bn254.Proof)
+	for i, e := range []fp.Element{
+		want.Ar.X, want.Ar.Y,
+		want.Bs.X.A1, want.Bs.X.A0, want.Bs.Y.A1, want.Bs.Y.A0,
+		want.Krs.X, want.Krs.Y,
+	} {
+		if b := e.Bytes(); !bytes.Equal(words[i], b[:]) {
+			t.Fatalf("proof word %d is %x, want %x", i, words[i], b)
+		}
+	}
+	if wordUint(words[8]) != 7 || wordUint(words[9]) != 12 {
+		t.Fatalf("calldata inputs %x, %x, want 7, 12", words[8], words[9])
+	}
+
+	// Decoded back, the calldata verifies; with the inputs swapped it does
+	// not.
+	var got groth16bn254.Proof
+	for i, e := range []*fp.Element{
+		&got.Ar.X, &got.Ar.Y,
+		&got.Bs.X.A1, &got.Bs.X.A0, &got.Bs.Y.A1, &got.Bs.Y.A0,
+		&got.Krs.X, &got.Krs.Y,
+	} {
+		if err := e.SetBytesCanonical(words[i]); err != nil {
+			t.Fatalf("proof word %d: %v", i, err)
+		}
+	}
+	var inputs fr.Vector = make([]fr.Element, 2)
+	inputs[0].SetBytes(words[8])
+	inputs[1].SetBytes(words[9])
+	bnVK := vk.(*groth16bn254.VerifyingKey)
+	if err := groth16bn254.Verify(&got, bnVK, inputs); err != nil {
+		t.Fatalf("decoded calldata does not verify: %v", err)
+	}
+	inputs[0], inputs[1] = inputs[1], inputs[0]
+	if err := groth16bn254.Verify(&got, bnVK, inputs); err == nil {
+		t.Fatal("decoded calldata verifies with the inputs swapped")
+	}
+}
+
+func TestSolidityCalldataPlonk(t *testing.T) {
+	proofData, public, vk := solidityProof(t, BackendPlonk)
+	contractDeclares(t, BackendPlonk, vk, "function Verify(bytes calldata proof, uint256[] calldata public_inputs)")
+
+	call, err := EncodeSolidityCalldata(string(BackendPlonk), proofData, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Backend != BackendPlonk || call.Function != "Verify(bytes,uint256[])" {
+		t.Fatalf("encoded a call to %s for %s", call.Function, call.Backend)
+	}
+	selector, words := calldataWords(t, call.Data)
+	if !bytes.Equal(selector, abiSelector(call.Function)) || len(words) < 4 {
+		t.Fatalf("calldata has selector %x and %d words", selector, len(words))
+	}
+
+	// The head holds the offsets of the proof bytes and the input array.
+	p := plonk.NewProof(ecc.BN254)
+	if _, err := p.ReadFrom(bytes.NewReader(proofData)); err != nil {
+		t.Fatal(err)
+	}
+	want := p.(*plonkbn254.Proof).MarshalSolidity()
+	proofAt, inputsAt := wordUint(words[0])/32, wordUint(words[1])/32
+	if proofAt != 2 || wordUint(words[proofAt]) != uint64(len(want)) {
+		t.Fatalf("proof at word %d with length %d, want at 2 with length %d", proofAt, wordUint(words[proofAt]), len(want))
+	}
+	got := bytes.Join(words[proofAt+1:inputsAt], nil)
+	if !bytes.Equal(got[:len(want)], want) || bytes.Count(got[len(want):], []byte{0}) != len(got)-len(want) {
+		t.Fatal("proof bytes in the calldata differ from the proof")
+	}
+	if call.Proof != "0x"+hex.EncodeToString(want) {
+		t.Fatal("proof argument differs from the proof")
+	}
+	if int(inputsAt)+3 != len(words) || wordUint(words[inputsAt]) != 2 ||
+		wordUint(words[inputsAt+1]) != 7 || wordUint(words[inputsAt+2]) != 12 {
+		t.Fatalf("input array at word %d of %d is not [7 12]", inputsAt, len(words))
+	}
+	if len(call.Inputs) != 2 || call.Inputs[0] != "7" || call.Inputs[1] != "12" {
+		t.Fatalf("inputs %v, want [7 12]", call.Inputs)
+	}
+}
+
+func TestSolidityCalldataInvalid(t *testing.T) {
+	proofData, public, _ := solidityProof(t, BackendGroth16)
+	if _, err := EncodeSolidityCalldata("stark", proofData, public); err == nil {
+		t.Fatal("encoded a proof for an unknown backend")
+	}
+	if _, err := EncodeSolidityCalldata("", proofData[:len(proofData)/2], public); err == nil {
+		t.Fatal("encoded a truncated proof")
+	}
+	if _, err := EncodeSolidityCalldata("", proofData, public[:len(public)-1]); err == nil {
+		t.Fatal("encoded a truncated public witness")
+	}
+}
+
+func TestABISelector(t *testing.T) {
+	// The ERC-20 transfer selector.
+	if got := hex.EncodeToString(abiSelector("transfer(address,uint256)")); got != "a9059cbb" {
+		t.Fatalf("selector %s, want a9059cbb", got)
+	}
+}
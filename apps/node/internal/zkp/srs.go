@@ -0,0 +1,105 @@
+package zkp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/kzg"
+	"github.com/consensys/gnark/constraint"
+	"github.com/sirupsen/logrus"
+)
+
+// devSRSSize is the size of the SRS generated when none is installed: 2^16
+// points plus the 3 PLONK blinds, enough for every built-in circuit.
+const devSRSSize = 1<<16 + 3
+
+// srsFile is where the PLONK SRS is read from by default.
+func srsFile(dataDir string) string {
+	return filepath.Join(dataDir, "zkp", "srs", "bn254.srs")
+}
+
+// srsStore loads the universal KZG SRS that PLONK keys are derived from. The
+// file is gnark-crypto's own serialization of a BN254 kzg.SRS, so one taken
+// from a public powers-of-tau ceremony can be installed as is.
+type srsStore struct {
+	path   string
+	logger *logrus.Logger
+	srs    *kzg.SRS
+}
+
+// forCircuit returns the SRS, loading it on first use, and checks that it is
+// large enough for ccs. If no SRS is installed it generates one from local
+// randomness: fine for development, but whoever holds that randomness could
+// forge proofs.
+func (s *srsStore) forCircuit(ccs constraint.ConstraintSystem) (*kzg.SRS, error) {
+	needed := srsSize(ccs)
+
+	if s.srs == nil {
+		srs, err := readSRS(s.path)
+		switch {
+		case err == nil:
+			s.logger.Infof("ZKP: loaded PLONK SRS from %s (%d points)", s.path, len(srs.Pk.G1))
+		case errors.Is(err, os.ErrNotExist):
+			s.logger.Warnf("ZKP: no PLONK SRS at %s, generating an insecure local one; install one from a powers-of-tau ceremony for production", s.path)
+			if srs, err = generateSRS(s.path, max(needed, devSRSSize)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+		s.srs = srs
+	}
+
+	if uint64(len(s.srs.Pk.G1)) < needed {
+		return nil, fmt.Errorf("zkp: PLONK SRS in %s has %d points, circuit needs %d", s.path, len(s.srs.Pk.G1), needed)
+	}
+	return s.srs, nil
+}
+
+// srsSize is the number of SRS points PLONK needs for ccs.
+func srsSize(ccs constraint.ConstraintSystem) uint64 {
+	return ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints()+ccs.GetNbPublicVariables())) + 3
+}
+
+func readSRS(path string) (*kzg.SRS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	srs := new(kzg.SRS)
+	if _, err := srs.ReadFrom(bufio.NewReader(f)); err != nil {
+		return nil, fmt.Errorf("zkp: failed to decode %s: %w", path, err)
+	}
+	return srs, nil
+}
+
+func generateSRS(path string, size uint64) (*kzg.SRS, error) {
+	tau, err := rand.Int(rand.Reader, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to sample SRS secret: %w", err)
+	}
+	srs, err := kzg.NewSRS(size, tau)
+	if err != nil {
+		return nil, fmt.Errorf("zkp: failed to generate SRS: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("zkp: failed to create SRS dir: %w", err)
+	}
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		_, err := srs.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return srs, nil
+}
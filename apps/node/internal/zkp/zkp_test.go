@@ -0,0 +1,83 @@
+package zkp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// testDocument returns a vault document with a fixed salt.
+func testDocument() Document {
+	digest := sha256.Sum256([]byte("scanned credential"))
+	return Document{Digest: digest[:], Salt: big.NewInt(424242)}
+}
+
+// issue signs the credential claims of c for proofType with a new issuer key
+// and adds the issuer claims, as an issuing office would.
+func issue(t *testing.T, proofType string, doc Document, c Claims) Claims {
+	t.Helper()
+	key, err := GenerateIssuerKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := LookupProofType(proofType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignCredential(key, proofType, doc.Digest, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c[ClaimIssuerPublicKey] = hex.EncodeToString(key.PublicKey.Bytes())
+	c[pt.SignatureClaimName()] = sig
+	return c
+}
+
+// assign builds the witness of proofType for doc and c.
+func assign(t *testing.T, proofType string, doc Document, c Claims) (circuit, assignment frontend.Circuit) {
+	t.Helper()
+	pt, err := LookupProofType(proofType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment, err = pt.Assign(doc, c)
+	if err != nil {
+		t.Fatalf("assign %s: %v", proofType, err)
+	}
+	return pt.Circuit(), assignment
+}
+
+func assertSolved(t *testing.T, circuit, assignment frontend.Circuit) {
+	t.Helper()
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("circuit not solved: %v", err)
+	}
+}
+
+func assertNotSolved(t *testing.T, circuit, assignment frontend.Circuit) {
+	t.Helper()
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("circuit solved with a bad witness")
+	}
+}
+
+// powerCircuit is a small circuit for backend and ceremony tests: it proves
+// knowledge of X with X^32 = Y.
+type powerCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *powerCircuit) Define(api frontend.API) error {
+	x := c.X
+	for i := 0; i < 5; i++ {
+		x = api.Mul(x, x)
+	}
+	api.AssertIsEqual(x, c.Y)
+	return nil
+}
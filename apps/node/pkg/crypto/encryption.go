@@ -26,15 +26,34 @@ func NewEncryptionService(password string) *EncryptionService {
 	return &EncryptionService{key: key}
 }
 
-func (e *EncryptionService) Encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+// NewEncryptionServiceFromKey encrypts with a raw KeySize key, such as a
+// document key from the Keystore.
+func NewEncryptionServiceFromKey(key []byte) (*EncryptionService, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
 	}
+	return &EncryptionService{key: key}, nil
+}
 
-	gcm, err := cipher.NewGCM(block)
+// Encrypt returns nonce||ciphertext, bound to nothing.
+//
+// Deprecated: use Seal, which binds the ciphertext to its document.
+func (e *EncryptionService) Encrypt(plaintext []byte) ([]byte, error) {
+	return sealAESGCM(e.key, plaintext, nil)
+}
+
+// Decrypt reverses Encrypt.
+func (e *EncryptionService) Decrypt(ciphertext []byte) ([]byte, error) {
+	return openAESGCM(e.key, ciphertext, nil)
+}
+
+const gcmNonceSize = 12
+
+// sealAESGCM encrypts plaintext under key and returns nonce||ciphertext.
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
@@ -42,19 +61,14 @@ func (e *EncryptionService) Encrypt(plaintext []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return ciphertext, nil
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
 }
 
-func (e *EncryptionService) Decrypt(ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
+// openAESGCM reverses sealAESGCM.
+func openAESGCM(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
@@ -63,7 +77,20 @@ func (e *EncryptionService) Decrypt(ciphertext []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
 }
 
 func Hash(data []byte) string {
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A ciphertext envelope frames document ciphertext as
+//
+//	magic "LPEV" | version | algorithm | key ID (8) | nonce | ciphertext
+//
+// The header and the document's ID and hash are authenticated as associated
+// data, so an envelope moved to another document, or with its header
+// altered, fails to open. The version and algorithm leave room to change
+// the cipher later; older envelopes stay readable by their algorithm ID.
+
+var envelopeMagic = []byte("LPEV")
+
+const envelopeVersion = 1
+
+// EnvelopeAlgorithm identifies the AEAD sealing an envelope.
+type EnvelopeAlgorithm byte
+
+const (
+	// AlgAES256GCM is AES-256 in GCM mode with a random 96-bit nonce.
+	AlgAES256GCM EnvelopeAlgorithm = 1
+)
+
+const envelopeKeyIDSize = 8
+
+// envelopeHeaderSize is the header up to, not including, the nonce.
+var envelopeHeaderSize = len(envelopeMagic) + 1 + 1 + envelopeKeyIDSize
+
+// ErrEnvelopeKey is returned when an envelope was sealed under another key.
+var ErrEnvelopeKey = errors.New("envelope was sealed under another key")
+
+// AssociatedData is what an envelope is bound to besides its header.
+type AssociatedData struct {
+	DocumentID string
+	// Hash is the document's plaintext hash, as returned by Hash.
+	Hash string
+}
+
+// encode writes each field length-prefixed so that no two values encode
+// alike.
+func (ad AssociatedData) encode(header []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(header)
+	for _, f := range []string{ad.DocumentID, ad.Hash} {
+		binary.Write(&buf, binary.BigEndian, uint32(len(f)))
+		buf.WriteString(f)
+	}
+	return buf.Bytes()
+}
+
+// IsEnvelope reports whether data starts like a ciphertext envelope rather
+// than the legacy nonce||ciphertext framing.
+func IsEnvelope(data []byte) bool {
+	return len(data) >= envelopeHeaderSize && bytes.HasPrefix(data, envelopeMagic)
+}
+
+// Seal encrypts plaintext into an envelope bound to ad.
+func (e *EncryptionService) Seal(plaintext []byte, ad AssociatedData) ([]byte, error) {
+	gcm, err := newGCM(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, envelopeHeaderSize+gcm.NonceSize())
+	header = append(header, envelopeMagic...)
+	header = append(header, envelopeVersion, byte(AlgAES256GCM))
+	header = append(header, e.keyID()...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, plaintext, ad.encode(header)), nil
+}
+
+// Open decrypts an envelope bound to ad, including a stream envelope held in
+// memory. Data in the legacy framing, written by Encrypt, is opened as
+// before, without associated data.
+func (e *EncryptionService) Open(data []byte, ad AssociatedData) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return e.Decrypt(data)
+	}
+
+	version, alg := data[len(envelopeMagic)], EnvelopeAlgorithm(data[len(envelopeMagic)+1])
+	if version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", version)
+	}
+	if alg == AlgAES256GCMStream {
+		r, err := e.OpenStream(bytes.NewReader(data), ad)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+	if alg != AlgAES256GCM {
+		return nil, fmt.Errorf("unsupported envelope algorithm %d", alg)
+	}
+	keyID := data[envelopeHeaderSize-envelopeKeyIDSize : envelopeHeaderSize]
+	if !bytes.Equal(keyID, e.keyID()) {
+		return nil, fmt.Errorf("%w %s", ErrEnvelopeKey, hex.EncodeToString(keyID))
+	}
+
+	gcm, err := newGCM(e.key)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := envelopeHeaderSize + gcm.NonceSize()
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("envelope too short")
+	}
+	header := data[:headerSize]
+	nonce := header[envelopeHeaderSize:]
+	plaintext, err := gcm.Open(nil, nonce, data[headerSize:], ad.encode(header))
+	if err != nil {
+		return nil, fmt.Errorf("envelope does not open for document %s: %w", ad.DocumentID, err)
+	}
+	return plaintext, nil
+}
+
+// keyID identifies the service's key in envelopes without revealing it.
+func (e *EncryptionService) keyID() []byte {
+	id, _ := hex.DecodeString(keyID(e.key))
+	return id
+}
@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := []byte("scanned credential")
+	ad := AssociatedData{DocumentID: "doc-1", Hash: Hash(data)}
+
+	envelope, err := enc.Seal(data, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEnvelope(envelope) {
+		t.Fatal("sealed data is not an envelope")
+	}
+	got, err := enc.Open(envelope, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("opened %q, want %q", got, data)
+	}
+
+	// OpenStream reads envelopes sealed whole too.
+	r, err := enc.OpenStream(bytes.NewReader(envelope), ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("streamed %q, %v", got, err)
+	}
+}
+
+func TestEnvelopeTamper(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := []byte("scanned credential")
+	ad := AssociatedData{DocumentID: "doc-1", Hash: Hash(data)}
+	envelope, err := enc.Seal(data, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The header is both checked and authenticated, the rest authenticated.
+	for at := range envelope {
+		tampered := append([]byte{}, envelope...)
+		tampered[at] ^= 0x01
+		if _, err := enc.Open(tampered, ad); err == nil {
+			t.Fatalf("byte %d altered: envelope opened", at)
+		}
+	}
+
+	if _, err := enc.Open(envelope, AssociatedData{DocumentID: "doc-2", Hash: ad.Hash}); err == nil {
+		t.Fatal("envelope opened for another document")
+	}
+	if _, err := enc.Open(envelope, AssociatedData{DocumentID: ad.DocumentID, Hash: Hash([]byte("other"))}); err == nil {
+		t.Fatal("envelope opened under another document hash")
+	}
+	if _, err := testEncryptionService(t, 2).Open(envelope, ad); !errors.Is(err, ErrEnvelopeKey) {
+		t.Fatalf("other key: got %v, want ErrEnvelopeKey", err)
+	}
+}
+
+func TestEnvelopeTruncated(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := []byte("scanned credential")
+	ad := AssociatedData{DocumentID: "doc-1", Hash: Hash(data)}
+	envelope, err := enc.Seal(data, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{envelopeHeaderSize, envelopeHeaderSize + gcmNonceSize, len(envelope) - 1} {
+		if _, err := enc.Open(envelope[:n], ad); err == nil {
+			t.Fatalf("cut at %d bytes: envelope opened", n)
+		}
+	}
+}
+
+func TestEnvelopeLegacy(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := []byte("scanned credential")
+	legacy, err := enc.Encrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsEnvelope(legacy) {
+		t.Fatal("legacy ciphertext taken for an envelope")
+	}
+	got, err := enc.Open(legacy, AssociatedData{DocumentID: "doc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("opened %q, want %q", got, data)
+	}
+}
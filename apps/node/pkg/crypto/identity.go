@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// HolderIdentity is the long-term Ed25519 key of the holder running the
+// node. It signs the holder's documents and proofs, so that peers can tell
+// which holder they came from. Unlike the libp2p host key it survives
+// restarts, and unlike the vault master key it is not behind the
+// passphrase: it signs whether or not the vault is unlocked.
+type HolderIdentity struct {
+	key ed25519.PrivateKey
+}
+
+const holderKeyPEMType = "PRIVATE KEY"
+
+// Signing domains keep a holder signature over one kind of message from
+// being passed off as another.
+const (
+	DomainDocumentStatement = "lairik-pulse/document-statement/v1"
+	DomainProofBundle       = "lairik-pulse/proof-bundle/v1"
+)
+
+// LoadHolderIdentity reads the holder key at path, a PKCS #8 PEM file,
+// creating it on first start. created reports whether it was.
+func LoadHolderIdentity(path string) (id *HolderIdentity, created bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to generate holder key: %w", err)
+		}
+		id := &HolderIdentity{key: key}
+		if err := id.Save(path); err != nil {
+			return nil, false, err
+		}
+		return id, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read holder key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != holderKeyPEMType {
+		return nil, false, fmt.Errorf("holder key %s is not a PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid holder key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, false, fmt.Errorf("holder key %s is not an Ed25519 key", path)
+	}
+	return &HolderIdentity{key: key}, false, nil
+}
+
+// HolderIdentityFromSeed rebuilds a holder identity from its seed, as kept
+// in the key ring.
+func HolderIdentityFromSeed(seed []byte) (*HolderIdentity, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("holder key must be a %d-byte Ed25519 seed", ed25519.SeedSize)
+	}
+	return &HolderIdentity{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// Save writes the holder key to path, replacing any key there.
+func (h *HolderIdentity) Save(path string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(h.key)
+	if err != nil {
+		return fmt.Errorf("failed to encode holder key: %w", err)
+	}
+	if err := writeFileAtomic(path, pem.EncodeToMemory(&pem.Block{Type: holderKeyPEMType, Bytes: der})); err != nil {
+		return fmt.Errorf("failed to write holder key: %w", err)
+	}
+	return nil
+}
+
+// Seed returns the private seed the holder key is derived from.
+func (h *HolderIdentity) Seed() []byte {
+	return h.key.Seed()
+}
+
+// PublicKey returns the holder's public key, hex-encoded.
+func (h *HolderIdentity) PublicKey() string {
+	return hex.EncodeToString(h.key.Public().(ed25519.PublicKey))
+}
+
+// Sign signs msg in domain.
+func (h *HolderIdentity) Sign(domain string, msg []byte) []byte {
+	return ed25519.Sign(h.key, signingMessage(domain, msg))
+}
+
+// VerifyHolderSignature checks that sig is the signature in domain of msg by
+// the holder with hex-encoded publicKey.
+func VerifyHolderSignature(publicKey, domain string, msg, sig []byte) error {
+	pub, err := hex.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("holder public key must be %d hex-encoded bytes", ed25519.PublicKeySize)
+	}
+	if !ed25519.Verify(pub, signingMessage(domain, msg), sig) {
+		return errors.New("invalid holder signature")
+	}
+	return nil
+}
+
+func signingMessage(domain string, msg []byte) []byte {
+	m := make([]byte, 0, len(domain)+1+len(msg))
+	m = append(m, domain...)
+	m = append(m, 0)
+	return append(m, msg...)
+}
@@ -0,0 +1,436 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A key ring is the one on-disk home of the node's keys, in a JSON file
+// modelled on the Ethereum v3 keystore:
+//
+//	{"version": 1, "keys": [{
+//	  "version": 1, "id": "<uuid>", "name": "holder", "type": "holder-ed25519",
+//	  "key_id": "...", "public_key": "...",
+//	  "crypto": {"cipher": "aes-256-gcm", "ciphertext": "...", "cipherparams": {"nonce": "..."},
+//	             "kdf": "argon2id", "kdfparams": {"salt": "...", "time": 3, "memory": 65536, "threads": 4, "dklen": 32}},
+//	  "created_at": "...", "used_at": "..."}]}
+//
+// Every key is sealed on its own, with its own salt, so a single entry
+// exported to a file is complete: it opens anywhere with its passphrase.
+
+var (
+	// ErrKeyNotFound is returned for a key name the ring does not hold.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists is returned when a key name is already taken.
+	ErrKeyExists = errors.New("a key with that name already exists")
+)
+
+const keyRingVersion = 1
+
+// KeyType says what a key in the ring is for.
+type KeyType string
+
+const (
+	// KeyTypeVaultMaster is the vault master key.
+	KeyTypeVaultMaster KeyType = "vault-master"
+	// KeyTypeHolder is the seed of the holder's Ed25519 identity.
+	KeyTypeHolder KeyType = "holder-ed25519"
+	// KeyTypePeer is the seed of the Ed25519 key behind the node's libp2p
+	// peer ID.
+	KeyTypePeer KeyType = "libp2p-ed25519"
+)
+
+// KeyInfo describes a key in the ring without opening it.
+type KeyInfo struct {
+	Name      string     `json:"name"`
+	Type      KeyType    `json:"type"`
+	ID        string     `json:"id"`
+	KeyID     string     `json:"key_id"`
+	PublicKey string     `json:"public_key,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// KeyRing holds named keys, each sealed under a passphrase.
+type KeyRing struct {
+	path string
+
+	mu   sync.Mutex
+	keys []*keyEntry
+}
+
+type keyRingFile struct {
+	Version int         `json:"version"`
+	Keys    []*keyEntry `json:"keys"`
+}
+
+// keyEntry is one sealed key, on its own when exported.
+type keyEntry struct {
+	Version   int        `json:"version"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Type      KeyType    `json:"type"`
+	KeyID     string     `json:"key_id"`
+	PublicKey string     `json:"public_key,omitempty"`
+	Crypto    keyCrypto  `json:"crypto"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+type keyCrypto struct {
+	Cipher       string `json:"cipher"`
+	CipherText   string `json:"ciphertext"`
+	CipherParams struct {
+		Nonce string `json:"nonce"`
+	} `json:"cipherparams"`
+	KDF       string `json:"kdf"`
+	KDFParams struct {
+		Salt    string `json:"salt"`
+		Time    uint32 `json:"time"`
+		Memory  uint32 `json:"memory"`
+		Threads uint8  `json:"threads"`
+		DKLen   int    `json:"dklen"`
+	} `json:"kdfparams"`
+}
+
+// OpenKeyRing reads the key ring at path; a missing file is an empty ring.
+func OpenKeyRing(path string) (*KeyRing, error) {
+	r := &KeyRing{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key ring: %w", err)
+	}
+
+	var f keyRingFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse key ring %s: %w", path, err)
+	}
+	if f.Version != keyRingVersion {
+		return nil, fmt.Errorf("unsupported key ring %s (version %d)", path, f.Version)
+	}
+	for _, e := range f.Keys {
+		if err := e.check(); err != nil {
+			return nil, fmt.Errorf("key ring %s: %w", path, err)
+		}
+	}
+	r.keys = f.Keys
+	return r, nil
+}
+
+// List describes every key in the ring, by name.
+func (r *KeyRing) List() []KeyInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]KeyInfo, len(r.keys))
+	for i, e := range r.keys {
+		infos[i] = e.info()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Info describes the key name, if the ring holds it.
+func (r *KeyRing) Info(name string) (KeyInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.find(name)
+	if e == nil {
+		return KeyInfo{}, false
+	}
+	return e.info(), true
+}
+
+// Put seals key under passphrase as name, replacing what was there. The
+// creation time is kept when the same key is sealed again.
+func (r *KeyRing) Put(name string, typ KeyType, key []byte, passphrase string) error {
+	if name == "" {
+		return errors.New("key name is required")
+	}
+	e, err := sealKeyEntry(typ, key, passphrase)
+	if err != nil {
+		return err
+	}
+	e.Name = name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := append([]*keyEntry{}, r.keys...)
+	if old := r.find(name); old != nil {
+		if old.KeyID == e.KeyID {
+			e.CreatedAt, e.UsedAt = old.CreatedAt, old.UsedAt
+		}
+		keys = removeEntry(keys, old)
+	}
+	return r.save(append(keys, e))
+}
+
+// Open unseals the key name with passphrase and records its use.
+func (r *KeyRing) Open(name, passphrase string) ([]byte, KeyType, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.find(name)
+	if e == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	key, err := e.open(passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+
+	used := *e
+	now := time.Now().UTC()
+	used.UsedAt = &now
+	if err := r.save(append(removeEntry(append([]*keyEntry{}, r.keys...), e), &used)); err != nil {
+		clear(key)
+		return nil, "", err
+	}
+	return key, e.Type, nil
+}
+
+// Delete removes the key name from the ring.
+func (r *KeyRing) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.find(name)
+	if e == nil {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	return r.save(removeEntry(append([]*keyEntry{}, r.keys...), e))
+}
+
+// Export returns the key name as a standalone JSON file, still sealed under
+// its passphrase.
+func (r *KeyRing) Export(name string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.find(name)
+	if e == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// OpenKeyFile unseals a key exported by Export with passphrase, without
+// adding it to a ring.
+func OpenKeyFile(data []byte, passphrase string) (KeyInfo, []byte, error) {
+	e, err := parseKeyFile(data)
+	if err != nil {
+		return KeyInfo{}, nil, err
+	}
+	key, err := e.open(passphrase)
+	if err != nil {
+		return KeyInfo{}, nil, err
+	}
+	return e.info(), key, nil
+}
+
+// Import adds a key exported by Export, under name or, if name is empty,
+// the name it was exported with. A key already under that name is only
+// replaced if replace is set. The key stays sealed: check it opens with
+// OpenKeyFile first.
+func (r *KeyRing) Import(data []byte, name string, replace bool) (KeyInfo, error) {
+	e, err := parseKeyFile(data)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	if name != "" {
+		e.Name = name
+	}
+	if e.Name == "" {
+		return KeyInfo{}, errors.New("key name is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := append([]*keyEntry{}, r.keys...)
+	if old := r.find(e.Name); old != nil {
+		if !replace {
+			return KeyInfo{}, fmt.Errorf("%w: %s", ErrKeyExists, e.Name)
+		}
+		keys = removeEntry(keys, old)
+	}
+	if err := r.save(append(keys, e)); err != nil {
+		return KeyInfo{}, err
+	}
+	return e.info(), nil
+}
+
+func parseKeyFile(data []byte) (*keyEntry, error) {
+	var e keyEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// find returns the entry name. Callers hold r.mu.
+func (r *KeyRing) find(name string) *keyEntry {
+	for _, e := range r.keys {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// save writes keys to disk and, once written, makes them the ring's. Callers
+// hold r.mu.
+func (r *KeyRing) save(keys []*keyEntry) error {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	data, err := json.MarshalIndent(keyRingFile{Version: keyRingVersion, Keys: keys}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key ring: %w", err)
+	}
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to write key ring: %w", err)
+	}
+	r.keys = keys
+	return nil
+}
+
+func removeEntry(keys []*keyEntry, e *keyEntry) []*keyEntry {
+	for i, k := range keys {
+		if k == e {
+			return append(keys[:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
+
+// sealKeyEntry seals key under passphrase with a fresh salt.
+func sealKeyEntry(typ KeyType, key []byte, passphrase string) (*keyEntry, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, ErrWeakPassphrase
+	}
+	pub, err := keyPublic(typ, key)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	e := &keyEntry{
+		Version:   keyRingVersion,
+		ID:        uuid.NewString(),
+		Type:      typ,
+		KeyID:     keyID(key),
+		PublicKey: pub,
+		CreatedAt: time.Now().UTC(),
+	}
+	e.Crypto.Cipher = "aes-256-gcm"
+	e.Crypto.KDF = "argon2id"
+	e.Crypto.KDFParams.Salt = hex.EncodeToString(salt)
+	e.Crypto.KDFParams.Time = argonTime
+	e.Crypto.KDFParams.Memory = argonMemory
+	e.Crypto.KDFParams.Threads = argonThreads
+	e.Crypto.KDFParams.DKLen = KeySize
+
+	sealing := e.kdf().derive(passphrase)
+	defer clear(sealing)
+	sealed, err := sealAESGCM(sealing, key, e.associatedData())
+	if err != nil {
+		return nil, err
+	}
+	e.Crypto.CipherParams.Nonce = hex.EncodeToString(sealed[:gcmNonceSize])
+	e.Crypto.CipherText = hex.EncodeToString(sealed[gcmNonceSize:])
+	return e, nil
+}
+
+// open unseals the entry and checks that it holds the key it describes.
+func (e *keyEntry) open(passphrase string) ([]byte, error) {
+	nonce, err := hex.DecodeString(e.Crypto.CipherParams.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: invalid nonce", e.Name)
+	}
+	ciphertext, err := hex.DecodeString(e.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: invalid ciphertext", e.Name)
+	}
+
+	sealing := e.kdf().derive(passphrase)
+	defer clear(sealing)
+	key, err := openAESGCM(sealing, append(nonce, ciphertext...), e.associatedData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if keyID(key) != e.KeyID {
+		clear(key)
+		return nil, fmt.Errorf("key %s does not match its key ID", e.Name)
+	}
+	return key, nil
+}
+
+// check rejects entries this version cannot open.
+func (e *keyEntry) check() error {
+	switch {
+	case e.Version != keyRingVersion:
+		return fmt.Errorf("unsupported key %q (version %d)", e.Name, e.Version)
+	case e.Crypto.Cipher != "aes-256-gcm" || e.Crypto.KDF != "argon2id":
+		return fmt.Errorf("unsupported key %q (cipher %q, kdf %q)", e.Name, e.Crypto.Cipher, e.Crypto.KDF)
+	case e.Crypto.KDFParams.DKLen != KeySize:
+		return fmt.Errorf("unsupported key %q (dklen %d)", e.Name, e.Crypto.KDFParams.DKLen)
+	}
+	return nil
+}
+
+func (e *keyEntry) kdf() kdfParams {
+	salt, _ := hex.DecodeString(e.Crypto.KDFParams.Salt)
+	p := e.Crypto.KDFParams
+	return kdfParams{Name: e.Crypto.KDF, Salt: salt, Time: p.Time, Memory: p.Memory, Threads: p.Threads}
+}
+
+// associatedData binds the sealed key to the entry's ID, type and key ID,
+// but not to its name, so that a key can be imported under another.
+func (e *keyEntry) associatedData() []byte {
+	return bytes.Join([][]byte{[]byte(e.ID), []byte(e.Type), []byte(e.KeyID)}, []byte{0})
+}
+
+func (e *keyEntry) info() KeyInfo {
+	return KeyInfo{
+		Name:      e.Name,
+		Type:      e.Type,
+		ID:        e.ID,
+		KeyID:     e.KeyID,
+		PublicKey: e.PublicKey,
+		CreatedAt: e.CreatedAt,
+		UsedAt:    e.UsedAt,
+	}
+}
+
+// keyPublic checks that key suits typ and returns its public half, if it
+// has one.
+func keyPublic(typ KeyType, key []byte) (string, error) {
+	switch typ {
+	case KeyTypeVaultMaster:
+		if len(key) != KeySize {
+			return "", fmt.Errorf("vault master key must be %d bytes", KeySize)
+		}
+		return "", nil
+	case KeyTypeHolder, KeyTypePeer:
+		if len(key) != ed25519.SeedSize {
+			return "", fmt.Errorf("%s key must be a %d-byte Ed25519 seed", typ, ed25519.SeedSize)
+		}
+		return hex.EncodeToString(ed25519.NewKeyFromSeed(key).Public().(ed25519.PublicKey)), nil
+	}
+	return "", fmt.Errorf("unknown key type %q", typ)
+}
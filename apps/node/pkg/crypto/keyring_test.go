@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testKeyRing(t *testing.T) *KeyRing {
+	t.Helper()
+	r, err := OpenKeyRing(filepath.Join(t.TempDir(), "keys", "keyring.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestKeyRingPutOpen(t *testing.T) {
+	r := testKeyRing(t)
+	master := bytes.Repeat([]byte{7}, 32)
+	if err := r.Put("vault", KeyTypeVaultMaster, master, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, typ, err := r.Open("vault", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != KeyTypeVaultMaster || !bytes.Equal(got, master) {
+		t.Fatalf("opened %s key %x", typ, got)
+	}
+	if info, _ := r.Info("vault"); info.UsedAt == nil {
+		t.Fatal("opening the key did not record its use")
+	}
+
+	if _, _, err := r.Open("vault", "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+	if _, _, err := r.Open("missing", "correct horse"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("missing key: got %v, want ErrKeyNotFound", err)
+	}
+
+	// The ring reads back from disk.
+	again, err := OpenKeyRing(r.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := again.Open("vault", "correct horse"); err != nil || !bytes.Equal(got, master) {
+		t.Fatalf("reopened ring gave %x (%v)", got, err)
+	}
+}
+
+func TestKeyRingTampered(t *testing.T) {
+	r := testKeyRing(t)
+	if err := r.Put("holder", KeyTypeHolder, bytes.Repeat([]byte{9}, 32), "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.Export("holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Relabelling the key as another type breaks its associated data.
+	var e map[string]any
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatal(err)
+	}
+	e["type"] = string(KeyTypePeer)
+	relabelled, _ := json.Marshal(e)
+	if _, _, err := OpenKeyFile(relabelled, "correct horse"); err == nil {
+		t.Fatal("relabelled key opened")
+	}
+}
+
+func TestKeyRingExportImport(t *testing.T) {
+	src, dst := testKeyRing(t), testKeyRing(t)
+	seed := bytes.Repeat([]byte{3}, 32)
+	if err := src.Put("holder", KeyTypeHolder, seed, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := src.Export("holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := OpenKeyFile(data, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+	info, key, err := OpenKeyFile(data, "correct horse")
+	if err != nil || !bytes.Equal(key, seed) || info.Type != KeyTypeHolder {
+		t.Fatalf("key file opened to %+v %x (%v)", info, key, err)
+	}
+
+	if _, err := dst.Import(data, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Import(data, "", false); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("second import: got %v, want ErrKeyExists", err)
+	}
+	if _, err := dst.Import(data, "old-holder", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := dst.Open("old-holder", "correct horse"); err != nil || !bytes.Equal(got, seed) {
+		t.Fatalf("imported key opened to %x (%v)", got, err)
+	}
+
+	if err := dst.Delete("holder"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dst.List()) != 1 {
+		t.Fatalf("ring holds %d keys after delete, want 1", len(dst.List()))
+	}
+}
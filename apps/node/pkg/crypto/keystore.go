@@ -0,0 +1,557 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	// ErrVaultLocked is returned while the master key is sealed.
+	ErrVaultLocked = errors.New("vault is locked")
+	// ErrWrongPassphrase is returned when the passphrase does not open the
+	// keystore.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrWeakPassphrase is returned for passphrases shorter than
+	// MinPassphraseLength.
+	ErrWeakPassphrase = fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	// ErrRotationInProgress is returned when a rotation is started before
+	// the previous one has moved every document key.
+	ErrRotationInProgress = errors.New("a key rotation is already in progress")
+)
+
+// KeySize is the size of the master key and of every document key.
+const KeySize = 32
+
+// MinPassphraseLength is the shortest passphrase a vault can be sealed with.
+const MinPassphraseLength = 8
+
+const keystoreVersion = 1
+
+// Argon2id parameters for new keystores; existing keystores keep the ones
+// they were sealed with.
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonSaltLen = 16
+)
+
+// Keystore holds the node's vault master key, sealed under the holder's
+// passphrase. Each document is encrypted with its own key, which is stored
+// wrapped by the master key, so the master key never touches document data.
+//
+// During a rotation the keystore holds two master keys: the new one, which
+// wraps all new document keys, and the retiring one, which still opens the
+// document keys not moved yet. Both are sealed under the same passphrase, so
+// a rotation interrupted by a crash or a lock resumes after the next unlock.
+type Keystore struct {
+	path string
+
+	mu       sync.RWMutex
+	file     *keystoreFile // nil until the first unlock creates it
+	master   []byte        // nil while locked
+	retiring []byte        // nil while locked or not rotating
+}
+
+// keystoreFile is the on-disk keystore.
+type keystoreFile struct {
+	Version int `json:"version"`
+	// KeyID identifies the master key without revealing it.
+	KeyID     string    `json:"key_id"`
+	KDF       kdfParams `json:"kdf"`
+	Nonce     []byte    `json:"nonce"`
+	SealedKey []byte    `json:"sealed_key"`
+	CreatedAt time.Time `json:"created_at"`
+	// Retiring is the previous master key while a rotation is in progress.
+	Retiring *sealedKey `json:"retiring,omitempty"`
+}
+
+// sealedKey is a master key sealed under the keystore passphrase.
+type sealedKey struct {
+	KeyID     string `json:"key_id"`
+	Nonce     []byte `json:"nonce"`
+	SealedKey []byte `json:"sealed_key"`
+}
+
+// kdfParams are the Argon2id parameters a keystore was sealed with.
+type kdfParams struct {
+	Name    string `json:"name"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// OpenKeystore reads the keystore at path, if it exists. The vault starts
+// locked either way.
+func OpenKeystore(path string) (*Keystore, error) {
+	k := &Keystore{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var f keystoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore %s: %w", path, err)
+	}
+	if f.Version != keystoreVersion || f.KDF.Name != "argon2id" {
+		return nil, fmt.Errorf("unsupported keystore %s (version %d, kdf %q)", path, f.Version, f.KDF.Name)
+	}
+	k.file = &f
+	return k, nil
+}
+
+// Initialized reports whether a master key exists yet.
+func (k *Keystore) Initialized() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.file != nil
+}
+
+// Unlocked reports whether the master key is available.
+func (k *Keystore) Unlocked() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.master != nil
+}
+
+// KeyID identifies the master key, empty before the first unlock.
+func (k *Keystore) KeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.file == nil {
+		return ""
+	}
+	return k.file.KeyID
+}
+
+// Unlock opens the master key with passphrase. The first unlock creates the
+// master key and seals it under passphrase; it reports created.
+func (k *Keystore) Unlock(passphrase string) (created bool, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.file == nil {
+		master, f, err := newKeystoreFile(passphrase)
+		if err != nil {
+			return false, err
+		}
+		if err := writeKeystoreFile(k.path, f); err != nil {
+			return false, err
+		}
+		k.file, k.master = f, master
+		return true, nil
+	}
+
+	sealing := k.file.KDF.derive(passphrase)
+	defer clear(sealing)
+	master, err := k.file.open(sealing)
+	if err != nil {
+		return false, err
+	}
+	var retiring []byte
+	if k.file.Retiring != nil {
+		if retiring, err = k.file.Retiring.open(sealing); err != nil {
+			clear(master)
+			return false, err
+		}
+	}
+	// Unlocking an unlocked vault replaces the copies already open.
+	clear(k.master)
+	clear(k.retiring)
+	k.master, k.retiring = master, retiring
+	return false, nil
+}
+
+// Lock forgets the master keys until the next unlock.
+func (k *Keystore) Lock() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	clear(k.master)
+	clear(k.retiring)
+	k.master, k.retiring = nil, nil
+}
+
+// ChangePassphrase seals the master keys under newPassphrase with a fresh
+// salt. The keys themselves, and so every document, stay as they are.
+func (k *Keystore) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	master, retiring, err := k.openAll(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	defer clear(master)
+	defer clear(retiring)
+
+	f, err := sealKeystoreFile(newPassphrase, master, retiring, k.file.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := writeKeystoreFile(k.path, f); err != nil {
+		return err
+	}
+	k.file = f
+	return nil
+}
+
+// BeginRotation replaces the master key with a new one and seals both under
+// newPassphrase; the old key is kept as the retiring key until FinishRotation.
+// The vault must be unlocked. record is called with both key IDs before the
+// keystore is written, so that the caller can persist the rotation first; if
+// it fails nothing changes.
+func (k *Keystore) BeginRotation(oldPassphrase, newPassphrase string, record func(fromKeyID, toKeyID string) error) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.master == nil {
+		return ErrVaultLocked
+	}
+	if k.file.Retiring != nil {
+		return ErrRotationInProgress
+	}
+	old, _, err := k.openAll(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	master := make([]byte, KeySize)
+	if _, err := rand.Read(master); err != nil {
+		clear(old)
+		return fmt.Errorf("failed to generate master key: %w", err)
+	}
+	f, err := sealKeystoreFile(newPassphrase, master, old, time.Now().UTC())
+	if err == nil {
+		err = record(f.Retiring.KeyID, f.KeyID)
+	}
+	if err == nil {
+		err = writeKeystoreFile(k.path, f)
+	}
+	if err != nil {
+		clear(old)
+		clear(master)
+		return err
+	}
+
+	clear(k.master)
+	k.file, k.master, k.retiring = f, master, old
+	return nil
+}
+
+// RetiringKeyID is the ID of the master key a rotation is moving away from,
+// empty when no rotation is in progress.
+func (k *Keystore) RetiringKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.file == nil || k.file.Retiring == nil {
+		return ""
+	}
+	return k.file.Retiring.KeyID
+}
+
+// FinishRotation drops the retiring master key. Call it once no document key
+// is wrapped by it any more.
+func (k *Keystore) FinishRotation() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.file == nil || k.file.Retiring == nil {
+		return nil
+	}
+
+	f := *k.file
+	f.Retiring = nil
+	if err := writeKeystoreFile(k.path, &f); err != nil {
+		return err
+	}
+	k.file = &f
+	clear(k.retiring)
+	k.retiring = nil
+	return nil
+}
+
+// ExportMasterKey opens the master key with passphrase and returns a copy,
+// for the key ring. It fails during a rotation, as the retiring key would be
+// left behind.
+func (k *Keystore) ExportMasterKey(passphrase string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.file != nil && k.file.Retiring != nil {
+		return nil, ErrRotationInProgress
+	}
+	master, _, err := k.openAll(passphrase)
+	return master, err
+}
+
+// ImportMasterKey seals master, exported from another node, under
+// passphrase and unlocks the vault with it. A vault that already has a
+// different master key, or is in the middle of a rotation, is left alone.
+func (k *Keystore) ImportMasterKey(master []byte, passphrase string) error {
+	if len(master) != KeySize {
+		return fmt.Errorf("master key must be %d bytes", KeySize)
+	}
+	return k.install(append([]byte{}, master...), passphrase)
+}
+
+// install makes master, which it takes ownership of, the vault's only master
+// key, sealed under passphrase. It refuses during a rotation: dropping the
+// retiring key would strand the document keys it still wraps.
+func (k *Keystore) install(master []byte, passphrase string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.file != nil && k.file.Retiring != nil {
+		clear(master)
+		return ErrRotationInProgress
+	}
+	if id := keyID(master); k.file != nil && k.file.KeyID != id {
+		clear(master)
+		return fmt.Errorf("this vault already has master key %s", k.file.KeyID)
+	}
+	f, err := sealKeystoreFile(passphrase, master, nil, time.Now().UTC())
+	if err != nil {
+		clear(master)
+		return err
+	}
+	if err := writeKeystoreFile(k.path, f); err != nil {
+		clear(master)
+		return err
+	}
+	clear(k.master)
+	clear(k.retiring)
+	k.file, k.master, k.retiring = f, master, nil
+	return nil
+}
+
+// NewDocumentKey creates a key for the document id and returns it along with
+// its wrapped form and the ID of the master key that wrapped it, all to be
+// stored with the document.
+func (k *Keystore) NewDocumentKey(id string) (key, wrapped []byte, keyID string, err error) {
+	key = make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to generate document key: %w", err)
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.master == nil {
+		return nil, nil, "", ErrVaultLocked
+	}
+	wrapped, err = sealAESGCM(k.master, key, []byte(id))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return key, wrapped, k.file.KeyID, nil
+}
+
+// DocumentKey unwraps the key of the document id, wrapped by master key
+// keyID. The wrapping is bound to id, so a wrapped key copied to another
+// document does not open.
+func (k *Keystore) DocumentKey(id, keyID string, wrapped []byte) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	master, err := k.masterKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := openAESGCM(master, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap document key: %w", err)
+	}
+	return key, nil
+}
+
+// RewrapDocumentKey moves the key of the document id from master key keyID to
+// the current one, returning the new wrapped key and its master key ID.
+func (k *Keystore) RewrapDocumentKey(id, keyID string, wrapped []byte) ([]byte, string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	master, err := k.masterKey(keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := openAESGCM(master, wrapped, []byte(id))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to unwrap document key: %w", err)
+	}
+	defer clear(key)
+
+	rewrapped, err := sealAESGCM(k.master, key, []byte(id))
+	if err != nil {
+		return nil, "", err
+	}
+	return rewrapped, k.file.KeyID, nil
+}
+
+// masterKey returns the unlocked master key keyID; empty means the current
+// one. Callers hold k.mu.
+func (k *Keystore) masterKey(keyID string) ([]byte, error) {
+	if k.master == nil {
+		return nil, ErrVaultLocked
+	}
+	switch {
+	case keyID == "" || keyID == k.file.KeyID:
+		return k.master, nil
+	case k.file.Retiring != nil && keyID == k.file.Retiring.KeyID:
+		return k.retiring, nil
+	}
+	return nil, fmt.Errorf("unknown master key %s", keyID)
+}
+
+// openAll opens every master key in the keystore with passphrase. Callers
+// hold k.mu.
+func (k *Keystore) openAll(passphrase string) (master, retiring []byte, err error) {
+	if k.file == nil {
+		return nil, nil, fmt.Errorf("vault has no master key yet")
+	}
+	sealing := k.file.KDF.derive(passphrase)
+	defer clear(sealing)
+
+	if master, err = k.file.open(sealing); err != nil {
+		return nil, nil, err
+	}
+	if k.file.Retiring != nil {
+		if retiring, err = k.file.Retiring.open(sealing); err != nil {
+			clear(master)
+			return nil, nil, err
+		}
+	}
+	return master, retiring, nil
+}
+
+// newKeystoreFile creates a master key and seals it under passphrase.
+func newKeystoreFile(passphrase string) ([]byte, *keystoreFile, error) {
+	master := make([]byte, KeySize)
+	if _, err := rand.Read(master); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	f, err := sealKeystoreFile(passphrase, master, nil, time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	return master, f, nil
+}
+
+// sealKeystoreFile seals master, and retiring if not nil, under passphrase
+// with a random salt.
+func sealKeystoreFile(passphrase string, master, retiring []byte, createdAt time.Time) (*keystoreFile, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, ErrWeakPassphrase
+	}
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	f := &keystoreFile{
+		Version: keystoreVersion,
+		KDF: kdfParams{
+			Name:    "argon2id",
+			Salt:    salt,
+			Time:    argonTime,
+			Memory:  argonMemory,
+			Threads: argonThreads,
+		},
+		CreatedAt: createdAt,
+	}
+	sealing := f.KDF.derive(passphrase)
+	defer clear(sealing)
+
+	current, err := sealMasterKey(sealing, master)
+	if err != nil {
+		return nil, err
+	}
+	f.KeyID, f.Nonce, f.SealedKey = current.KeyID, current.Nonce, current.SealedKey
+	if retiring != nil {
+		if f.Retiring, err = sealMasterKey(sealing, retiring); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func sealMasterKey(sealing, master []byte) (*sealedKey, error) {
+	id := keyID(master)
+	sealed, err := sealAESGCM(sealing, master, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+	return &sealedKey{KeyID: id, Nonce: sealed[:gcmNonceSize], SealedKey: sealed[gcmNonceSize:]}, nil
+}
+
+// open opens the current master key with the key derived from the
+// passphrase.
+func (f *keystoreFile) open(sealing []byte) ([]byte, error) {
+	return (&sealedKey{KeyID: f.KeyID, Nonce: f.Nonce, SealedKey: f.SealedKey}).open(sealing)
+}
+
+func (s *sealedKey) open(sealing []byte) ([]byte, error) {
+	sealed := append(append([]byte{}, s.Nonce...), s.SealedKey...)
+	master, err := openAESGCM(sealing, sealed, []byte(s.KeyID))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return master, nil
+}
+
+func (p kdfParams) derive(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), p.Salt, p.Time, p.Memory, p.Threads, KeySize)
+}
+
+// keyID is a short public identifier of a key.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("lairik-pulse-key-id:"), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// writeKeystoreFile atomically replaces the keystore at path.
+func writeKeystoreFile(path string, f *keystoreFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keystore: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data, readable by the owner
+// only, so that a crash leaves either the old file or the new one.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testKeystore(t *testing.T) *Keystore {
+	t.Helper()
+	k, err := OpenKeystore(filepath.Join(t.TempDir(), "keystore.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeystoreWrongPassphrase(t *testing.T) {
+	k := testKeystore(t)
+	if created, err := k.Unlock("correct horse"); err != nil || !created {
+		t.Fatalf("first unlock: created %v, %v", created, err)
+	}
+	k.Lock()
+
+	if _, err := k.Unlock("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("unlock: got %v, want ErrWrongPassphrase", err)
+	}
+	if k.Unlocked() {
+		t.Fatal("vault unlocked with a wrong passphrase")
+	}
+	if err := k.ChangePassphrase("wrong horse", "battery staple"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("change passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := k.ExportMasterKey("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("export: got %v, want ErrWrongPassphrase", err)
+	}
+
+	// The keystore on disk opens with the right passphrase only.
+	reopened, err := OpenKeystore(k.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeystoreUnlockClearsPreviousKey(t *testing.T) {
+	k := testKeystore(t)
+	if _, err := k.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	previous := k.master
+	if _, err := k.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(previous, make([]byte, KeySize)) {
+		t.Fatal("unlocking again left the previous master key in memory")
+	}
+}
+
+func TestKeystoreRotationResumes(t *testing.T) {
+	k := testKeystore(t)
+	if _, err := k.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	key, wrapped, oldKeyID, err := k.NewDocumentKey("doc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var from, to string
+	err = k.BeginRotation("correct horse", "battery staple", func(fromKeyID, toKeyID string) error {
+		from, to = fromKeyID, toKeyID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != oldKeyID || to != k.KeyID() || k.RetiringKeyID() != oldKeyID {
+		t.Fatalf("rotation from %s to %s, retiring %s, current %s", from, to, k.RetiringKeyID(), k.KeyID())
+	}
+	if err := k.BeginRotation("battery staple", "battery staple", func(string, string) error { return nil }); !errors.Is(err, ErrRotationInProgress) {
+		t.Fatalf("second rotation: got %v, want ErrRotationInProgress", err)
+	}
+
+	// Interrupted: the node stops before any document key moved. After a
+	// restart the new passphrase opens both master keys.
+	k.Lock()
+	k, err = OpenKeystore(k.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Unlock("correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("old passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := k.Unlock("battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if k.RetiringKeyID() != oldKeyID {
+		t.Fatalf("retiring key %q after restart, want %s", k.RetiringKeyID(), oldKeyID)
+	}
+	if _, err := k.ExportMasterKey("battery staple"); !errors.Is(err, ErrRotationInProgress) {
+		t.Fatalf("export: got %v, want ErrRotationInProgress", err)
+	}
+	if _, err := k.SplitMasterKey("battery staple", 2, 3); !errors.Is(err, ErrRotationInProgress) {
+		t.Fatalf("split: got %v, want ErrRotationInProgress", err)
+	}
+
+	// Importing a master key now would drop the retiring key and strand
+	// the document keys it still wraps.
+	master := bytes.Repeat([]byte{9}, KeySize)
+	if err := k.ImportMasterKey(master, "battery staple"); !errors.Is(err, ErrRotationInProgress) {
+		t.Fatalf("import: got %v, want ErrRotationInProgress", err)
+	}
+
+	rewrapped, newKeyID, err := k.RewrapDocumentKey("doc-1", oldKeyID, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKeyID != to {
+		t.Fatalf("rewrapped under %s, want %s", newKeyID, to)
+	}
+	if err := k.FinishRotation(); err != nil {
+		t.Fatal(err)
+	}
+	if k.RetiringKeyID() != "" {
+		t.Fatal("retiring key kept after the rotation finished")
+	}
+	if _, err := k.DocumentKey("doc-1", oldKeyID, wrapped); err == nil {
+		t.Fatal("document key opened under the retired master key")
+	}
+	got, err := k.DocumentKey("doc-1", newKeyID, rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Fatal("rewrapped document key differs")
+	}
+}
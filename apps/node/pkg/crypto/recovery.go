@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// recoveryShareTextPrefix marks the text form of a recovery share: its binary
+// form in unpadded base32, which fits a QR code's alphanumeric mode and can
+// be typed in from paper.
+const recoveryShareTextPrefix = "LPS1:"
+
+const (
+	recoveryShareVersion = 1
+	recoverySetIDSize    = 8
+	recoveryKeyIDSize    = 8
+)
+
+var recoveryShareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryShare is one of the shares the vault master key is split into for
+// social recovery. Shares of one split share a SetID; any Threshold of them
+// rebuild the master key KeyID.
+type RecoveryShare struct {
+	SetID     string `json:"set_id"`
+	KeyID     string `json:"key_id"`
+	Threshold int    `json:"threshold"`
+	Total     int    `json:"total"`
+	// Index is the share's x coordinate, from 1 to Total.
+	Index int    `json:"index"`
+	Value []byte `json:"-"`
+}
+
+// Text encodes the share for QR codes, paper and the mesh. It ends in a
+// checksum, so a mistyped or misread share is rejected rather than
+// rebuilding a wrong key.
+func (s *RecoveryShare) Text() (string, error) {
+	setID, err := hex.DecodeString(s.SetID)
+	if err != nil || len(setID) != recoverySetIDSize {
+		return "", fmt.Errorf("invalid share set ID %q", s.SetID)
+	}
+	keyID, err := hex.DecodeString(s.KeyID)
+	if err != nil || len(keyID) != recoveryKeyIDSize {
+		return "", fmt.Errorf("invalid share key ID %q", s.KeyID)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(recoveryShareVersion)
+	buf.Write(setID)
+	buf.Write(keyID)
+	buf.Write([]byte{byte(s.Threshold), byte(s.Total), byte(s.Index)})
+	buf.Write(s.Value)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return recoveryShareTextPrefix + recoveryShareEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ParseRecoveryShare decodes the text form of a share. Whitespace and case
+// are ignored.
+func ParseRecoveryShare(text string) (*RecoveryShare, error) {
+	text = strings.ToUpper(strings.Join(strings.Fields(text), ""))
+	if !strings.HasPrefix(text, recoveryShareTextPrefix) {
+		return nil, errors.New("not a recovery share")
+	}
+	raw, err := recoveryShareEncoding.DecodeString(strings.TrimPrefix(text, recoveryShareTextPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recovery share: %w", err)
+	}
+
+	const header = 1 + recoverySetIDSize + recoveryKeyIDSize + 3
+	if len(raw) < header+1+4 {
+		return nil, errors.New("recovery share is truncated")
+	}
+	body, sum := raw[:len(raw)-4], binary.BigEndian.Uint32(raw[len(raw)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, errors.New("recovery share checksum mismatch; check it was copied correctly")
+	}
+	if body[0] != recoveryShareVersion {
+		return nil, fmt.Errorf("unsupported recovery share version %d", body[0])
+	}
+
+	s := &RecoveryShare{
+		SetID:     hex.EncodeToString(body[1 : 1+recoverySetIDSize]),
+		KeyID:     hex.EncodeToString(body[1+recoverySetIDSize : 1+recoverySetIDSize+recoveryKeyIDSize]),
+		Threshold: int(body[header-3]),
+		Total:     int(body[header-2]),
+		Index:     int(body[header-1]),
+		Value:     append([]byte{}, body[header:]...),
+	}
+	if s.Threshold < 2 || s.Total < s.Threshold || s.Index < 1 || s.Index > s.Total {
+		return nil, errors.New("recovery share has invalid parameters")
+	}
+	return s, nil
+}
+
+// SplitMasterKey splits the current master key into total shares, any
+// threshold of which rebuild it. passphrase must open the keystore. Shares
+// are tied to the master key: after a rotation, split again.
+func (k *Keystore) SplitMasterKey(passphrase string, threshold, total int) ([]RecoveryShare, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.file != nil && k.file.Retiring != nil {
+		return nil, ErrRotationInProgress
+	}
+	master, _, err := k.openAll(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(master)
+
+	values, err := SplitSecret(master, threshold, total)
+	if err != nil {
+		return nil, err
+	}
+	setID := make([]byte, recoverySetIDSize)
+	if _, err := rand.Read(setID); err != nil {
+		return nil, fmt.Errorf("failed to generate share set ID: %w", err)
+	}
+
+	shares := make([]RecoveryShare, total)
+	for i, v := range values {
+		shares[i] = RecoveryShare{
+			SetID:     hex.EncodeToString(setID),
+			KeyID:     k.file.KeyID,
+			Threshold: threshold,
+			Total:     total,
+			Index:     int(v[0]),
+			Value:     v[1:],
+		}
+	}
+	return shares, nil
+}
+
+// Recover rebuilds the master key from shares of one split and seals it
+// under a new passphrase, leaving the vault unlocked. On a device whose
+// keystore holds another master key it fails; on the original device it
+// resets a forgotten passphrase.
+func (k *Keystore) Recover(shares []RecoveryShare, passphrase string) error {
+	if len(shares) == 0 {
+		return errors.New("no recovery shares")
+	}
+	first := shares[0]
+	values := make([][]byte, len(shares))
+	for i, s := range shares {
+		if s.SetID != first.SetID || s.KeyID != first.KeyID {
+			return errors.New("recovery shares come from different splits")
+		}
+		values[i] = append([]byte{byte(s.Index)}, s.Value...)
+	}
+	if len(shares) < first.Threshold {
+		return fmt.Errorf("need %d recovery shares, have %d", first.Threshold, len(shares))
+	}
+
+	master, err := CombineShares(values)
+	if err != nil {
+		return err
+	}
+	if keyID(master) != first.KeyID {
+		clear(master)
+		return errors.New("recovery shares do not rebuild the master key; one of them is wrong")
+	}
+
+	return k.install(master, passphrase)
+}
+
+// Seal encrypts data under the current master key, bound to label, for
+// vault records that leave the node, such as the recovery manifest.
+func (k *Keystore) Seal(data []byte, label string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.master == nil {
+		return nil, ErrVaultLocked
+	}
+	return sealAESGCM(k.master, data, []byte(label))
+}
+
+// Open reverses Seal.
+func (k *Keystore) Open(sealed []byte, label string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.master == nil {
+		return nil, ErrVaultLocked
+	}
+	data, err := openAESGCM(k.master, sealed, []byte(label))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed record: %w", err)
+	}
+	return data, nil
+}
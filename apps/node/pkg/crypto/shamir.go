@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Shamir secret sharing over GF(2^8), byte by byte: each byte of the secret
+// is the constant term of its own random polynomial of degree threshold-1,
+// and share i holds every polynomial evaluated at x = i. Any threshold
+// shares rebuild the secret by Lagrange interpolation at x = 0; fewer reveal
+// nothing about it.
+
+// MaxShares is the most shares a secret can be split into.
+const MaxShares = 255
+
+// SplitSecret splits secret into total shares, any threshold of which
+// rebuild it. Share i (from 1) is returned at index i-1 as x||f(x).
+func SplitSecret(secret []byte, threshold, total int) ([][]byte, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, errors.New("secret is empty")
+	case threshold < 2:
+		return nil, errors.New("threshold must be at least 2")
+	case total < threshold:
+		return nil, errors.New("total must be at least the threshold")
+	case total > MaxShares:
+		return nil, fmt.Errorf("total must be at most %d", MaxShares)
+	}
+
+	shares := make([][]byte, total)
+	for i := range shares {
+		shares[i] = make([]byte, 1+len(secret))
+		shares[i][0] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, threshold)
+	defer clear(coeffs)
+	for j, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+		for _, share := range shares {
+			share[1+j] = gfEval(coeffs, share[0])
+		}
+	}
+	return shares, nil
+}
+
+// CombineShares rebuilds a secret from at least threshold distinct shares
+// made by SplitSecret. With fewer, or shares from different splits, it
+// returns a wrong secret; callers check the result.
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are needed")
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("share is empty")
+	}
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("shares differ in length")
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, fmt.Errorf("invalid or repeated share index %d", share[0])
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for i, si := range shares {
+		// Lagrange basis polynomial for share i, evaluated at 0.
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj[0], sj[0]^si[0]))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(basis, si[1+k])
+		}
+	}
+	return secret, nil
+}
+
+// gfEval evaluates the polynomial with coefficients coeffs, constant term
+// first, at x.
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1, through log and
+// exp tables over the generator 3.
+var gfExp, gfLog = gfTables()
+
+func gfTables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = x, x
+		log[x] = byte(i)
+		// x *= 3
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, log
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfDiv divides a by b, which must not be 0.
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
@@ -0,0 +1,97 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShamirThreshold(t *testing.T) {
+	secret := []byte("32-byte vault master key .......")
+	shares, err := SplitSecret(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every choice of 3 of the 5 shares rebuilds the secret.
+	for a := 0; a < 5; a++ {
+		for b := a + 1; b < 5; b++ {
+			for c := b + 1; c < 5; c++ {
+				got, err := CombineShares([][]byte{shares[a], shares[b], shares[c]})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, secret) {
+					t.Fatalf("shares %d,%d,%d rebuilt %x", a+1, b+1, c+1, got)
+				}
+			}
+		}
+	}
+
+	all, err := CombineShares(shares)
+	if err != nil || !bytes.Equal(all, secret) {
+		t.Fatalf("all shares rebuilt %x (%v)", all, err)
+	}
+}
+
+func TestShamirBelowThreshold(t *testing.T) {
+	secret := []byte("32-byte vault master key .......")
+	shares, err := SplitSecret(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := CombineShares(shares[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, secret) {
+		t.Fatal("2 shares of a 3-of-5 split rebuilt the secret")
+	}
+}
+
+func TestShamirInvalid(t *testing.T) {
+	secret := []byte("secret")
+	for _, tc := range []struct{ threshold, total int }{{1, 3}, {4, 3}, {2, 256}} {
+		if _, err := SplitSecret(secret, tc.threshold, tc.total); err == nil {
+			t.Errorf("split %d of %d accepted", tc.threshold, tc.total)
+		}
+	}
+
+	shares, err := SplitSecret(secret, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CombineShares([][]byte{shares[0], shares[0]}); err == nil {
+		t.Error("repeated share accepted")
+	}
+	if _, err := CombineShares([][]byte{shares[0], shares[1][:3]}); err == nil {
+		t.Error("truncated share accepted")
+	}
+}
+
+func TestRecoveryShareText(t *testing.T) {
+	share := RecoveryShare{
+		SetID:     "8899aabbccddeeff",
+		KeyID:     "0011223344556677",
+		Threshold: 2,
+		Total:     3,
+		Index:     2,
+		Value:     []byte{1, 2, 3, 4, 5},
+	}
+	text, err := share.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseRecoveryShare(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SetID != share.SetID || got.KeyID != share.KeyID || got.Index != share.Index || !bytes.Equal(got.Value, share.Value) {
+		t.Fatalf("share round-tripped to %+v", got)
+	}
+
+	typo := []byte(text)
+	typo[len(typo)-3] ^= 1
+	if _, err := ParseRecoveryShare(string(typo)); err == nil {
+		t.Fatal("mistyped share accepted")
+	}
+}
@@ -0,0 +1,276 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Stream envelopes seal large documents in fixed-size chunks with the STREAM
+// construction, so neither side holds the whole document in memory:
+//
+//	magic | version | AlgAES256GCMStream | key ID (8) | nonce prefix (7) |
+//	chunk size (4) | chunk 0 | chunk 1 | ... | final chunk
+//
+// Chunk i is sealed under the nonce prefix || i (4) || final flag (1), so
+// chunks cannot be reordered, dropped, or the stream cut short without the
+// next chunk, or the end, failing to open. Every chunk is bound to the header
+// and the document ID; the final chunk also to the document hash, which the
+// sealer computes as the plaintext goes by.
+
+// AlgAES256GCMStream is AES-256-GCM in the STREAM construction.
+const AlgAES256GCMStream EnvelopeAlgorithm = 2
+
+const (
+	// StreamChunkSize is the plaintext size of every chunk but the last.
+	StreamChunkSize = 64 << 10
+
+	streamNoncePrefixSize = 7
+	// maxStreamChunkSize bounds the chunk size a reader accepts.
+	maxStreamChunkSize = 16 << 20
+)
+
+var streamHeaderSize = envelopeHeaderSize + streamNoncePrefixSize + 4
+
+// StreamWriter seals what is written to it into a stream envelope. Close
+// writes the final chunk; the envelope is incomplete until it does.
+type StreamWriter struct {
+	dst    io.Writer
+	gcm    cipher.AEAD
+	header []byte
+	docID  string
+	buf    []byte
+	out    []byte
+	nonce  []byte
+	chunk  uint32
+	sum    hash.Hash
+	size   int64
+	closed bool
+}
+
+// SealStream starts a stream envelope for document documentID on dst.
+func (e *EncryptionService) SealStream(dst io.Writer, documentID string) (*StreamWriter, error) {
+	gcm, err := newGCM(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, streamHeaderSize)
+	header = append(header, envelopeMagic...)
+	header = append(header, envelopeVersion, byte(AlgAES256GCMStream))
+	header = append(header, e.keyID()...)
+	prefix := make([]byte, streamNoncePrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header = append(header, prefix...)
+	header = binary.BigEndian.AppendUint32(header, StreamChunkSize)
+
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+	return &StreamWriter{
+		dst:    dst,
+		gcm:    gcm,
+		header: header,
+		docID:  documentID,
+		buf:    make([]byte, 0, StreamChunkSize),
+		out:    make([]byte, 0, StreamChunkSize+gcm.Overhead()),
+		nonce:  append(append([]byte{}, prefix...), 0, 0, 0, 0, 0),
+		sum:    sha256.New(),
+	}, nil
+}
+
+// Write buffers p and seals every chunk it fills. A full chunk is only
+// written once more data follows it, as the last chunk is sealed apart.
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	n := len(p)
+	w.sum.Write(p)
+	w.size += int64(n)
+	for len(p) > 0 {
+		if len(w.buf) == StreamChunkSize {
+			if err := w.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		k := copy(w.buf[len(w.buf):StreamChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Close seals the final chunk, bound to the hash of everything written.
+func (w *StreamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// Sum returns the SHA-256 digest of the plaintext; Hash returns it encoded
+// like Hash. Both are only complete after Close.
+func (w *StreamWriter) Sum() []byte { return w.sum.Sum(nil) }
+
+func (w *StreamWriter) Hash() string { return base64.URLEncoding.EncodeToString(w.Sum()) }
+
+// Size returns how many plaintext bytes were written.
+func (w *StreamWriter) Size() int64 { return w.size }
+
+func (w *StreamWriter) flush(final bool) error {
+	ad := AssociatedData{DocumentID: w.docID}
+	if final {
+		ad.Hash = w.Hash()
+	}
+	streamNonce(w.nonce, w.chunk, final)
+	w.out = w.gcm.Seal(w.out[:0], w.nonce, w.buf, ad.encode(w.header))
+	clear(w.buf)
+	w.buf = w.buf[:0]
+	w.chunk++
+	if w.chunk == 0 {
+		return errors.New("stream too long")
+	}
+	_, err := w.dst.Write(w.out)
+	return err
+}
+
+func streamNonce(nonce []byte, chunk uint32, final bool) {
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixSize:], chunk)
+	nonce[len(nonce)-1] = 0
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+}
+
+// OpenStream decrypts a document envelope read from src, bound to ad. Stream
+// envelopes are decrypted chunk by chunk as the result is read; an error
+// from Read means the content was altered or cut short, and what was read
+// before it must be discarded. Envelopes sealed whole and legacy ciphertext
+// are read in full and opened at once.
+func (e *EncryptionService) OpenStream(src io.Reader, ad AssociatedData) (io.Reader, error) {
+	br := bufio.NewReader(src)
+	header, err := br.Peek(streamHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !IsEnvelope(header) || EnvelopeAlgorithm(header[len(envelopeMagic)+1]) != AlgAES256GCMStream {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := e.Open(data, ad)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(plaintext), nil
+	}
+	if len(header) < streamHeaderSize {
+		return nil, errors.New("stream envelope too short")
+	}
+
+	if version := header[len(envelopeMagic)]; version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", version)
+	}
+	keyID := header[envelopeHeaderSize-envelopeKeyIDSize : envelopeHeaderSize]
+	if !bytes.Equal(keyID, e.keyID()) {
+		return nil, fmt.Errorf("%w %x", ErrEnvelopeKey, keyID)
+	}
+	chunkSize := binary.BigEndian.Uint32(header[streamHeaderSize-4:])
+	if chunkSize == 0 || chunkSize > maxStreamChunkSize {
+		return nil, fmt.Errorf("invalid stream chunk size %d", chunkSize)
+	}
+
+	gcm, err := newGCM(e.key)
+	if err != nil {
+		return nil, err
+	}
+	r := &streamReader{
+		src:    br,
+		gcm:    gcm,
+		header: append([]byte{}, header...),
+		ad:     ad,
+		in:     make([]byte, int(chunkSize)+gcm.Overhead()),
+		nonce:  append(append([]byte{}, header[envelopeHeaderSize:envelopeHeaderSize+streamNoncePrefixSize]...), 0, 0, 0, 0, 0),
+	}
+	br.Discard(streamHeaderSize)
+
+	// Open the first chunk now, so a wrong key or document fails here
+	// rather than on the first Read.
+	if err := r.next(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type streamReader struct {
+	src    *bufio.Reader
+	gcm    cipher.AEAD
+	header []byte
+	ad     AssociatedData
+	in     []byte
+	out    []byte
+	nonce  []byte
+	chunk  uint32
+	final  bool
+	err    error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.final {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// next opens the following chunk. A short chunk, or a full one with nothing
+// after it, must be the final one.
+func (r *streamReader) next() error {
+	n, err := io.ReadFull(r.src, r.in)
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("stream envelope is truncated")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.final = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			r.final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	ad := AssociatedData{DocumentID: r.ad.DocumentID}
+	if r.final {
+		ad.Hash = r.ad.Hash
+	}
+	streamNonce(r.nonce, r.chunk, r.final)
+	out, err := r.gcm.Open(r.in[:0], r.nonce, r.in[:n], ad.encode(r.header))
+	if err != nil {
+		return fmt.Errorf("chunk %d does not open for document %s: %w", r.chunk, r.ad.DocumentID, err)
+	}
+	r.out = out
+	r.chunk++
+	return nil
+}
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+const streamTagSize = 16
+
+func testEncryptionService(t *testing.T, b byte) *EncryptionService {
+	t.Helper()
+	enc, err := NewEncryptionServiceFromKey(bytes.Repeat([]byte{b}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+// sealStream seals data for documentID and returns the envelope and the
+// document hash the sealer computed.
+func sealStream(t *testing.T, enc *EncryptionService, documentID string, data []byte) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := enc.SealStream(&buf, documentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Size() != int64(len(data)) {
+		t.Fatalf("sealed %d bytes, wrote %d", w.Size(), len(data))
+	}
+	return buf.Bytes(), w.Hash()
+}
+
+// openStream reads a stream envelope to the end.
+func openStream(enc *EncryptionService, envelope []byte, ad AssociatedData) ([]byte, error) {
+	r, err := enc.OpenStream(bytes.NewReader(envelope), ad)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// streamChunk returns the bounds of sealed chunk i in an envelope.
+func streamChunk(i int) (start, end int) {
+	size := StreamChunkSize + streamTagSize
+	start = streamHeaderSize + i*size
+	return start, start + size
+}
+
+func testStreamData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	for _, n := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 17} {
+		data := testStreamData(n)
+		envelope, hash := sealStream(t, enc, "doc-1", data)
+		if hash != Hash(data) {
+			t.Fatalf("%d bytes: sealer hash %s, want %s", n, hash, Hash(data))
+		}
+
+		got, err := openStream(enc, envelope, AssociatedData{DocumentID: "doc-1", Hash: hash})
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes: opened content differs", n)
+		}
+	}
+}
+
+func TestStreamTamper(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := testStreamData(2*StreamChunkSize + 100)
+	envelope, hash := sealStream(t, enc, "doc-1", data)
+	ad := AssociatedData{DocumentID: "doc-1", Hash: hash}
+
+	for _, at := range []int{streamHeaderSize + 3, streamHeaderSize + StreamChunkSize + streamTagSize + 5, len(envelope) - 1} {
+		tampered := append([]byte{}, envelope...)
+		tampered[at] ^= 0x01
+		if _, err := openStream(enc, tampered, ad); err == nil {
+			t.Fatalf("byte %d altered: content opened", at)
+		}
+	}
+
+	if _, err := openStream(enc, envelope, AssociatedData{DocumentID: "doc-2", Hash: hash}); err == nil {
+		t.Fatal("content opened for another document")
+	}
+	if _, err := openStream(enc, envelope, AssociatedData{DocumentID: "doc-1", Hash: Hash([]byte("other"))}); err == nil {
+		t.Fatal("content opened under another document hash")
+	}
+	if _, err := openStream(testEncryptionService(t, 2), envelope, ad); !errors.Is(err, ErrEnvelopeKey) {
+		t.Fatalf("other key: got %v, want ErrEnvelopeKey", err)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := testStreamData(3 * StreamChunkSize)
+	envelope, hash := sealStream(t, enc, "doc-1", data)
+	ad := AssociatedData{DocumentID: "doc-1", Hash: hash}
+
+	_, lastFull := streamChunk(1)
+	for _, n := range []int{streamHeaderSize, streamHeaderSize + 10, lastFull, lastFull + 10, len(envelope) - 1} {
+		if got, err := openStream(enc, envelope[:n], ad); err == nil {
+			t.Fatalf("cut at %d bytes: opened %d bytes", n, len(got))
+		}
+	}
+}
+
+func TestStreamChunkReorder(t *testing.T) {
+	enc := testEncryptionService(t, 1)
+	data := testStreamData(3*StreamChunkSize + 17)
+	envelope, hash := sealStream(t, enc, "doc-1", data)
+	ad := AssociatedData{DocumentID: "doc-1", Hash: hash}
+
+	start0, end0 := streamChunk(0)
+	start1, end1 := streamChunk(1)
+	swapped := append([]byte{}, envelope[:start0]...)
+	swapped = append(swapped, envelope[start1:end1]...)
+	swapped = append(swapped, envelope[start0:end0]...)
+	swapped = append(swapped, envelope[end1:]...)
+	if _, err := openStream(enc, swapped, ad); err == nil {
+		t.Fatal("content with swapped chunks opened")
+	}
+
+	// Dropping a middle chunk shifts every later chunk's index.
+	start2, end2 := streamChunk(2)
+	dropped := append(append([]byte{}, envelope[:start2]...), envelope[end2:]...)
+	if _, err := openStream(enc, dropped, ad); err == nil {
+		t.Fatal("content with a dropped chunk opened")
+	}
+}
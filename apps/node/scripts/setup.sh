@@ -95,8 +95,8 @@ echo ""
 echo -e "${GREEN}🎉 Setup complete!${NC}"
 echo ""
 echo "To start the node:"
-echo "  go run cmd/main.go"
+echo "  go run ./cmd"
 echo ""
 echo "Or with custom config:"
-echo "  DATA_DIR=./data API_PORT=8080 go run cmd/main.go"
+echo "  DATA_DIR=./data API_PORT=8080 go run ./cmd"
 echo ""
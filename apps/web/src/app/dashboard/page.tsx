@@ -252,7 +252,7 @@ export default function Dashboard() {
                 </p>
               </div>
               <code className="hidden md:block text-xs bg-amber-200/70 text-amber-900 px-3 py-1.5 rounded-lg font-mono">
-                cd apps/node && go run cmd/main.go
+                cd apps/node && go run ./cmd
               </code>
             </div>
           </div>
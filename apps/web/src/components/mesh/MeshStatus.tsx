@@ -91,7 +91,7 @@ export function MeshStatus() {
               Start the backend to enable real-time P2P mesh networking:
             </p>
             <code className="text-xs bg-amber-100 text-amber-900 px-3 py-2 rounded block font-mono">
-              cd apps/node && go run cmd/main.go
+              cd apps/node && go run ./cmd
             </code>
             <p className="text-xs text-amber-600 mt-2">
               The mesh will auto-connect once the backend is available.
@@ -222,7 +222,7 @@ export function useVault(vaultPassword = 'default-vault-key'): UseVaultReturn {
 
         // 2. Generate ZKP
         try {
-          const zkpRes = await fetch(`${API_URL}/zkp/generate`, {
+          const zkpRes = await fetch(`${API_URL}/zkp/generate?wait=true`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
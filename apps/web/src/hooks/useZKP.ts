@@ -36,7 +36,7 @@ export function useZKP(): UseZKPReturn {
       setError(null);
 
       try {
-        const response = await fetch(`${API_URL}/zkp/generate`, {
+        const response = await fetch(`${API_URL}/zkp/generate?wait=true`, {
           method: 'POST',
           headers: { 'Content-Type': 'application/json' },
           body: JSON.stringify({ document_id: documentId, proof_type: type }),